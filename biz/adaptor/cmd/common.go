@@ -6,6 +6,8 @@ type Response struct {
 }
 
 type Paging struct {
-	Page  int `json:"page"`
-	Limit int `json:"limit"`
+	Page  int `query:"page" json:"page"`
+	Limit int `query:"limit" json:"limit"`
+	// Cursor 上一页返回的游标, 不为空时忽略Page
+	Cursor string `query:"cursor" json:"cursor"`
 }
@@ -0,0 +1,22 @@
+package cmd
+
+// 检查结果状态
+const (
+	StatusOk   = "ok"
+	StatusFail = "fail"
+)
+
+// ReadyResp 就绪检查响应
+type ReadyResp struct {
+	Status string            `json:"status"`
+	Checks map[string]*Check `json:"checks"`
+}
+
+// Check 单个依赖的检查结果
+type Check struct {
+	Status  string `json:"status"`
+	Latency int64  `json:"latency_ms"`
+	Error   string `json:"error,omitempty"`
+	// Cached 是否为缓存的探测结果
+	Cached bool `json:"cached,omitempty"`
+}
@@ -1,30 +1,82 @@
 package cmd
 
+// ListHistoryReq 各项筛选条件为空时不筛选
 type ListHistoryReq struct {
-	Paging Paging `json:"paging"`
+	Paging        Paging `json:"paging"`
+	SeniorId      string `query:"senior_id" json:"senior_id"`
+	InstitutionId string `query:"institution_id" json:"institution_id"`
+	// From, To 对话开始时间的范围, 单位秒, 包含From不包含To
+	From int64 `query:"from" json:"from"`
+	To   int64 `query:"to" json:"to"`
+	// RiskLevel 风险等级, high, medium或low
+	RiskLevel   string `query:"risk_level" json:"risk_level"`
+	EmotionTone string `query:"emotion_tone" json:"emotion_tone"`
+	// ReportStatus 报告的生成状态, done或failed
+	ReportStatus string `query:"report_status" json:"report_status"`
+	// Query 检索对话内容
+	Query string `query:"query" json:"query"`
 }
 
+// ListHistoryResp 列表中的记录不包含对话内容, 通过详情获取
 type ListHistoryResp struct {
 	Code    int64      `json:"code"`
 	Msg     string     `json:"msg"`
 	History []*History `json:"history"`
 	Total   int64      `json:"total"`
+	// NextCursor 下一页的游标, 没有下一页时为空
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type GetHistoryReq struct {
+	ID string `path:"id" json:"id"`
+}
+
+type GetHistoryResp struct {
+	Code    int64    `json:"code"`
+	Msg     string   `json:"msg"`
+	History *History `json:"history"`
 }
 
 // History 聊天记录与报表
 type History struct {
-	ID        string    `json:"id,omitempty"`
-	Name      string    `json:"name"`
-	Class     string    `json:"class"`
-	Dialogs   []*Dialog `json:"dialogs"`
-	Report    *Report   `json:"report"`
-	StartTime int64     `json:"start_time"`
-	EndTime   int64     `json:"end_time"`
+	ID            string    `json:"id,omitempty"`
+	Name          string    `json:"name"`
+	Class         string    `json:"class"`
+	SessionId     string    `json:"session_id,omitempty"`
+	SeniorId      string    `json:"senior_id,omitempty"`
+	InstitutionId string    `json:"institution_id,omitempty"`
+	Lang          string    `json:"lang,omitempty"`
+	Dialogs       []*Dialog `json:"dialogs,omitempty"`
+	Report        *Report   `json:"report"`
+	ReportStatus  string    `json:"report_status,omitempty"`
+	RiskLevel     string    `json:"risk_level,omitempty"`
+	StartTime     int64     `json:"start_time"`
+	EndTime       int64     `json:"end_time"`
 }
 
 type Dialog struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Timestamp 消息的时间, 单位毫秒, 与对话中的记录一致
+	Timestamp     int64  `json:"timestamp"`
+	Turn          int64  `json:"turn"`
+	Provider      string `json:"provider,omitempty"`
+	Model         string `json:"model,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	Source        string `json:"source,omitempty"`
+	Raw           string `json:"raw,omitempty"`
+	FirstToken    int64  `json:"first_token,omitempty"`
+	Duration      int64  `json:"duration,omitempty"`
+	FirstAudio    int64  `json:"first_audio,omitempty"`
+	Usage         *Usage `json:"usage,omitempty"`
+	Interrupted   bool   `json:"interrupted,omitempty"`
+	Emotion       string `json:"emotion,omitempty"`
+	VoiceStyle    string `json:"voice_style,omitempty"`
+}
+
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
 }
 
 type (
@@ -0,0 +1,25 @@
+package cmd
+
+type GetHotwordReq struct {
+	SeniorId string `query:"senior_id" json:"senior_id"`
+}
+
+type UpdateHotwordReq struct {
+	SeniorId string `json:"senior_id"`
+	Hotword
+}
+
+type HotwordResp struct {
+	Code    int64    `json:"code"`
+	Msg     string   `json:"msg"`
+	Hotword *Hotword `json:"hotword"`
+}
+
+// Hotword 老人的识别热词, 合计最多100个, 每个最多10个字
+type Hotword struct {
+	Family      []string `json:"family"`
+	Pets        []string `json:"pets"`
+	Places      []string `json:"places"`
+	Medications []string `json:"medications"`
+	Others      []string `json:"others"`
+}
@@ -0,0 +1,15 @@
+package cmd
+
+type ListLanguagesReq struct{}
+
+type ListLanguagesResp struct {
+	Code      int64       `json:"code"`
+	Msg       string      `json:"msg"`
+	Languages []*Language `json:"languages"`
+}
+
+// Language 可选的语言或方言, Code作为开始对话与识别时的lang
+type Language struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
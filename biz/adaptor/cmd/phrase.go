@@ -0,0 +1,15 @@
+package cmd
+
+type WarmPhraseReq struct {
+	// Phrases 需要预先合成的话术, 必须是配置的话术或各语言的固定话术, 为空时合成全部
+	Phrases []string `json:"phrases"`
+}
+
+type WarmPhraseResp struct {
+	Code int64  `json:"code"`
+	Msg  string `json:"msg"`
+	// Filled, Cached, Failed 本次合成, 已经缓存与合成失败的音频数
+	Filled int `json:"filled"`
+	Cached int `json:"cached"`
+	Failed int `json:"failed"`
+}
@@ -0,0 +1,39 @@
+package cmd
+
+type GetPreferenceReq struct {
+	SeniorId string `query:"senior_id" json:"senior_id"`
+}
+
+type UpdatePreferenceReq struct {
+	SeniorId string `json:"senior_id"`
+	Settings
+}
+
+type UpdateRecordConsentReq struct {
+	SeniorId string `json:"senior_id"`
+	// RecordConsent 是否同意保存对话录音, 家属可以回听
+	RecordConsent bool `json:"record_consent"`
+}
+
+type PreferenceResp struct {
+	Code       int64       `json:"code"`
+	Msg        string      `json:"msg"`
+	Preference *Preference `json:"preference"`
+}
+
+// Preference 老人的偏好
+type Preference struct {
+	Settings
+	// RecordConsent 是否同意保存对话录音, 只能通过单独的接口修改
+	RecordConsent bool `json:"record_consent"`
+}
+
+// Settings 老人的语音与显示偏好, 各项为0或空时使用默认值
+type Settings struct {
+	Speaker string `json:"speaker"`
+	// SpeechRate, Volume 语速与音量的调整, 取值-50~100
+	SpeechRate int `json:"speech_rate"`
+	Volume     int `json:"volume"`
+	// TextSize 字号的调整级别, 取值-2~4
+	TextSize int `json:"text_size"`
+}
@@ -0,0 +1,34 @@
+package cmd
+
+type GetRecordingReq struct {
+	SessionId string `query:"session_id" json:"session_id"`
+	// Side 说话的一方, user或ai
+	Side string `query:"side" json:"side"`
+	// Turn 对话轮次, 不传时返回整次会话
+	Turn *int64 `query:"turn" json:"turn"`
+}
+
+type ListRecordingReq struct {
+	SessionId string `query:"session_id" json:"session_id"`
+	// Side 说话的一方, 不传时包含双方
+	Side string `query:"side" json:"side"`
+	// Turn 对话轮次, 不传时包含所有轮次
+	Turn *int64 `query:"turn" json:"turn"`
+}
+
+type ListRecordingResp struct {
+	Code       int64        `json:"code"`
+	Msg        string       `json:"msg"`
+	Recordings []*Recording `json:"recordings"`
+}
+
+// Recording 一轮中一方的录音, 用于与对话记录对齐
+type Recording struct {
+	Side   string `json:"side"`
+	Turn   int64  `json:"turn"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+	// Start, End 录音开始与结束的时间, 单位毫秒
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
@@ -0,0 +1,45 @@
+package cmd
+
+type GetUsageReq struct {
+	// Scope 统计维度, app, institution或senior
+	Scope string `query:"scope" json:"scope"`
+	Id    string `query:"id" json:"id"`
+	// From, To 每日用量的查询范围, 格式为 2006-01-02, 默认为本月
+	From string `query:"from" json:"from"`
+	To   string `query:"to" json:"to"`
+}
+
+type GetUsageResp struct {
+	Code int64  `json:"code"`
+	Msg  string `json:"msg"`
+	// Today, Month 当日与当月的实时用量
+	Today *UsageStat `json:"today"`
+	Month *UsageStat `json:"month"`
+	// Daily 每日用量, 只包含已结束的会话
+	Daily []*DailyUsage `json:"daily"`
+	Quota *Quota        `json:"quota"`
+}
+
+type UsageStat struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TtsChars     int64 `json:"tts_chars"`
+	AsrMillis    int64 `json:"asr_millis"`
+}
+
+type DailyUsage struct {
+	Date string `json:"date"`
+	UsageStat
+}
+
+// Quota 额度, 0表示不限制
+type Quota struct {
+	Daily   *QuotaLimit `json:"daily"`
+	Monthly *QuotaLimit `json:"monthly"`
+}
+
+type QuotaLimit struct {
+	Tokens     int64 `json:"tokens"`
+	TtsChars   int64 `json:"tts_chars"`
+	AsrSeconds int64 `json:"asr_seconds"`
+}
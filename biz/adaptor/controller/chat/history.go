@@ -24,3 +24,19 @@ func ListHistory(ctx context.Context, c *app.RequestContext) {
 	resp, err := p.HistoryService.ListHistory(ctx, &req)
 	adaptor.PostProcess(ctx, c, &req, resp, err)
 }
+
+// GetHistory .
+// @router /chat/history/:id [GET]
+func GetHistory(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.GetHistoryReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.HistoryService.GetHistory(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
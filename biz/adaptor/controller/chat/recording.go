@@ -0,0 +1,47 @@
+package chat
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/xh-polaris/psych-senior/biz/adaptor"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/provider"
+)
+
+// GetRecording 流式返回录音文件
+// @router /chat/recording [GET]
+func GetRecording(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.GetRecordingReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	playback, err := p.RecordingService.GetRecording(ctx, &req)
+	if err != nil {
+		adaptor.PostProcess(ctx, c, &req, nil, err)
+		return
+	}
+	c.SetContentType(playback.ContentType)
+	c.SetBodyStream(playback, int(playback.Size))
+}
+
+// ListRecording .
+// @router /chat/recording/list [GET]
+func ListRecording(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.ListRecordingReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.RecordingService.ListRecording(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
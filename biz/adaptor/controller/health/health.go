@@ -0,0 +1,27 @@
+package health
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/provider"
+)
+
+// Healthz 存活检查, 进程能够响应即可
+// @router /healthz [GET]
+func Healthz(ctx context.Context, c *app.RequestContext) {
+	c.JSON(consts.StatusOK, &cmd.ReadyResp{Status: cmd.StatusOk})
+}
+
+// Readyz 就绪检查, 任一必要依赖不可用时返回503
+// @router /readyz [GET]
+func Readyz(ctx context.Context, c *app.RequestContext) {
+	p := provider.Get()
+	resp := p.HealthService.Ready(ctx)
+	code := consts.StatusOK
+	if resp.Status != cmd.StatusOk {
+		code = consts.StatusServiceUnavailable
+	}
+	c.JSON(code, resp)
+}
@@ -0,0 +1,26 @@
+package phrase
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/xh-polaris/psych-senior/biz/adaptor"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/provider"
+)
+
+// Warm .
+// @router /phrase/warm [POST]
+func Warm(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.WarmPhraseReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.PhraseService.Warm(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
@@ -0,0 +1,42 @@
+package senior
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/xh-polaris/psych-senior/biz/adaptor"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/provider"
+)
+
+// GetHotword .
+// @router /senior/hotword [GET]
+func GetHotword(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.GetHotwordReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.HotwordService.GetHotword(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
+
+// UpdateHotword .
+// @router /senior/hotword [POST]
+func UpdateHotword(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.UpdateHotwordReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.HotwordService.UpdateHotword(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
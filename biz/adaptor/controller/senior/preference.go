@@ -0,0 +1,58 @@
+package senior
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/xh-polaris/psych-senior/biz/adaptor"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/provider"
+)
+
+// GetPreference .
+// @router /senior/preference [GET]
+func GetPreference(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.GetPreferenceReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.PreferenceService.GetPreference(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
+
+// UpdatePreference .
+// @router /senior/preference [POST]
+func UpdatePreference(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.UpdatePreferenceReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.PreferenceService.UpdatePreference(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
+
+// UpdateRecordConsent .
+// @router /senior/record_consent [POST]
+func UpdateRecordConsent(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.UpdateRecordConsentReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.PreferenceService.UpdateRecordConsent(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
@@ -0,0 +1,26 @@
+package usage
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/xh-polaris/psych-senior/biz/adaptor"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/provider"
+)
+
+// GetUsage .
+// @router /usage [GET]
+func GetUsage(ctx context.Context, c *app.RequestContext) {
+	var err error
+	var req cmd.GetUsageReq
+	err = c.BindAndValidate(&req)
+	if err != nil {
+		c.String(consts.StatusBadRequest, err.Error())
+		return
+	}
+
+	p := provider.Get()
+	resp, err := p.UsageService.GetUsage(ctx, &req)
+	adaptor.PostProcess(ctx, c, &req, resp, err)
+}
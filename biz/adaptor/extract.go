@@ -6,6 +6,7 @@ import (
 	"github.com/xh-polaris/gopkg/util"
 	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"slices"
 
 	"encoding/json"
 	"github.com/cloudwego/hertz/pkg/app"
@@ -39,22 +40,7 @@ func ExtractUserMeta(ctx context.Context) (user *basic.UserMeta) {
 			log.CtxInfo(ctx, "extract user meta fail, err=%v", err)
 		}
 	}()
-	c, err := ExtractContext(ctx)
-	if err != nil {
-		return
-	}
-	tokenString := c.GetHeader("Authorization")
-	token, err := jwt.Parse(string(tokenString), func(_ *jwt.Token) (interface{}, error) {
-		return jwt.ParseECPublicKeyFromPEM([]byte(config.GetConfig().Auth.PublicKey))
-	})
-	if err != nil {
-		return
-	}
-	if !token.Valid {
-		err = errors.New("token is not valid")
-		return
-	}
-	data, err := json.Marshal(token.Claims)
+	data, err := extractClaims(ctx)
 	if err != nil {
 		return
 	}
@@ -75,6 +61,53 @@ func ExtractUserMeta(ctx context.Context) (user *basic.UserMeta) {
 	return
 }
 
+// Access 调用方可以访问的老人, 由令牌中的声明给出
+type Access struct {
+	UserId string `json:"userId"`
+	// SeniorIds 可以访问的老人, 如家属绑定的老人与机构工作人员负责的老人
+	SeniorIds []string `json:"seniorIds"`
+}
+
+// ExtractAccess 解析调用方可以访问的老人, 未登录时UserId为空
+func ExtractAccess(ctx context.Context) (access *Access) {
+	access = new(Access)
+	data, err := extractClaims(ctx)
+	if err == nil {
+		err = json.Unmarshal(data, access)
+	}
+	if err != nil {
+		log.CtxInfo(ctx, "extract access fail, err=%v", err)
+	}
+	return
+}
+
+// Senior 是否可以访问老人的数据, 老人本人或令牌中列出的老人
+func (a *Access) Senior(seniorId string) bool {
+	if a.UserId == "" || seniorId == "" {
+		return false
+	}
+	return a.UserId == seniorId || slices.Contains(a.SeniorIds, seniorId)
+}
+
+// extractClaims 校验请求的令牌, 返回其中的声明
+func extractClaims(ctx context.Context) ([]byte, error) {
+	c, err := ExtractContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokenString := c.GetHeader("Authorization")
+	token, err := jwt.Parse(string(tokenString), func(_ *jwt.Token) (interface{}, error) {
+		return jwt.ParseECPublicKeyFromPEM([]byte(config.GetConfig().Auth.PublicKey))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("token is not valid")
+	}
+	return json.Marshal(token.Claims)
+}
+
 func ExtractExtra(ctx context.Context) (extra *basic.Extra) {
 	extra = new(basic.Extra)
 	var err error
@@ -3,15 +3,37 @@ package router
 import (
 	"github.com/cloudwego/hertz/pkg/app/server"
 	"github.com/xh-polaris/psych-senior/biz/adaptor/controller/chat"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/controller/health"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/controller/phrase"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/controller/senior"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/controller/usage"
 	"github.com/xh-polaris/psych-senior/biz/adaptor/controller/voice"
 )
 
 func Register(r *server.Hertz) {
 	root := r.Group("/", _rootMw()...)
+	{
+		root.GET("/healthz", health.Healthz)
+		root.GET("/readyz", health.Readyz)
+		root.GET("/usage", usage.GetUsage)
+		root.POST("/phrase/warm", phrase.Warm)
+	}
 	{
 		_chat := root.Group("/chat")
 		_chat.GET("/", append(_longchatMw(), chat.LongChat)...)
 		_chat.GET("/history/list", chat.ListHistory)
+		_chat.GET("/history/:id", chat.GetHistory)
+		_chat.GET("/languages", chat.ListLanguages)
+		_chat.GET("/recording", chat.GetRecording)
+		_chat.GET("/recording/list", chat.ListRecording)
+	}
+	{
+		_senior := root.Group("/senior")
+		_senior.GET("/preference", senior.GetPreference)
+		_senior.POST("/preference", senior.UpdatePreference)
+		_senior.POST("/record_consent", senior.UpdateRecordConsent)
+		_senior.GET("/hotword", senior.GetHotword)
+		_senior.POST("/hotword", senior.UpdateHotword)
 	}
 	{
 		_voice := root.Group("/voice")
@@ -9,6 +9,14 @@ type (
 		From string `json:"from"`
 		// 语言
 		Lang string `json:"lang"`
+		// 使用者所属的应用, 机构与老人, 用于用量统计与额度控制
+		AppId         string `json:"app_id"`
+		InstitutionId string `json:"institution_id"`
+		SeniorId      string `json:"senior_id"`
+		// OutputFormat 下发音频的格式, 默认与语音合成的格式一致
+		OutputFormat AudioFormat `json:"output_format"`
+		// Captions 是否下发与音频对齐的字幕
+		Captions bool `json:"captions"`
 	}
 
 	// ChatReq 对话请求
@@ -16,6 +24,45 @@ type (
 		// 命令, 0对话, -1结束
 		Cmd int64  `json:"cmd"`
 		Msg string `json:"msg"`
+		// 输入来源, asr语音识别或text文字输入, 默认为text
+		Source string `json:"source"`
+	}
+
+	// ChatNotice 服务状态的通知
+	ChatNotice struct {
+		// Type 固定为notice
+		Type  string `json:"type"`
+		Event string `json:"event"`
+		Msg   string `json:"msg"`
+	}
+
+	// ChatCaption 与音频对齐的字幕, 时间为相对本次对话已下发音频开头的毫秒数
+	ChatCaption struct {
+		// Type 固定为caption
+		Type  string `json:"type"`
+		Event string `json:"event"`
+		Text  string `json:"text"`
+		Start int64  `json:"start"`
+		End   int64  `json:"end,omitempty"`
+		// Words 逐字的时间, 只在句子结束时下发
+		Words []*CaptionWord `json:"words,omitempty"`
+	}
+
+	// CaptionWord 一个字或词的时间
+	CaptionWord struct {
+		Text  string `json:"text"`
+		Start int64  `json:"start"`
+		End   int64  `json:"end"`
+	}
+
+	// ChatPreference 老人的语音与显示偏好, 前端据此调整字号
+	ChatPreference struct {
+		// Type 固定为preference
+		Type       string `json:"type"`
+		Speaker    string `json:"speaker,omitempty"`
+		SpeechRate int    `json:"speech_rate"`
+		Volume     int    `json:"volume"`
+		TextSize   int    `json:"text_size"`
 	}
 
 	// ChatEndResp 对话结束响应
@@ -31,12 +78,47 @@ type (
 		SessionId string `json:"session_id"`
 		Timestamp int64  `json:"timestamp"`
 		Finish    string `json:"finish"`
+		// Model 实际调用的模型, 不返回给前端
+		Model string `json:"-"`
+		// Usage 模型用量, 不返回给前端
+		Usage *Usage `json:"-"`
+	}
+
+	// Usage 模型token用量
+	Usage struct {
+		InputTokens  int64 `json:"input_tokens"`
+		OutputTokens int64 `json:"output_tokens"`
 	}
 
 	// ChatHistory 对话记录
 	ChatHistory struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
+		// Timestamp 毫秒时间戳
+		Timestamp int64 `json:"timestamp,omitempty"`
+		// Turn 对话轮次, 开场白为0
+		Turn          int64  `json:"turn"`
+		Provider      string `json:"provider,omitempty"`
+		Model         string `json:"model,omitempty"`
+		PromptVersion string `json:"prompt_version,omitempty"`
+		// Source 用户输入的来源, asr或text
+		Source string `json:"source,omitempty"`
+		// Raw 语音识别的原文, Content为规范化后发送给大模型的文本
+		Raw string `json:"raw,omitempty"`
+		// FirstToken, Duration, FirstAudio AI回复的首token耗时, 生成总耗时, 首音频耗时, 单位毫秒
+		FirstToken int64  `json:"first_token,omitempty"`
+		Duration   int64  `json:"duration,omitempty"`
+		FirstAudio int64  `json:"first_audio,omitempty"`
+		Usage      *Usage `json:"usage,omitempty"`
+		// TtsChars 本轮送去合成的字数
+		TtsChars int64 `json:"tts_chars,omitempty"`
+		// AudioCached 固定话术是否使用了缓存的音频, 此时不计入合成字数
+		AudioCached bool `json:"audio_cached,omitempty"`
+		// Interrupted AI回复是否被打断
+		Interrupted bool `json:"interrupted,omitempty"`
+		// Emotion, VoiceStyle AI回复的情绪与合成语音使用的风格
+		Emotion    string `json:"emotion,omitempty"`
+		VoiceStyle string `json:"voice_style,omitempty"`
 	}
 
 	Report struct {
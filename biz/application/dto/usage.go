@@ -0,0 +1,23 @@
+package dto
+
+// UsageStat 一段时间或一次会话内的用量
+type UsageStat struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TtsChars     int64 `json:"tts_chars"`
+	// AsrMillis 识别的音频时长, 单位毫秒
+	AsrMillis int64 `json:"asr_millis"`
+}
+
+// Add 累加用量
+func (u *UsageStat) Add(o *UsageStat) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.TtsChars += o.TtsChars
+	u.AsrMillis += o.AsrMillis
+}
+
+// Empty 是否没有任何用量
+func (u *UsageStat) Empty() bool {
+	return u.InputTokens == 0 && u.OutputTokens == 0 && u.TtsChars == 0 && u.AsrMillis == 0
+}
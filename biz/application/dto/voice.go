@@ -1,8 +1,36 @@
 package dto
 
 type (
+	// AudioFormat 前端的音频格式, 为空的项使用服务端的格式
+	AudioFormat struct {
+		// Encoding 编码, pcm16, float32, wav, ogg(ogg封装的opus) 或 opus(未封装的opus数据包, 每个ws消息一个)
+		Encoding   string `json:"encoding"`
+		SampleRate int    `json:"sample_rate"`
+		Channels   int    `json:"channels"`
+	}
+
+	// AsrStartReq 语音识别的开始请求, 可选, 未发送时直接按音频处理
+	AsrStartReq struct {
+		AppId         string `json:"app_id"`
+		InstitutionId string `json:"institution_id"`
+		SeniorId      string `json:"senior_id"`
+		// SessionId 同时进行的对话, 最近的对话内容作为识别的上下文
+		SessionId string `json:"session_id"`
+		// Lang 识别的语言, 与对话的语言一致, 为空时使用默认的识别模型
+		Lang string `json:"lang"`
+		// InputFormat 上传音频的格式, 默认为16k采样率的单声道pcm16
+		InputFormat AudioFormat `json:"input_format"`
+	}
+
+	// AsrResp 一句话的识别结果, partial为中间结果, 之后会被同一句话的结果替换, final为确定的结果
 	AsrResp struct {
+		Type      string `json:"type"`
 		Text      string `json:"text"`
 		Timestamp int64  `json:"timestamp"`
+		// Start, End 句子在本次识别的音频中的起止毫秒数
+		Start int64 `json:"start,omitempty"`
+		End   int64 `json:"end,omitempty"`
+		// Confidence 置信度, 服务端未返回时省略
+		Confidence float64 `json:"confidence,omitempty"`
 	}
 )
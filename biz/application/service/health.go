@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/wire"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/biz/domain"
+	"github.com/xh-polaris/psych-senior/biz/domain/language"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/mapper/history"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/mq"
+)
+
+// dashScopeUrl 百炼服务地址, 用于连通性探测
+const dashScopeUrl = "https://dashscope.aliyuncs.com"
+
+// Health为可选配置, 未配置时其中的默认值不会生效, 在代码中补全
+const (
+	defaultHealthTimeout = 2000
+	defaultProbeCache    = 30
+)
+
+type IHealthService interface {
+	Ready(ctx context.Context) *cmd.ReadyResp
+}
+
+type HealthService struct {
+	Config        *config.Config
+	HistoryMapper *history.MongoMapper
+	Registry      *language.Registry
+}
+
+var HealthServiceSet = wire.NewSet(
+	wire.Struct(new(HealthService), "*"),
+	wire.Bind(new(IHealthService), new(*HealthService)),
+)
+
+// ttsChains 语音合成方式对应的探测项
+var ttsChains = map[string]string{
+	consts.VolcTts:        "tts",
+	consts.VolcNoModelTts: "tts_nomodel",
+}
+
+// probes 第三方服务探测结果的缓存, 避免每次就绪检查都访问外部服务
+var probes = struct {
+	mu     sync.Mutex
+	checks map[string]*cmd.Check
+	expire time.Time
+}{}
+
+// Ready 检查各依赖的连通性
+// Redis, Mongo, RabbitMQ 决定是否就绪, 第三方模型服务的探测结果只做展示, 避免外部故障导致所有实例下线
+func (s *HealthService) Ready(ctx context.Context) *cmd.ReadyResp {
+	timeout := s.Config.Health.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+
+	required := map[string]func(ctx context.Context) error{
+		"redis": func(ctx context.Context) error {
+			if !domain.GetRedisHelper().Ping(ctx) {
+				return errors.New("redis ping failed")
+			}
+			return nil
+		},
+		"mongo": s.HistoryMapper.Ping,
+		"rabbitmq": func(_ context.Context) error {
+			return mq.Healthy()
+		},
+	}
+
+	resp := &cmd.ReadyResp{
+		Status: cmd.StatusOk,
+		Checks: run(ctx, required),
+	}
+	for _, c := range resp.Checks {
+		if c.Status != cmd.StatusOk {
+			resp.Status = cmd.StatusFail
+		}
+	}
+
+	if s.Config.Health.Probe {
+		for name, c := range s.probe(ctx) {
+			resp.Checks[name] = c
+		}
+	}
+	return resp
+}
+
+// probe 探测第三方模型服务, 结果在ProbeCache时间内复用, 探测期间不持有锁
+func (s *HealthService) probe(ctx context.Context) map[string]*cmd.Check {
+	probes.mu.Lock()
+	if probes.checks != nil && time.Now().Before(probes.expire) {
+		cached := make(map[string]*cmd.Check, len(probes.checks))
+		for name, c := range probes.checks {
+			cc := *c
+			cc.Cached = true
+			cached[name] = &cc
+		}
+		probes.mu.Unlock()
+		return cached
+	}
+	probes.mu.Unlock()
+
+	chains := s.chains()
+	checks := make(map[string]func(ctx context.Context) error, len(chains))
+	for name, targets := range chains {
+		checks[name] = func(ctx context.Context) error {
+			return dialAny(ctx, targets)
+		}
+	}
+	res := run(ctx, checks)
+	// 语言使用的合成与识别链路均可用时该语言可用
+	for _, l := range s.Registry.List() {
+		c := &cmd.Check{Status: cmd.StatusOk}
+		for _, name := range []string{ttsChains[l.Tts.Backend], "asr"} {
+			if r, ok := res[name]; ok && r.Status != cmd.StatusOk {
+				c.Status, c.Error = cmd.StatusFail, name+" unavailable"
+			}
+		}
+		res["language:"+l.Code] = c
+	}
+
+	cache := s.Config.Health.ProbeCache
+	if cache <= 0 {
+		cache = defaultProbeCache
+	}
+	probes.mu.Lock()
+	probes.checks, probes.expire = res, time.Now().Add(time.Duration(cache)*time.Second)
+	probes.mu.Unlock()
+	return res
+}
+
+// chains 各服务的主后端与备用后端地址, 只包含有语言使用的合成方式
+// 对话与报告的所有后端均为百炼应用, 使用同一地址
+func (s *HealthService) chains() map[string][]string {
+	c := s.Config
+	chains := map[string][]string{"llm": {dashScopeUrl}}
+	for _, l := range s.Registry.List() {
+		switch l.Tts.Backend {
+		case consts.VolcTts:
+			chains["tts"] = urls(append([]config.VolcTts{c.VolcTts}, c.Failover.VolcTts...), func(b config.VolcTts) string { return b.Url })
+		case consts.VolcNoModelTts:
+			chains["tts_nomodel"] = urls(append([]config.VolcNoModelTts{c.VolcNoModelTts}, c.Failover.VolcNoModelTts...), func(b config.VolcNoModelTts) string { return b.Url })
+		}
+	}
+	chains["asr"] = urls(append([]config.VolcAsr{c.VolcAsr}, c.Failover.VolcAsr...), func(b config.VolcAsr) string { return b.Url })
+	for name, targets := range chains {
+		if len(targets) == 0 {
+			delete(chains, name)
+		}
+	}
+	return chains
+}
+
+// urls 后端的地址, 跳过未配置的
+func urls[T any](backends []T, url func(T) string) []string {
+	var res []string
+	for _, b := range backends {
+		if u := url(b); u != "" {
+			res = append(res, u)
+		}
+	}
+	return res
+}
+
+// run 并发执行检查
+func run(ctx context.Context, checks map[string]func(ctx context.Context) error) map[string]*cmd.Check {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	res := make(map[string]*cmd.Check, len(checks))
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := check(ctx)
+			c := &cmd.Check{
+				Status:  cmd.StatusOk,
+				Latency: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				c.Status = cmd.StatusFail
+				c.Error = err.Error()
+			}
+			mu.Lock()
+			res[name] = c
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// dialAny 并发连接链路中的各后端, 任一可达即可用
+func dialAny(ctx context.Context, targets []string) error {
+	errs := make(chan error, len(targets))
+	for _, target := range targets {
+		go func() {
+			errs <- dial(ctx, target)
+		}()
+	}
+	var all []error
+	for range targets {
+		err := <-errs
+		if err == nil {
+			return nil
+		}
+		all = append(all, err)
+	}
+	return errors.Join(all...)
+}
+
+// dial 建立tcp连接以确认服务可达
+func dial(ctx context.Context, target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "http", "ws":
+			host = net.JoinHostPort(u.Hostname(), "80")
+		default:
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -2,14 +2,20 @@ package service
 
 import (
 	"context"
+	"errors"
+	"time"
+
 	"github.com/google/wire"
 	"github.com/jinzhu/copier"
 	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/mapper/history"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/util"
 )
 
 type IHistoryService interface {
 	ListHistory(ctx context.Context, req *cmd.ListHistoryReq) (*cmd.ListHistoryResp, error)
+	GetHistory(ctx context.Context, req *cmd.GetHistoryReq) (*cmd.GetHistoryResp, error)
 }
 
 type HistoryService struct {
@@ -21,43 +27,143 @@ var HistoryServiceSet = wire.NewSet(
 	wire.Bind(new(IHistoryService), new(*HistoryService)),
 )
 
+// ListHistory 按开始时间倒序筛选对话记录, 支持页码与游标分页
 func (s *HistoryService) ListHistory(ctx context.Context, req *cmd.ListHistoryReq) (*cmd.ListHistoryResp, error) {
-	data, total, err := s.HistoryMapper.FindMany(ctx, &req.Paging)
+	f, err := toFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	data, total, err := s.HistoryMapper.FindMany(ctx, f, &req.Paging)
+	if errors.Is(err, history.ErrInvalidCursor) {
+		return nil, consts.ErrInvalidParam
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	his := make([]*cmd.History, 0, len(data))
 	for _, h := range data {
-		dia := make([]*cmd.Dialog, 0, len(h.Dialogs))
-		for _, d := range h.Dialogs {
-			if d == nil {
-				continue
-			}
-			dia = append(dia, &cmd.Dialog{
-				Role:    d.Role,
-				Content: d.Content,
-			})
+		ch, err := toHistory(h)
+		if err != nil {
+			return nil, err
 		}
-		ch := &cmd.History{
-			ID:        h.ID.Hex(),
-			Dialogs:   dia,
-			StartTime: h.StartTime.Unix(),
-			EndTime:   h.EndTime.Unix(),
-			Report:    &cmd.Report{},
-		}
-		if h.Report != nil {
-			if err := copier.Copy(ch.Report, h.Report); err != nil {
-				return nil, err
-			}
-		}
-
 		his = append(his, ch)
 	}
-	return &cmd.ListHistoryResp{
+	resp := &cmd.ListHistoryResp{
 		Code:    0,
 		Msg:     "success",
 		History: his,
 		Total:   total,
-	}, nil
+	}
+	// 不足一页时没有下一页
+	if _, limit := util.ParsePaging(&req.Paging); int64(len(data)) == limit {
+		resp.NextCursor = history.NewCursor(data[len(data)-1]).Encode()
+	}
+	return resp, nil
+}
+
+// GetHistory 获取一次对话的记录与报告
+func (s *HistoryService) GetHistory(ctx context.Context, req *cmd.GetHistoryReq) (*cmd.GetHistoryResp, error) {
+	if req.ID == "" {
+		return nil, consts.ErrInvalidParam
+	}
+	h, err := s.HistoryMapper.FindOne(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return nil, consts.ErrNoHistory
+	}
+	ch, err := toHistory(h)
+	if err != nil {
+		return nil, err
+	}
+	return &cmd.GetHistoryResp{Code: 0, Msg: "success", History: ch}, nil
+}
+
+// toFilter 校验并转换筛选条件
+func toFilter(req *cmd.ListHistoryReq) (*history.Filter, error) {
+	switch req.RiskLevel {
+	case "", consts.RiskHigh, consts.RiskMedium, consts.RiskLow:
+	default:
+		return nil, consts.ErrInvalidParam
+	}
+	switch req.ReportStatus {
+	case "", consts.ReportDone, consts.ReportFailed:
+	default:
+		return nil, consts.ErrInvalidParam
+	}
+	f := &history.Filter{
+		SeniorId:      req.SeniorId,
+		InstitutionId: req.InstitutionId,
+		RiskLevel:     req.RiskLevel,
+		EmotionTone:   req.EmotionTone,
+		ReportStatus:  req.ReportStatus,
+		Query:         req.Query,
+	}
+	if req.From > 0 {
+		f.From = time.Unix(req.From, 0)
+	}
+	if req.To > 0 {
+		f.To = time.Unix(req.To, 0)
+	}
+	return f, nil
+}
+
+// toHistory 转换对话记录, 列表中的记录没有对话内容
+func toHistory(h *history.History) (*cmd.History, error) {
+	var dia []*cmd.Dialog
+	if len(h.Dialogs) > 0 {
+		dia = make([]*cmd.Dialog, 0, len(h.Dialogs))
+	}
+	for _, d := range h.Dialogs {
+		if d == nil {
+			continue
+		}
+		cd := &cmd.Dialog{
+			Role:          d.Role,
+			Content:       d.Content,
+			Turn:          d.Turn,
+			Provider:      d.Provider,
+			Model:         d.Model,
+			PromptVersion: d.PromptVersion,
+			Source:        d.Source,
+			Raw:           d.Raw,
+			FirstToken:    d.FirstToken,
+			Duration:      d.Duration,
+			FirstAudio:    d.FirstAudio,
+			Interrupted:   d.Interrupted,
+			Emotion:       d.Emotion,
+			VoiceStyle:    d.VoiceStyle,
+		}
+		if !d.Timestamp.IsZero() {
+			cd.Timestamp = d.Timestamp.UnixMilli()
+		}
+		if d.Usage != nil {
+			cd.Usage = &cmd.Usage{
+				InputTokens:  d.Usage.InputTokens,
+				OutputTokens: d.Usage.OutputTokens,
+			}
+		}
+		dia = append(dia, cd)
+	}
+	ch := &cmd.History{
+		ID:            h.ID.Hex(),
+		SessionId:     h.SessionId,
+		SeniorId:      h.SeniorId,
+		InstitutionId: h.InstitutionId,
+		Lang:          h.Lang,
+		Dialogs:       dia,
+		ReportStatus:  h.ReportStatus,
+		RiskLevel:     h.RiskLevel,
+		StartTime:     h.StartTime.Unix(),
+		EndTime:       h.EndTime.Unix(),
+		Report:        &cmd.Report{},
+	}
+	if h.Report != nil {
+		if err := copier.Copy(ch.Report, h.Report); err != nil {
+			return nil, err
+		}
+	}
+	return ch, nil
 }
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/wire"
+	"github.com/xh-polaris/psych-senior/biz/adaptor"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/biz/domain/hotword"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+)
+
+type IHotwordService interface {
+	GetHotword(ctx context.Context, req *cmd.GetHotwordReq) (*cmd.HotwordResp, error)
+	UpdateHotword(ctx context.Context, req *cmd.UpdateHotwordReq) (*cmd.HotwordResp, error)
+}
+
+type HotwordService struct {
+	Store *hotword.Store
+}
+
+var HotwordServiceSet = wire.NewSet(
+	wire.Struct(new(HotwordService), "*"),
+	wire.Bind(new(IHotwordService), new(*HotwordService)),
+)
+
+// GetHotword 查询老人的识别热词, 仅限可以访问该老人的用户
+func (s *HotwordService) GetHotword(ctx context.Context, req *cmd.GetHotwordReq) (*cmd.HotwordResp, error) {
+	if req.SeniorId == "" {
+		return nil, consts.ErrInvalidParam
+	}
+	if !adaptor.ExtractAccess(ctx).Senior(req.SeniorId) {
+		return nil, consts.ErrForbidden
+	}
+	h, err := s.Store.Load(ctx, req.SeniorId)
+	if err != nil {
+		return nil, err
+	}
+	return toHotwordResp(h), nil
+}
+
+// UpdateHotword 整体更新老人的识别热词, 下次识别时生效, 仅限可以访问该老人的用户
+func (s *HotwordService) UpdateHotword(ctx context.Context, req *cmd.UpdateHotwordReq) (*cmd.HotwordResp, error) {
+	if req.SeniorId == "" {
+		return nil, consts.ErrInvalidParam
+	}
+	if !adaptor.ExtractAccess(ctx).Senior(req.SeniorId) {
+		return nil, consts.ErrForbidden
+	}
+	h := &hotword.Hotword{
+		SeniorId:    req.SeniorId,
+		Family:      req.Family,
+		Pets:        req.Pets,
+		Places:      req.Places,
+		Medications: req.Medications,
+		Others:      req.Others,
+	}
+	if err := s.Store.Save(ctx, h); err != nil {
+		return nil, err
+	}
+	return toHotwordResp(h), nil
+}
+
+func toHotwordResp(h *hotword.Hotword) *cmd.HotwordResp {
+	return &cmd.HotwordResp{
+		Code: 0,
+		Msg:  "success",
+		Hotword: &cmd.Hotword{
+			Family:      h.Family,
+			Pets:        h.Pets,
+			Places:      h.Places,
+			Medications: h.Medications,
+			Others:      h.Others,
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/wire"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/biz/domain/language"
+)
+
+type ILanguageService interface {
+	ListLanguages(ctx context.Context, req *cmd.ListLanguagesReq) (*cmd.ListLanguagesResp, error)
+}
+
+type LanguageService struct {
+	Registry *language.Registry
+}
+
+var LanguageServiceSet = wire.NewSet(
+	wire.Struct(new(LanguageService), "*"),
+	wire.Bind(new(ILanguageService), new(*LanguageService)),
+)
+
+// ListLanguages 列出支持的语言与方言
+func (s *LanguageService) ListLanguages(_ context.Context, _ *cmd.ListLanguagesReq) (*cmd.ListLanguagesResp, error) {
+	resp := &cmd.ListLanguagesResp{Code: 0, Msg: "success", Languages: make([]*cmd.Language, 0)}
+	for _, l := range s.Registry.List() {
+		resp.Languages = append(resp.Languages, &cmd.Language{Code: l.Code, Name: l.Name})
+	}
+	return resp, nil
+}
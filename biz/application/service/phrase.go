@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/wire"
+	"github.com/xh-polaris/psych-senior/biz/adaptor"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/biz/domain/phrase"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+)
+
+type IPhraseService interface {
+	Warm(ctx context.Context, req *cmd.WarmPhraseReq) (*cmd.WarmPhraseResp, error)
+}
+
+type PhraseService struct {
+	Cache *phrase.Cache
+}
+
+var PhraseServiceSet = wire.NewSet(
+	wire.Struct(new(PhraseService), "*"),
+	wire.Bind(new(IPhraseService), new(*PhraseService)),
+)
+
+// Warm 为各语音合成后端预先合成固定话术的音频, 仅限登录的工作人员, 只能合成配置的话术
+func (s *PhraseService) Warm(ctx context.Context, req *cmd.WarmPhraseReq) (*cmd.WarmPhraseResp, error) {
+	if adaptor.ExtractUserMeta(ctx).GetUserId() == "" {
+		return nil, consts.ErrForbidden
+	}
+	res, err := s.Cache.Warm(ctx, req.Phrases)
+	if errors.Is(err, phrase.ErrUnknownPhrase) {
+		return nil, consts.ErrInvalidParam
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cmd.WarmPhraseResp{
+		Code:   0,
+		Msg:    "success",
+		Filled: res.Filled,
+		Cached: res.Cached,
+		Failed: res.Failed,
+	}, nil
+}
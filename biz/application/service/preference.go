@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/wire"
+	"github.com/xh-polaris/psych-senior/biz/adaptor"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/biz/domain/preference"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+)
+
+type IPreferenceService interface {
+	GetPreference(ctx context.Context, req *cmd.GetPreferenceReq) (*cmd.PreferenceResp, error)
+	UpdatePreference(ctx context.Context, req *cmd.UpdatePreferenceReq) (*cmd.PreferenceResp, error)
+	UpdateRecordConsent(ctx context.Context, req *cmd.UpdateRecordConsentReq) (*cmd.PreferenceResp, error)
+}
+
+type PreferenceService struct {
+	Store *preference.Store
+}
+
+var PreferenceServiceSet = wire.NewSet(
+	wire.Struct(new(PreferenceService), "*"),
+	wire.Bind(new(IPreferenceService), new(*PreferenceService)),
+)
+
+// GetPreference 查询老人的偏好, 没有保存过时返回默认值
+func (s *PreferenceService) GetPreference(ctx context.Context, req *cmd.GetPreferenceReq) (*cmd.PreferenceResp, error) {
+	if req.SeniorId == "" {
+		return nil, consts.ErrInvalidParam
+	}
+	p, err := s.Store.Load(ctx, req.SeniorId)
+	if err != nil {
+		return nil, err
+	}
+	return toPreferenceResp(p), nil
+}
+
+// UpdatePreference 整体更新老人的语音与显示偏好, 超出范围的取值会被限制在范围内, 下次对话时生效
+// 是否同意录音不在此修改, 仅限可以访问该老人的用户
+func (s *PreferenceService) UpdatePreference(ctx context.Context, req *cmd.UpdatePreferenceReq) (*cmd.PreferenceResp, error) {
+	if req.SeniorId == "" {
+		return nil, consts.ErrInvalidParam
+	}
+	if !adaptor.ExtractAccess(ctx).Senior(req.SeniorId) {
+		return nil, consts.ErrForbidden
+	}
+	p := &preference.Preference{
+		SeniorId:   req.SeniorId,
+		Speaker:    req.Speaker,
+		SpeechRate: req.SpeechRate,
+		Volume:     req.Volume,
+		TextSize:   req.TextSize,
+	}
+	if err := s.Store.Save(ctx, p); err != nil {
+		return nil, err
+	}
+	return s.GetPreference(ctx, &cmd.GetPreferenceReq{SeniorId: req.SeniorId})
+}
+
+// UpdateRecordConsent 修改老人是否同意录音, 仅限可以访问该老人的用户, 下次对话时生效
+func (s *PreferenceService) UpdateRecordConsent(ctx context.Context, req *cmd.UpdateRecordConsentReq) (*cmd.PreferenceResp, error) {
+	if req.SeniorId == "" {
+		return nil, consts.ErrInvalidParam
+	}
+	if !adaptor.ExtractAccess(ctx).Senior(req.SeniorId) {
+		return nil, consts.ErrForbidden
+	}
+	if err := s.Store.SaveRecordConsent(ctx, req.SeniorId, req.RecordConsent); err != nil {
+		return nil, err
+	}
+	return s.GetPreference(ctx, &cmd.GetPreferenceReq{SeniorId: req.SeniorId})
+}
+
+func toPreferenceResp(p *preference.Preference) *cmd.PreferenceResp {
+	return &cmd.PreferenceResp{
+		Code: 0,
+		Msg:  "success",
+		Preference: &cmd.Preference{
+			Settings: cmd.Settings{
+				Speaker:    p.Speaker,
+				SpeechRate: p.SpeechRate,
+				Volume:     p.Volume,
+				TextSize:   p.TextSize,
+			},
+			RecordConsent: p.RecordConsent,
+		},
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/wire"
+	"github.com/xh-polaris/psych-senior/biz/adaptor"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/biz/domain/recording"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+)
+
+type IRecordingService interface {
+	GetRecording(ctx context.Context, req *cmd.GetRecordingReq) (*recording.Playback, error)
+	ListRecording(ctx context.Context, req *cmd.ListRecordingReq) (*cmd.ListRecordingResp, error)
+}
+
+type RecordingService struct {
+	Recorder *recording.Recorder
+}
+
+var RecordingServiceSet = wire.NewSet(
+	wire.Struct(new(RecordingService), "*"),
+	wire.Bind(new(IRecordingService), new(*RecordingService)),
+)
+
+// GetRecording 获取一方在一次会话或一轮中的录音, 仅限可以访问该会话老人的用户
+func (s *RecordingService) GetRecording(ctx context.Context, req *cmd.GetRecordingReq) (*recording.Playback, error) {
+	if req.SessionId == "" || (req.Side != recording.SideUser && req.Side != recording.SideAi) {
+		return nil, consts.ErrInvalidParam
+	}
+	if err := s.authorize(ctx, req.SessionId); err != nil {
+		return nil, err
+	}
+	p, err := s.Recorder.Open(ctx, req.SessionId, req.Side, turnOf(req.Turn))
+	if errors.Is(err, recording.ErrNotFound) {
+		return nil, consts.ErrNoRecording
+	}
+	return p, err
+}
+
+// ListRecording 按时间顺序列出一次会话的录音, 仅限可以访问该会话老人的用户
+func (s *RecordingService) ListRecording(ctx context.Context, req *cmd.ListRecordingReq) (*cmd.ListRecordingResp, error) {
+	if req.SessionId == "" || (req.Side != "" && req.Side != recording.SideUser && req.Side != recording.SideAi) {
+		return nil, consts.ErrInvalidParam
+	}
+	if err := s.authorize(ctx, req.SessionId); err != nil {
+		return nil, err
+	}
+	recs, err := s.Recorder.List(ctx, req.SessionId, req.Side, turnOf(req.Turn))
+	if err != nil {
+		return nil, err
+	}
+	resp := &cmd.ListRecordingResp{Code: 0, Msg: "success", Recordings: make([]*cmd.Recording, 0, len(recs))}
+	for _, r := range recs {
+		resp.Recordings = append(resp.Recordings, &cmd.Recording{
+			Side:   r.Side,
+			Turn:   r.Turn,
+			Format: r.Format,
+			Size:   r.Size,
+			Start:  r.Start.UnixMilli(),
+			End:    r.End.UnixMilli(),
+		})
+	}
+	return resp, nil
+}
+
+// authorize 录音归属会话的老人, 只有可以访问该老人的用户才能查看
+func (s *RecordingService) authorize(ctx context.Context, sessionId string) error {
+	recs, err := s.Recorder.List(ctx, sessionId, "", -1)
+	if err != nil {
+		return err
+	}
+	if len(recs) == 0 {
+		return consts.ErrNoRecording
+	}
+	if !adaptor.ExtractAccess(ctx).Senior(recs[0].SeniorId) {
+		return consts.ErrForbidden
+	}
+	return nil
+}
+
+// turnOf 未指定轮次时包含所有轮次
+func turnOf(turn *int64) int64 {
+	if turn == nil {
+		return -1
+	}
+	return *turn
+}
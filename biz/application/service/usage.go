@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/wire"
+	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
+	"github.com/xh-polaris/psych-senior/biz/application/dto"
+	"github.com/xh-polaris/psych-senior/biz/domain/usage"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+)
+
+type IUsageService interface {
+	GetUsage(ctx context.Context, req *cmd.GetUsageReq) (*cmd.GetUsageResp, error)
+}
+
+type UsageService struct {
+	Recorder *usage.Recorder
+}
+
+var UsageServiceSet = wire.NewSet(
+	wire.Struct(new(UsageService), "*"),
+	wire.Bind(new(IUsageService), new(*UsageService)),
+)
+
+func (s *UsageService) GetUsage(ctx context.Context, req *cmd.GetUsageReq) (*cmd.GetUsageResp, error) {
+	switch req.Scope {
+	case usage.ScopeApp, usage.ScopeInstitution, usage.ScopeSenior:
+	default:
+		return nil, consts.ErrInvalidParam
+	}
+	if req.Id == "" {
+		return nil, consts.ErrInvalidParam
+	}
+	now := time.Now()
+	if req.From == "" {
+		req.From = now.AddDate(0, 0, 1-now.Day()).Format(usage.DateLayout)
+	}
+	if req.To == "" {
+		req.To = now.Format(usage.DateLayout)
+	}
+	if _, err := time.Parse(usage.DateLayout, req.From); err != nil {
+		return nil, consts.ErrInvalidParam
+	}
+	if _, err := time.Parse(usage.DateLayout, req.To); err != nil {
+		return nil, consts.ErrInvalidParam
+	}
+
+	day, month, err := s.Recorder.Current(ctx, req.Scope, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.Recorder.History(ctx, req.Scope, req.Id, req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+	daily := make([]*cmd.DailyUsage, 0, len(data))
+	for _, d := range data {
+		daily = append(daily, &cmd.DailyUsage{
+			Date: d.Date,
+			UsageStat: cmd.UsageStat{
+				InputTokens:  d.InputTokens,
+				OutputTokens: d.OutputTokens,
+				TtsChars:     d.TtsChars,
+				AsrMillis:    d.AsrMillis,
+			},
+		})
+	}
+	rule := s.Recorder.Limit(req.Scope, req.Id)
+	return &cmd.GetUsageResp{
+		Code:  0,
+		Msg:   "success",
+		Today: toUsageStat(day),
+		Month: toUsageStat(month),
+		Daily: daily,
+		Quota: &cmd.Quota{
+			Daily:   toQuotaLimit(rule.Daily),
+			Monthly: toQuotaLimit(rule.Monthly),
+		},
+	}, nil
+}
+
+func toUsageStat(u *dto.UsageStat) *cmd.UsageStat {
+	return &cmd.UsageStat{
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+		TtsChars:     u.TtsChars,
+		AsrMillis:    u.AsrMillis,
+	}
+}
+
+func toQuotaLimit(l config.QuotaLimit) *cmd.QuotaLimit {
+	return &cmd.QuotaLimit{
+		Tokens:     l.Tokens,
+		TtsChars:   l.TtsChars,
+		AsrSeconds: l.AsrSeconds,
+	}
+}
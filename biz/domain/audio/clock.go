@@ -0,0 +1,48 @@
+package audio
+
+import "time"
+
+// Clock 统计已下发音频的时长, 用于字幕与音频对齐
+type Clock struct {
+	// bytesPerSecond pcm每秒的字节数, ogg时为0
+	bytesPerSecond int64
+	bytes          int64
+	ogg            *OggReader
+	opus           time.Duration
+}
+
+// NewPCMClock 统计pcm16音频的时长
+func NewPCMClock(sampleRate, channels int) *Clock {
+	return &Clock{bytesPerSecond: int64(sampleRate * channels * 2)}
+}
+
+// NewOggClock 统计ogg封装的opus音频的时长
+func NewOggClock() *Clock {
+	return &Clock{ogg: NewOggReader()}
+}
+
+// Advance 计入一块音频, c为nil时忽略
+func (c *Clock) Advance(data []byte) {
+	if c == nil {
+		return
+	}
+	if c.ogg == nil {
+		c.bytes += int64(len(data))
+		return
+	}
+	packets, _ := c.ogg.Write(data)
+	for _, p := range packets {
+		c.opus += PacketDuration(p)
+	}
+}
+
+// Position 已计入音频的总时长
+func (c *Clock) Position() time.Duration {
+	if c == nil {
+		return 0
+	}
+	if c.ogg != nil {
+		return c.opus
+	}
+	return time.Duration(c.bytes * int64(time.Second) / c.bytesPerSecond)
+}
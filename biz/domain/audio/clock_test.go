@@ -0,0 +1,32 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	// 16kHz单声道pcm16, 分块写入不应累计误差
+	c := NewPCMClock(16000, 1)
+	for i := 0; i < 3; i++ {
+		c.Advance(make([]byte, 3333))
+	}
+	if got, want := c.Position(), time.Duration(9999*int64(time.Second)/32000); got != want {
+		t.Errorf("pcm position = %v, want %v", got, want)
+	}
+
+	w := NewOggWriter(1, 1, 48000)
+	o := NewOggClock()
+	o.Advance(w.Header())
+	o.Advance(w.Page([]byte{0xf8, 1}))
+	o.Advance(w.Page([]byte{0xf9, 1}))
+	if got := o.Position(); got != 60*time.Millisecond {
+		t.Errorf("ogg position = %v", got)
+	}
+
+	var nilClock *Clock
+	nilClock.Advance([]byte{1})
+	if nilClock.Position() != 0 {
+		t.Error("nil clock should stay at 0")
+	}
+}
@@ -0,0 +1,151 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Converter 流式的音频格式转换, 依次完成解码, 声道转换, 重采样与编码
+// 分块输入时保留不足一帧的字节与重采样的状态, 不能并发使用
+type Converter struct {
+	src, dst Format
+	// header 输入为wav时未解析完头部前缓存的数据
+	header []byte
+	// ready 输入的wav头部已经解析
+	ready bool
+	// started 输出为wav时是否已经写入头部
+	started bool
+	// rest 不足一帧的剩余字节
+	rest      []byte
+	resampler *Resampler
+}
+
+// NewConverter 创建从src到dst格式的转换
+// src为wav时以头部声明的采样率与声道数为准
+func NewConverter(src, dst Format) (*Converter, error) {
+	if err := src.Validate(); err != nil {
+		return nil, err
+	}
+	if err := dst.Validate(); err != nil {
+		return nil, err
+	}
+	c := &Converter{src: src, dst: dst}
+	if src.Encoding != WAV {
+		c.init(src)
+	}
+	return c, nil
+}
+
+// init 确定输入格式后初始化重采样
+func (c *Converter) init(src Format) {
+	c.src, c.ready = src, true
+	if src.SampleRate != c.dst.SampleRate {
+		c.resampler = NewResampler(src.SampleRate, c.dst.SampleRate, c.dst.Channels)
+	}
+}
+
+// passthrough 输入与输出格式一致, 不需要转换, wav的数据部分与pcm16一致
+func (c *Converter) passthrough() bool {
+	dst := c.dst
+	if dst.Encoding == WAV {
+		dst.Encoding = PCM16
+	}
+	return c.src == dst
+}
+
+// Convert 转换一块音频, 数据不足时可能返回空
+// c为nil时原样返回
+func (c *Converter) Convert(data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	if !c.ready {
+		c.header = append(c.header, data...)
+		f, pos, err := parseWavHeader(c.header)
+		if err != nil || pos == 0 {
+			return nil, err
+		}
+		if err = f.Validate(); err != nil {
+			return nil, err
+		}
+		data, c.header = c.header[pos:], nil
+		c.init(f)
+	}
+	var out []byte
+	if c.dst.Encoding == WAV && !c.started {
+		c.started = true
+		out = wavHeader(c.dst.SampleRate, c.dst.Channels)
+	}
+	if c.passthrough() {
+		return append(out, data...), nil
+	}
+
+	// 只处理完整的帧, 剩余的字节留到下一块
+	if len(c.rest) > 0 {
+		data = append(c.rest, data...)
+		c.rest = nil
+	}
+	frame := c.src.frameSize()
+	n := len(data) / frame * frame
+	if n < len(data) {
+		c.rest = append([]byte(nil), data[n:]...)
+	}
+	samples := c.remix(c.decode(data[:n]))
+	if c.resampler != nil {
+		samples = c.resampler.Process(samples)
+	}
+	return c.encode(out, samples), nil
+}
+
+// decode 解码为-1~1的浮点采样
+func (c *Converter) decode(data []byte) []float32 {
+	size := c.src.sampleSize()
+	samples := make([]float32, len(data)/size)
+	for i := range samples {
+		b := data[i*size:]
+		if size == 4 {
+			samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(b))
+		} else {
+			samples[i] = float32(int16(binary.LittleEndian.Uint16(b))) / 32768
+		}
+	}
+	return samples
+}
+
+// remix 转换声道数, 多声道转单声道时取平均, 单声道转多声道时复制
+func (c *Converter) remix(in []float32) []float32 {
+	from, to := c.src.Channels, c.dst.Channels
+	if from == to {
+		return in
+	}
+	frames := len(in) / from
+	out := make([]float32, frames*to)
+	for i := 0; i < frames; i++ {
+		var sum float32
+		for j := 0; j < from; j++ {
+			sum += in[i*from+j]
+		}
+		v := sum / float32(from)
+		for j := 0; j < to; j++ {
+			out[i*to+j] = v
+		}
+	}
+	return out
+}
+
+// encode 编码为目标格式并追加到out
+func (c *Converter) encode(out []byte, samples []float32) []byte {
+	size := c.dst.sampleSize()
+	start := len(out)
+	out = append(out, make([]byte, len(samples)*size)...)
+	for i, v := range samples {
+		b := out[start+i*size:]
+		if size == 4 {
+			binary.LittleEndian.PutUint32(b, math.Float32bits(v))
+			continue
+		}
+		v = max(-1, min(1, v))
+		binary.LittleEndian.PutUint16(b, uint16(int16(v*math.MaxInt16)))
+	}
+	return out
+}
@@ -0,0 +1,139 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// sine 生成单声道pcm16的正弦波
+func sine(freq float64, rate, n int) []byte {
+	out := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		v := 0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(v*math.MaxInt16)))
+	}
+	return out
+}
+
+// peak pcm16音频的最大幅度
+func peak(data []byte) float64 {
+	var p float64
+	for i := 0; i+1 < len(data); i += 2 {
+		v := math.Abs(float64(int16(binary.LittleEndian.Uint16(data[i:]))) / 32768)
+		p = max(p, v)
+	}
+	return p
+}
+
+func TestResampleLength(t *testing.T) {
+	cases := []struct{ from, to int }{{48000, 16000}, {24000, 16000}, {24000, 48000}, {16000, 44100}}
+	for _, c := range cases {
+		conv, err := NewConverter(Format{PCM16, c.from, 1}, Format{PCM16, c.to, 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 分成奇数长度的块输入, 检查块之间的状态
+		in := sine(440, c.from, c.from)
+		var out []byte
+		for len(in) > 0 {
+			n := min(len(in), 997)
+			chunk, err := conv.Convert(in[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, chunk...)
+			in = in[n:]
+		}
+		if got := len(out) / 2; math.Abs(float64(got-c.to)) > 2 {
+			t.Errorf("%d -> %d: got %d samples", c.from, c.to, got)
+		}
+		if p := peak(out[len(out)/2:]); math.Abs(p-0.5) > 0.02 {
+			t.Errorf("%d -> %d: peak %.3f", c.from, c.to, p)
+		}
+	}
+}
+
+func TestResampleAntiAlias(t *testing.T) {
+	// 12kHz超过16k采样率的奈奎斯特频率, 降采样后应被滤除
+	conv, _ := NewConverter(Format{PCM16, 48000, 1}, Format{PCM16, 16000, 1})
+	out, _ := conv.Convert(sine(12000, 48000, 48000))
+	if p := peak(out[len(out)/2:]); p > 0.05 {
+		t.Errorf("aliased peak %.3f", p)
+	}
+}
+
+func TestConvertEncodingAndChannels(t *testing.T) {
+	// 双声道float32转单声道pcm16
+	in := make([]byte, 0, 16)
+	for _, v := range []float32{0.5, 0.5, -0.5, -0.5} {
+		in = binary.LittleEndian.AppendUint32(in, math.Float32bits(v))
+	}
+	conv, err := NewConverter(Format{Float32, 16000, 2}, Format{PCM16, 16000, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按不完整的帧输入
+	a, _ := conv.Convert(in[:5])
+	b, _ := conv.Convert(in[5:])
+	out := append(a, b...)
+	if len(out) != 4 {
+		t.Fatalf("got %d bytes", len(out))
+	}
+	if v := int16(binary.LittleEndian.Uint16(out)); v != math.MaxInt16/2 {
+		t.Errorf("first sample %d", v)
+	}
+	if v := int16(binary.LittleEndian.Uint16(out[2:])); v != -math.MaxInt16/2 {
+		t.Errorf("second sample %d", v)
+	}
+}
+
+func TestConvertWav(t *testing.T) {
+	src := append(wavHeader(48000, 2), make([]byte, 48000*4)...)
+	conv, err := NewConverter(Format{WAV, 16000, 1}, Format{PCM16, 16000, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 头部分两块到达
+	a, err := conv.Convert(src[:20])
+	if err != nil || len(a) != 0 {
+		t.Fatalf("got %d bytes, err %v", len(a), err)
+	}
+	b, err := conv.Convert(src[20:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(b) / 2; math.Abs(float64(got-16000)) > 2 {
+		t.Errorf("got %d samples", got)
+	}
+
+	bad, _ := NewConverter(Format{WAV, 16000, 1}, Format{PCM16, 16000, 1})
+	if _, err = bad.Convert([]byte("not a riff header")); err == nil {
+		t.Error("expect error for invalid wav")
+	}
+}
+
+func TestConvertWavOutput(t *testing.T) {
+	conv, _ := NewConverter(Format{PCM16, 24000, 1}, Format{WAV, 24000, 1})
+	data := sine(440, 24000, 100)
+	first, _ := conv.Convert(data)
+	second, _ := conv.Convert(data)
+	if len(first) != 44+len(data) || string(first[:4]) != "RIFF" {
+		t.Errorf("first chunk should carry wav header")
+	}
+	if len(second) != len(data) {
+		t.Errorf("header should be written once")
+	}
+}
+
+func TestFormatValidate(t *testing.T) {
+	def := Format{PCM16, 16000, 1}
+	if f := (Format{SampleRate: 48000}).Or(def); f != (Format{PCM16, 48000, 1}) {
+		t.Errorf("Or = %v", f)
+	}
+	for _, f := range []Format{{"mp3", 16000, 1}, {PCM16, 4000, 1}, {PCM16, 16000, 6}} {
+		if f.Validate() == nil {
+			t.Errorf("%v should be unsupported", f)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 前端与第三方服务的音频格式各不相同, 这里统一转换
+// 目前只处理未压缩的音频, 全部为小端字节序
+
+// 支持的编码
+const (
+	// PCM16 16位有符号整数
+	PCM16 = "pcm16"
+	// Float32 32位浮点数, 取值-1~1
+	Float32 = "float32"
+	// WAV 带RIFF头的pcm16或float32, 作为输入时以头部声明的格式为准
+	WAV = "wav"
+)
+
+// 支持的采样率与声道数范围
+const (
+	minSampleRate = 8000
+	maxSampleRate = 96000
+	maxChannels   = 2
+)
+
+// ErrUnsupported 不支持的音频格式
+var ErrUnsupported = errors.New("unsupported audio format")
+
+// Format 音频格式
+type Format struct {
+	Encoding   string
+	SampleRate int
+	Channels   int
+}
+
+// Or 为空的项使用def中的值
+func (f Format) Or(def Format) Format {
+	if f.Encoding == "" {
+		f.Encoding = def.Encoding
+	}
+	if f.SampleRate == 0 {
+		f.SampleRate = def.SampleRate
+	}
+	if f.Channels == 0 {
+		f.Channels = def.Channels
+	}
+	return f
+}
+
+// Validate 检查格式是否支持
+func (f Format) Validate() error {
+	switch f.Encoding {
+	case PCM16, Float32, WAV:
+	default:
+		return fmt.Errorf("%w: encoding %q", ErrUnsupported, f.Encoding)
+	}
+	if f.SampleRate < minSampleRate || f.SampleRate > maxSampleRate {
+		return fmt.Errorf("%w: sample rate %d", ErrUnsupported, f.SampleRate)
+	}
+	if f.Channels < 1 || f.Channels > maxChannels {
+		return fmt.Errorf("%w: channels %d", ErrUnsupported, f.Channels)
+	}
+	return nil
+}
+
+// sampleSize 每个采样的字节数, wav的数据部分按pcm16处理
+func (f Format) sampleSize() int {
+	if f.Encoding == Float32 {
+		return 4
+	}
+	return 2
+}
+
+// frameSize 每帧(所有声道各一个采样)的字节数
+func (f Format) frameSize() int {
+	return f.sampleSize() * f.Channels
+}
+
+// String 便于日志输出
+func (f Format) String() string {
+	return fmt.Sprintf("%s/%dHz/%dch", f.Encoding, f.SampleRate, f.Channels)
+}
@@ -0,0 +1,186 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Ogg封装的Opus, 只做封装与解封装, 编解码由前端与第三方服务完成
+
+// 页头部的固定部分与标记
+const (
+	oggHeaderSize = 27
+	oggCapture    = "OggS"
+	oggBOS        = 0x02
+	oggContinued  = 0x01
+	// maxOggPage 单页的最大长度, 解析时超过该长度仍不完整视为格式错误
+	maxOggPage = oggHeaderSize + 255 + 255*255
+)
+
+// opusRate Opus的granule position固定以48kHz计数
+const opusRate = 48000
+
+var oggCRC = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return
+}()
+
+func oggChecksum(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRC[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// OggReader 流式解析Ogg页, 返回其中的Opus数据包, 跳过OpusHead与OpusTags头部包
+// 支持多个Ogg流首尾相接
+type OggReader struct {
+	buf []byte
+	// packet 跨页未完成的数据包
+	packet []byte
+	// head 最近读到的OpusHead包
+	head []byte
+}
+
+// NewOggReader 创建Ogg解析
+func NewOggReader() *OggReader {
+	return &OggReader{}
+}
+
+// Write 写入一块数据, 返回其中完整的数据包
+// 数据损坏时返回错误, 并跳到下一个页的开头继续解析
+func (r *OggReader) Write(data []byte) (packets [][]byte, err error) {
+	r.buf = append(r.buf, data...)
+	for len(r.buf) >= oggHeaderSize {
+		if string(r.buf[:4]) != oggCapture {
+			err = fmt.Errorf("%w: missing ogg capture pattern", ErrUnsupported)
+			r.packet = nil
+			if i := bytes.Index(r.buf[1:], []byte(oggCapture)); i >= 0 {
+				r.buf = r.buf[i+1:]
+				continue
+			}
+			r.buf = r.buf[len(r.buf)-len(oggCapture)+1:]
+			break
+		}
+		n := int(r.buf[26])
+		if len(r.buf) < oggHeaderSize+n {
+			break
+		}
+		lacing := r.buf[oggHeaderSize : oggHeaderSize+n]
+		size := oggHeaderSize + n
+		for _, l := range lacing {
+			size += int(l)
+		}
+		if len(r.buf) < size {
+			break
+		}
+		if r.buf[5]&oggContinued == 0 {
+			r.packet = nil
+		}
+		body := r.buf[oggHeaderSize+n : size]
+		for _, l := range lacing {
+			r.packet = append(r.packet, body[:l]...)
+			body = body[l:]
+			if l < 255 {
+				if bytes.HasPrefix(r.packet, []byte("OpusHead")) {
+					r.head = r.packet
+				} else if !isOpusHeader(r.packet) {
+					packets = append(packets, r.packet)
+				}
+				r.packet = nil
+			}
+		}
+		r.buf = r.buf[size:]
+	}
+	if len(r.buf) > maxOggPage {
+		r.buf, r.packet = nil, nil
+		err = fmt.Errorf("%w: ogg page too long", ErrUnsupported)
+	}
+	return packets, err
+}
+
+// Head 最近读到的OpusHead中的声道数与编码前的采样率, 未读到时为单声道48kHz
+func (r *OggReader) Head() (channels, rate int) {
+	channels, rate = 1, opusRate
+	if len(r.head) < 16 {
+		return
+	}
+	if r.head[9] > 0 {
+		channels = int(r.head[9])
+	}
+	if v := binary.LittleEndian.Uint32(r.head[12:16]); v > 0 {
+		rate = int(v)
+	}
+	return
+}
+
+func isOpusHeader(packet []byte) bool {
+	return bytes.HasPrefix(packet, []byte("OpusHead")) || bytes.HasPrefix(packet, []byte("OpusTags"))
+}
+
+// OggWriter 将Opus数据包逐个封装为Ogg页
+type OggWriter struct {
+	serial   uint32
+	seq      uint32
+	granule  uint64
+	channels int
+	rate     int
+}
+
+// NewOggWriter 创建Ogg封装, channels与rate写入OpusHead, rate为编码前的采样率
+func NewOggWriter(serial uint32, channels, rate int) *OggWriter {
+	return &OggWriter{serial: serial, channels: channels, rate: rate}
+}
+
+// Header OpusHead与OpusTags两个头部页
+func (w *OggWriter) Header() []byte {
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1
+	head[9] = byte(w.channels)
+	binary.LittleEndian.PutUint32(head[12:], uint32(w.rate))
+	tags := []byte("OpusTags")
+	tags = binary.LittleEndian.AppendUint32(tags, 0)
+	tags = binary.LittleEndian.AppendUint32(tags, 0)
+	return append(w.page(head, oggBOS, 0), w.page(tags, 0, 0)...)
+}
+
+// Page 将一个数据包封装为一页, 数据包不能超过MaxOggPacket
+func (w *OggWriter) Page(packet []byte) []byte {
+	w.granule += uint64(PacketDuration(packet).Seconds() * opusRate)
+	return w.page(packet, 0, w.granule)
+}
+
+func (w *OggWriter) page(packet []byte, flag byte, granule uint64) []byte {
+	var lacing []byte
+	for n := len(packet); ; n -= 255 {
+		if n < 255 {
+			lacing = append(lacing, byte(n))
+			break
+		}
+		lacing = append(lacing, 255)
+	}
+	page := make([]byte, oggHeaderSize, oggHeaderSize+len(lacing)+len(packet))
+	copy(page, oggCapture)
+	page[5] = flag
+	binary.LittleEndian.PutUint64(page[6:], granule)
+	binary.LittleEndian.PutUint32(page[14:], w.serial)
+	binary.LittleEndian.PutUint32(page[18:], w.seq)
+	page[26] = byte(len(lacing))
+	page = append(append(page, lacing...), packet...)
+	binary.LittleEndian.PutUint32(page[22:], oggChecksum(page))
+	w.seq++
+	return page
+}
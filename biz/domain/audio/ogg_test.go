@@ -0,0 +1,125 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOggRoundTrip(t *testing.T) {
+	w := NewOggWriter(1, 1, 48000)
+	// 20ms的CELT单帧包, 以及一个超过255字节需要多个lacing值的包
+	packets := [][]byte{
+		append([]byte{0xf8}, bytes.Repeat([]byte{1}, 100)...),
+		append([]byte{0xf8}, bytes.Repeat([]byte{2}, 600)...),
+		append([]byte{0xf8}, bytes.Repeat([]byte{3}, 254)...),
+	}
+	stream := w.Header()
+	for _, p := range packets {
+		stream = append(stream, w.Page(p)...)
+	}
+	// 再接一个新的流, 头部应被跳过
+	stream = append(stream, NewOggWriter(2, 2, 16000).Header()...)
+
+	r := NewOggReader()
+	var got [][]byte
+	for len(stream) > 0 {
+		n := min(len(stream), 37)
+		out, err := r.Write(stream[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, out...)
+		stream = stream[n:]
+	}
+	if len(got) != len(packets) {
+		t.Fatalf("got %d packets", len(got))
+	}
+	for i := range packets {
+		if !bytes.Equal(got[i], packets[i]) {
+			t.Errorf("packet %d mismatch", i)
+		}
+	}
+	if w.granule != 3*960 {
+		t.Errorf("granule = %d", w.granule)
+	}
+	if channels, rate := r.Head(); channels != 2 || rate != 16000 {
+		t.Errorf("head = %d %d", channels, rate)
+	}
+}
+
+func TestOggResync(t *testing.T) {
+	w := NewOggWriter(1, 1, 48000)
+	page := w.Page([]byte{0xf8, 9})
+	r := NewOggReader()
+	packets, err := r.Write(append([]byte("garbage"), page...))
+	if err == nil {
+		t.Error("expect error for corrupt data")
+	}
+	if len(packets) != 1 || !bytes.Equal(packets[0], []byte{0xf8, 9}) {
+		t.Errorf("packets = %v", packets)
+	}
+}
+
+func TestOggChecksum(t *testing.T) {
+	// 校验和字段置0后重新计算应与写入的一致
+	page := NewOggWriter(7, 1, 48000).Page([]byte{0xf8, 1, 2, 3})
+	sum := append([]byte(nil), page[22:26]...)
+	copy(page[22:26], []byte{0, 0, 0, 0})
+	want := oggChecksum(page)
+	if got := uint32(sum[0]) | uint32(sum[1])<<8 | uint32(sum[2])<<16 | uint32(sum[3])<<24; got != want {
+		t.Errorf("checksum = %x, want %x", got, want)
+	}
+	if oggChecksum([]byte("123456789")) != 0x89a1897f {
+		t.Error("crc32 with polynomial 0x04c11db7 mismatch")
+	}
+}
+
+func TestPacketDuration(t *testing.T) {
+	cases := []struct {
+		packet []byte
+		want   time.Duration
+	}{
+		{[]byte{0xf8}, 20 * time.Millisecond},         // CELT 20ms, 单帧
+		{[]byte{0xf9}, 40 * time.Millisecond},         // CELT 20ms, 两帧
+		{[]byte{0x08}, 20 * time.Millisecond},         // SILK 20ms
+		{[]byte{0x18}, 60 * time.Millisecond},         // SILK 60ms
+		{[]byte{0xe3, 0x03}, 7500 * time.Microsecond}, // CELT 2.5ms, 三帧
+		{[]byte{0x60}, 10 * time.Millisecond},         // Hybrid 10ms
+		{nil, 0},
+	}
+	for _, c := range cases {
+		if got := PacketDuration(c.packet); got != c.want {
+			t.Errorf("PacketDuration(%x) = %v, want %v", c.packet, got, c.want)
+		}
+	}
+}
+
+func TestUplinkOversized(t *testing.T) {
+	u, err := NewUplink(Format{Encoding: Opus}, Format{Encoding: PCM16, SampleRate: 16000, Channels: 1}, 1)
+	if err != nil || !u.Ogg() {
+		t.Fatalf("uplink %v, %v", u, err)
+	}
+	r := NewOggReader()
+	if _, err = r.Write(u.Header()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 最大的数据包仍能封装为一页并被正确解析
+	largest := append([]byte{0xf8}, bytes.Repeat([]byte{1}, MaxOggPacket-1)...)
+	pages, d, err := u.Encode(largest)
+	if err != nil || len(pages) != 1 || d != 20*time.Millisecond {
+		t.Fatalf("got %d pages, %v, %v", len(pages), d, err)
+	}
+	packets, err := r.Write(pages[0])
+	if err != nil || len(packets) != 1 || !bytes.Equal(packets[0], largest) {
+		t.Fatalf("got %d packets, %v", len(packets), err)
+	}
+
+	// 超过一页的数据包被丢弃
+	pages, _, err = u.Encode(append(largest, 0))
+	if !errors.Is(err, ErrDropped) || len(pages) != 0 {
+		t.Fatalf("got %d pages, %v", len(pages), err)
+	}
+}
@@ -0,0 +1,52 @@
+package audio
+
+import "time"
+
+// 压缩的编码, 不做转换, 由第三方服务直接处理
+const (
+	// Ogg Ogg封装的Opus
+	Ogg = "ogg"
+	// Opus 未封装的Opus数据包, 每个ws消息一个数据包
+	Opus = "opus"
+)
+
+// IsOpus 是否为Opus编码
+func IsOpus(encoding string) bool {
+	return encoding == Ogg || encoding == Opus
+}
+
+// Opus各模式的帧长, 单位为0.1毫秒, 由TOC字节的config决定
+var (
+	silkFrames   = [4]int{100, 200, 400, 600}
+	hybridFrames = [2]int{100, 200}
+	celtFrames   = [4]int{25, 50, 100, 200}
+)
+
+// PacketDuration 根据TOC字节计算一个Opus数据包的时长
+func PacketDuration(packet []byte) time.Duration {
+	if len(packet) == 0 {
+		return 0
+	}
+	toc := packet[0]
+	config := int(toc >> 3)
+	var frame int
+	switch {
+	case config < 12:
+		frame = silkFrames[config%4]
+	case config < 16:
+		frame = hybridFrames[config%2]
+	default:
+		frame = celtFrames[config%4]
+	}
+	count := 1
+	switch toc & 0x03 {
+	case 1, 2:
+		count = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		count = int(packet[1] & 0x3f)
+	}
+	return time.Duration(frame*count) * 100 * time.Microsecond
+}
@@ -0,0 +1,100 @@
+package audio
+
+import "math"
+
+// cutoff 降采样前低通滤波的截止频率, 相对于目标采样率
+const cutoff = 0.45
+
+// Resampler 流式重采样, 输入输出均为交错排列的多声道采样
+// 使用线性插值, 降采样前经过低通滤波以减少混叠, 分块输入时保留块之间的状态
+type Resampler struct {
+	channels int
+	// step 每个输出采样对应的输入采样数
+	step float64
+	// pos 下一个输出采样在当前块中的位置, 为负数时位于上一块的最后一帧与当前块之间
+	pos float64
+	// prev 上一块的最后一帧
+	prev []float32
+	// filters 降采样时每个声道的低通滤波器
+	filters []*lowpass
+}
+
+// NewResampler 创建从from到to采样率的重采样
+func NewResampler(from, to, channels int) *Resampler {
+	r := &Resampler{
+		channels: channels,
+		step:     float64(from) / float64(to),
+		prev:     make([]float32, channels),
+	}
+	if from > to {
+		for i := 0; i < channels; i++ {
+			r.filters = append(r.filters, newLowpass(cutoff*float64(to), float64(from)))
+		}
+	}
+	return r
+}
+
+// Process 重采样一块音频, 长度应为声道数的整数倍
+func (r *Resampler) Process(in []float32) []float32 {
+	n := len(in) / r.channels
+	if n == 0 {
+		return nil
+	}
+	for i, f := range r.filters {
+		for j := i; j < len(in); j += r.channels {
+			in[j] = f.process(in[j])
+		}
+	}
+	out := make([]float32, 0, int(float64(n)/r.step+1)*r.channels)
+	for r.pos < float64(n-1) {
+		i := int(math.Floor(r.pos))
+		frac := float32(r.pos - float64(i))
+		for c := 0; c < r.channels; c++ {
+			var a float32
+			if i < 0 {
+				a = r.prev[c]
+			} else {
+				a = in[i*r.channels+c]
+			}
+			b := in[(i+1)*r.channels+c]
+			out = append(out, a+(b-a)*frac)
+		}
+		r.pos += r.step
+	}
+	r.pos -= float64(n)
+	copy(r.prev, in[(n-1)*r.channels:n*r.channels])
+	return out
+}
+
+// lowpass 二阶巴特沃斯低通滤波, 两级级联
+type lowpass struct {
+	b0, b1, b2, a1, a2 float64
+	state              [2][4]float64
+}
+
+func newLowpass(freq, rate float64) *lowpass {
+	w := 2 * math.Pi * freq / rate
+	// Q取√2/2, alpha = sin(w)/(2Q)
+	alpha := math.Sin(w) / math.Sqrt2
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	return &lowpass{
+		b0: (1 - cos) / 2 / a0,
+		b1: (1 - cos) / a0,
+		b2: (1 - cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (l *lowpass) process(x float32) float32 {
+	v := float64(x)
+	for i := range l.state {
+		s := &l.state[i]
+		y := l.b0*v + l.b1*s[0] + l.b2*s[1] - l.a1*s[2] - l.a2*s[3]
+		s[1], s[0] = s[0], v
+		s[3], s[2] = s[2], y
+		v = y
+	}
+	return float32(v)
+}
@@ -0,0 +1,93 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxOggPacket 一页能封装的最大数据包, 一页最多255个lacing值且最后一个必须小于255
+const MaxOggPacket = 255*255 - 1
+
+// ErrDropped 部分音频无法处理被丢弃, 不影响之后的音频
+var ErrDropped = errors.New("audio dropped")
+
+// Uplink 前端上传的音频到语音识别格式的转换, 按开始请求声明的格式协商
+// 未压缩的音频转换为识别要求的格式, Opus逐个数据包重新封装为Ogg页, 使每次发送都是完整的页, 断线重连后可以重发
+type Uplink struct {
+	dst Format
+	// input 未压缩音频的转换, 为nil时不转换
+	input *Converter
+	// ogg 上传Opus时的Ogg封装, 上传未压缩音频时为nil
+	ogg *OggWriter
+	// oggReader 上传Ogg封装的Opus时解析出数据包, 上传未封装的Opus时为nil
+	oggReader *OggReader
+}
+
+// NewUplink 创建从src到识别格式dst的转换, src为Opus时由第三方解码, 不转换为dst
+// Opus未声明编码前的采样率时使用48kHz
+func NewUplink(src, dst Format, serial uint32) (*Uplink, error) {
+	u := &Uplink{dst: dst}
+	if IsOpus(src.Encoding) {
+		rate := src.SampleRate
+		if rate == 0 {
+			rate = opusRate
+		}
+		u.ogg = NewOggWriter(serial, max(src.Channels, 1), rate)
+		if src.Encoding == Ogg {
+			u.oggReader = NewOggReader()
+		}
+		return u, nil
+	}
+	if src = src.Or(dst); src != dst {
+		var err error
+		if u.input, err = NewConverter(src, dst); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// Ogg 是否以Ogg封装的Opus发送识别
+func (u *Uplink) Ogg() bool {
+	return u.ogg != nil
+}
+
+// Header Ogg的头部页, 开始识别时首先发送, 不是Ogg时为nil
+func (u *Uplink) Header() []byte {
+	if u.ogg == nil {
+		return nil
+	}
+	return u.ogg.Header()
+}
+
+// Encode 转换一块前端的音频, 返回待发送的数据与音频时长
+// 损坏的Ogg页与超过一页的数据包被丢弃, 返回ErrDropped与其余可以发送的数据
+func (u *Uplink) Encode(data []byte) ([][]byte, time.Duration, error) {
+	if u.ogg == nil {
+		data, err := u.input.Convert(data)
+		if err != nil || len(data) == 0 {
+			return nil, 0, err
+		}
+		frames := len(data) / u.dst.frameSize()
+		return [][]byte{data}, time.Duration(frames) * time.Second / time.Duration(u.dst.SampleRate), nil
+	}
+	var err error
+	packets := [][]byte{data}
+	if u.oggReader != nil {
+		if packets, err = u.oggReader.Write(data); err != nil {
+			err = fmt.Errorf("%w: %w", ErrDropped, err)
+		}
+	}
+	var pages [][]byte
+	var d time.Duration
+	for _, p := range packets {
+		if len(p) > MaxOggPacket {
+			err = fmt.Errorf("%w: opus packet of %d bytes too long", ErrDropped, len(p))
+			continue
+		}
+		pages = append(pages, u.ogg.Page(p))
+		d += PacketDuration(p)
+	}
+	return pages, d, err
+}
@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// wav头部中的编码类型
+const (
+	wavPCM   = 1
+	wavFloat = 3
+)
+
+// maxWavHeader 解析wav头部时最多缓存的字节数, 超过仍未找到数据块时视为格式错误
+const maxWavHeader = 4096
+
+// wavSize 流式输出时未知的长度
+const wavSize = 0xFFFFFFFF
+
+// parseWavHeader 解析wav头部, 返回头部声明的格式与数据块开始的位置
+// 数据不足以解析出头部时返回的位置为0
+func parseWavHeader(data []byte) (Format, int, error) {
+	var f Format
+	if len(data) < 12 {
+		return f, 0, nil
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return f, 0, fmt.Errorf("%w: missing RIFF header", ErrUnsupported)
+	}
+	pos, hasFmt := 12, false
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		pos += 8
+		if id == "data" {
+			if !hasFmt {
+				return f, 0, fmt.Errorf("%w: missing fmt chunk", ErrUnsupported)
+			}
+			return f, pos, nil
+		}
+		if pos+size > len(data) {
+			break
+		}
+		if id == "fmt " {
+			if size < 16 {
+				return f, 0, fmt.Errorf("%w: invalid fmt chunk", ErrUnsupported)
+			}
+			chunk := data[pos : pos+size]
+			code := binary.LittleEndian.Uint16(chunk[0:2])
+			bits := binary.LittleEndian.Uint16(chunk[14:16])
+			switch {
+			case code == wavPCM && bits == 16:
+				f.Encoding = PCM16
+			case code == wavFloat && bits == 32:
+				f.Encoding = Float32
+			default:
+				return f, 0, fmt.Errorf("%w: wav encoding %d with %d bits", ErrUnsupported, code, bits)
+			}
+			f.Channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			f.SampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			hasFmt = true
+		}
+		// 块的长度为奇数时有一个填充字节
+		pos += size + size&1
+	}
+	if len(data) > maxWavHeader {
+		return f, 0, fmt.Errorf("%w: wav header too long", ErrUnsupported)
+	}
+	return f, 0, nil
+}
+
+// WavHeaderSize 固定的wav头部长度
+const WavHeaderSize = 44
+
+// wavHeader 流式输出的pcm16 wav头部, 长度未知
+func wavHeader(sampleRate, channels int) []byte {
+	return buildWavHeader(sampleRate, channels, wavSize, wavSize)
+}
+
+// WavHeader 数据长度为size字节的pcm16 wav头部, 用于保存完整的文件
+func WavHeader(sampleRate, channels int, size uint32) []byte {
+	return buildWavHeader(sampleRate, channels, WavHeaderSize-8+size, size)
+}
+
+func buildWavHeader(sampleRate, channels int, riff, data uint32) []byte {
+	var buf bytes.Buffer
+	frame := 2 * channels
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, riff)
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(wavPCM))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*frame))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(frame))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, data)
+	return buf.Bytes()
+}
@@ -3,19 +3,33 @@ package chat
 import (
 	"context"
 	"errors"
+	"github.com/google/uuid"
 	"github.com/hertz-contrib/websocket"
 	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-senior/biz/application/dto"
 	"github.com/xh-polaris/psych-senior/biz/domain"
+	"github.com/xh-polaris/psych-senior/biz/domain/audio"
+	"github.com/xh-polaris/psych-senior/biz/domain/emotion"
+	"github.com/xh-polaris/psych-senior/biz/domain/language"
 	"github.com/xh-polaris/psych-senior/biz/domain/model"
-	"github.com/xh-polaris/psych-senior/biz/domain/model/bailian"
-	"github.com/xh-polaris/psych-senior/biz/domain/model/volc"
+	"github.com/xh-polaris/psych-senior/biz/domain/model/failover"
+	"github.com/xh-polaris/psych-senior/biz/domain/phrase"
+	"github.com/xh-polaris/psych-senior/biz/domain/preference"
+	"github.com/xh-polaris/psych-senior/biz/domain/recording"
+	"github.com/xh-polaris/psych-senior/biz/domain/speech"
+	"github.com/xh-polaris/psych-senior/biz/domain/usage"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/mq"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/trace"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"io"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 // Engine 是处理一轮对话的核心对象
@@ -40,21 +54,57 @@ type Engine struct {
 	// ttsApp 是调用的语音合成大模型
 	ttsApp model.TtsApp
 
-	// tts是否流式 (是否双端流式, 若false则一句话发一次)
-	ttsStream bool
+	// output 合成音频到前端格式的转换, 为nil时不转换
+	output *audio.Converter
+	// packets 前端使用未封装的opus时, 从合成的ogg中拆出数据包逐个下发
+	packets *audio.OggReader
+	// audioMu 保证音频按顺序转换与写入
+	audioMu sync.Mutex
+
+	// captions 是否下发与音频对齐的字幕
+	captions bool
+	// clock 已下发合成音频的时长, 用于字幕对齐, 由audioMu保护
+	clock *audio.Clock
+	// sentenceStart 当前句子音频开始的位置, 由audioMu保护
+	sentenceStart time.Duration
+
+	// styler 根据情绪选择合成语音的风格
+	styler *emotion.Styler
+	// speaker 合成使用的音色, 用于选择风格
+	speaker string
+
+	// prefs 老人偏好的读取与保存
+	prefs *preference.Store
+	// prefMu 保护pref, 偏好可能在对话中被语音指令修改
+	prefMu sync.Mutex
+	// pref 老人的语音与显示偏好, 为nil时使用默认值
+	pref *preference.Preference
+
+	// normalizer 合成前的文本规范化, 只影响合成的文本, 不影响返回给前端的文本
+	normalizer *speech.Normalizer
+	// transcript 语音识别结果的规范化, 规范化后的文本发送给大模型
+	transcript *speech.Normalizer
+
+	// textOnly 所有语音合成后端均不可用, 降级为纯文字对话
+	textOnly atomic.Bool
+
+	// reconnecting 语音合成正在重连, 用于通知去重
+	reconnecting atomic.Bool
 
 	// sessionId 是本轮对话的唯一标记, 只有第一次调用时会写入, 应该不需要互斥锁
 	// 目前使用的是BaiLian提供的sessionId管理, 如果有更好的方式, 可以考虑自己实现
 	sessionId string
+	// chatSession 对话应用给出的会话id, 用于多轮对话, 开场白等固定话术先于大模型调用时与sessionId不同
+	chatSession string
 
 	// aiHistory 记录AI输出历史
-	aiHistory chan string
+	aiHistory chan *dto.ChatHistory
 
 	// userHistory 记录用户输入历史
-	userHistory chan string
+	userHistory chan *dto.ChatHistory
 
-	// outw ai的流式文本, 用于语音合成
-	outw chan string
+	// outw ai的流式文本, 经过分句后用于语音合成
+	outw chan ttsText
 
 	// outv 合成的流式语音
 	outv chan []byte
@@ -62,35 +112,99 @@ type Engine struct {
 	// stop 用于打断AI输出
 	stop chan bool
 
+	// workers 调用大模型的协程, 关闭通道前等待其结束
+	workers sync.WaitGroup
+
 	// startTime 开始对话时间
 	startTime time.Time
 
 	// provider 消息生产者
 	provider *mq.HistoryProducer
 
-	// round 对话轮数
-	round int
+	// round 对话轮数, 由Chat写入, 其他协程读取
+	round atomic.Int64
+
+	// lang 本轮对话使用的语言
+	lang string
+	// scripts 语言的固定话术
+	scripts language.Scripts
+	// lastAudio 最近一次下发音频的时间, 单位纳秒, 用于等待告别语播放完成
+	lastAudio atomic.Int64
+	// lastSend 最近一次送去合成的时间, 单位纳秒, 用于等待合成的音频下发完成
+	lastSend atomic.Int64
+
+	// chatProvider, ttsProvider 第三方服务标识, 用于指标统计
+	chatProvider string
+	ttsProvider  string
+
+	// promptVersion 对话应用的提示词版本, 随对话记录保存
+	promptVersion string
+
+	// started 是否完成初始化, 用于统计活跃对话数
+	started bool
+
+	// turnStart 本轮调用开始的时间, 单位纳秒
+	turnStart atomic.Int64
+
+	// waitAudio 本轮是否还未收到首个音频包
+	waitAudio atomic.Bool
+
+	// firstAudio 本轮首个音频包的耗时, 单位毫秒
+	firstAudio atomic.Int64
+
+	// span 本次会话的追踪span, 每轮对话是它的子span
+	span oteltrace.Span
+
+	// turnMu 保护当前轮次的追踪信息
+	turnMu sync.Mutex
+	// turnCtx 当前轮次的上下文, 携带轮次span
+	turnCtx context.Context
+	// audioSpan 当前轮次等待音频的span, 收到首个音频包时结束
+	audioSpan oteltrace.Span
+
+	// owner 用量归属的对象
+	owner usage.Owner
+	// recorder 用量统计与额度控制
+	recorder *usage.Recorder
+	// phrases 固定话术的音频缓存
+	phrases *phrase.Cache
+	// usageMu 保护会话用量
+	usageMu sync.Mutex
+	// stat 本次会话的累计用量, 结束时汇总保存
+	stat dto.UsageStat
+
+	// recordings 会话录音的保存
+	recordings *recording.Recorder
+	// track 回复的录音, 老人未同意录音时为nil
+	track *recording.Track
 }
 
 // NewEngine 初始化一个ChatEngine
 // 暂时先固定为BaiLian之后类型多再换成工厂方法
 func NewEngine(ctx context.Context, conn *websocket.Conn) *Engine {
-	ctx, cancel := context.WithCancel(context.Background())
+	// 会话的生命周期长于协议升级请求, 只保留追踪信息
+	ctx, span := trace.Start(trace.Detach(ctx), "chat.session")
+	ctx, cancel := context.WithCancel(ctx)
 	e := &Engine{
 		ctx:    ctx,
 		cancel: cancel,
+		span:   span,
 		ws:     domain.NewWsHelper(conn),
 		rs:     domain.GetRedisHelper(),
 		//chatApp: bailian.NewBLChatApp(c.BaiLianChat.AppId, c.BaiLianChat.ApiKey),
 		//ttsApp:      volc.NewVcTtsApp(c.VolcTts.AppKey, c.VolcTts.AccessKey, c.VolcTts.Speaker, c.VolcTts.ResourceId, c.VolcTts.Url),
-		aiHistory:   make(chan string, 10),
-		userHistory: make(chan string, 10),
-		outw:        make(chan string, 50),
+		aiHistory:   make(chan *dto.ChatHistory, 10),
+		userHistory: make(chan *dto.ChatHistory, 10),
+		outw:        make(chan ttsText, 50),
 		outv:        make(chan []byte, 50),
 		stop:        make(chan bool),
 		startTime:   time.Now(),
 		provider:    mq.GetHistoryProducer(),
-		round:       0,
+		recorder:    usage.GetRecorder(),
+		phrases:     phrase.GetCache(),
+		styler:      emotion.GetStyler(),
+		prefs:       preference.GetStore(),
+		recordings:  recording.GetRecorder(),
 	}
 	return e
 }
@@ -104,24 +218,31 @@ func (e *Engine) Start() error {
 		_ = e.ws.Error(consts.ErrInvalidUser)
 		return consts.ErrInvalidUser
 	}
+	e.started = true
+	metrics.ChatSessionActive.Inc(e.chatProvider, e.lang)
+	if p := e.preference(); p != nil {
+		e.writePreference(p)
+	}
 
-	msg := "你好呀"
-
-	// 音频生成
+	// 音频生成, 不可用时降级为纯文字对话
 	if err = e.tts(); err != nil {
-		return err
+		metrics.TtsError.Inc(e.ttsProvider, e.lang)
+		log.Error("tts init err:", err)
+		e.degrade()
 	}
 
-	// chat模型调用
-	go e.streamCall(msg)
+	// 开场白为第0轮, 使用固定话术以便直接播放缓存的音频, 额度用尽时直接播报提示语
+	if e.exhausted() {
+		e.say(0, e.recorder.Message())
+	} else {
+		e.say(0, e.scripts.Greeting)
+	}
 
 	// 由于sessionId由第三方给出, 所以这里需要手动管理聊天记录的顺序
 	// 等待获取sessionId, 初始化redis
 	his := <-e.aiHistory
-	if err = e.rs.AddSystem(e.sessionId, msg); err != nil {
-		return err
-	}
-	if err = e.rs.AddAi(e.sessionId, his); err != nil {
+	e.track.SetSession(e.sessionId)
+	if err = e.rs.AddAi(e.turnContext(), e.sessionId, his); err != nil {
 		return err
 	}
 	return err
@@ -138,24 +259,137 @@ func (e *Engine) validate() bool {
 	}
 	log.Info("调用方: %s, 调用时间: %s", startReq.From, time.Unix(startReq.Timestamp, 0).String())
 
-	c := config.GetConfig()
-	if startReq.Lang == "zh-shanghai" {
-		e.ttsApp = volc.NewVcNoModelTtsApp(c.VolcNoModelTts.AppKey, c.VolcNoModelTts.AccessKey, c.VolcNoModelTts.Speaker, c.VolcNoModelTts.Cluster, c.VolcNoModelTts.Url)
-		e.chatApp = bailian.NewBLChatApp(c.BaiLianShanghaiChat.AppId, c.BaiLianShanghaiChat.ApiKey)
-		e.ttsStream = false
-	} else if startReq.Lang == "zh" {
-		e.ttsApp = volc.NewVcTtsApp(c.VolcTts.AppKey, c.VolcTts.AccessKey, c.VolcTts.Speaker, c.VolcTts.ResourceId, c.VolcTts.Url)
-		e.chatApp = bailian.NewBLChatApp(c.BaiLianChat.AppId, c.BaiLianChat.ApiKey)
-		e.ttsStream = true
-	} else {
+	l, ok := language.GetRegistry().Get(startReq.Lang)
+	if !ok {
+		log.Error("unsupported lang:", startReq.Lang)
 		return false
 	}
+	e.lang = startReq.Lang
+	e.scripts = l.Scripts
+	e.owner = usage.Owner{
+		AppId:         startReq.AppId,
+		InstitutionId: startReq.InstitutionId,
+		SeniorId:      startReq.SeniorId,
+	}
+	e.span.SetAttributes(attribute.String("lang", startReq.Lang), attribute.String("from", startReq.From))
+	if startReq.SeniorId != "" {
+		// 读取失败时使用默认的语音, 不影响对话
+		if e.pref, err = e.prefs.Load(e.ctx, startReq.SeniorId); err != nil {
+			log.Error("load preference err:", err)
+		}
+	}
+	e.chatProvider = consts.BaiLian
+	e.normalizer = speech.GetNormalizer(startReq.Lang)
+	e.transcript = speech.Transcript(l.Code, l.Lexicon)
+	// 前端使用opus时直接由第三方合成ogg封装的opus, 减少下行带宽
+	format := model.EncodingPCM
+	if audio.IsOpus(startReq.OutputFormat.Encoding) {
+		format = model.EncodingOggOpus
+	}
+	// 对话应用, 合成方式与音色由语言的配置决定
+	e.ttsApp = failover.NewLangTtsApp(l.Tts, format)
+	e.chatApp = failover.NewChatApp(l.Chat, l.ChatFailover)
+	e.ttsProvider = l.Tts.Backend
+	e.promptVersion = l.Chat.PromptVersion
+	e.captions = startReq.Captions
+	if s, ok := e.ttsApp.(model.PhraseSynthesizer); ok {
+		e.speaker = s.Voice().Speaker
+		e.clock = audio.NewPCMClock(s.Voice().SampleRate, 1)
+	}
+	if format == model.EncodingOggOpus {
+		e.clock = audio.NewOggClock()
+	}
+	if e.pref != nil && e.recordings.Allowed(e.pref.RecordConsent) {
+		e.track = e.newTrack(format)
+	}
+	switch startReq.OutputFormat.Encoding {
+	case audio.Ogg:
+	case audio.Opus:
+		e.packets = audio.NewOggReader()
+	default:
+		e.output = outputConverter(e.ttsApp, startReq.OutputFormat)
+	}
 	return true
 }
 
+// newTrack 按合成音频的格式录制回复, 格式未知时不录音
+func (e *Engine) newTrack(format string) *recording.Track {
+	f := recording.Format{Ogg: format == model.EncodingOggOpus}
+	if s, ok := e.ttsApp.(model.PhraseSynthesizer); ok {
+		f.SampleRate = s.Voice().SampleRate
+	}
+	if !f.Ogg && f.SampleRate == 0 {
+		return nil
+	}
+	return e.recordings.NewTrack(recording.SideAi, e.owner.SeniorId, f)
+}
+
+// outputConverter 创建合成音频到前端声明格式的转换, 格式不支持时记录日志并按原格式下发
+func outputConverter(app model.TtsApp, f dto.AudioFormat) *audio.Converter {
+	s, ok := app.(model.PhraseSynthesizer)
+	if !ok {
+		return nil
+	}
+	src := audio.Format{Encoding: audio.PCM16, SampleRate: s.Voice().SampleRate, Channels: 1}
+	dst := audio.Format{Encoding: f.Encoding, SampleRate: f.SampleRate, Channels: f.Channels}.Or(src)
+	c, err := audio.NewConverter(src, dst)
+	if err != nil {
+		log.Error("invalid output format:", err)
+		return nil
+	}
+	return c
+}
+
+// writeAudio 将合成的音频转换为前端的格式后写入
+func (e *Engine) writeAudio(data []byte) error {
+	e.audioMu.Lock()
+	defer e.audioMu.Unlock()
+	e.lastAudio.Store(time.Now().UnixNano())
+	e.clock.Advance(data)
+	// 录音保存合成的原始音频, 与前端的格式无关
+	e.track.Write(e.round.Load(), data)
+	if e.packets != nil {
+		packets, err := e.packets.Write(data)
+		for _, p := range packets {
+			if err := e.ws.WriteBytes(p); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	data, err := e.output.Convert(data)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	return e.ws.WriteBytes(data)
+}
+
+// onCaption 按已下发音频的位置下发字幕, 句子开始时的位置即为该句音频的起点
+func (e *Engine) onCaption(c *model.Caption) {
+	e.audioMu.Lock()
+	defer e.audioMu.Unlock()
+	pos := e.clock.Position()
+	resp := &dto.ChatCaption{Type: consts.Caption, Event: consts.EventSentenceStart, Text: c.Text}
+	if !c.End {
+		e.sentenceStart = pos
+	} else {
+		resp.Event, resp.End = consts.EventSentenceEnd, pos.Milliseconds()
+		for _, w := range c.Words {
+			resp.Words = append(resp.Words, &dto.CaptionWord{
+				Text:  w.Text,
+				Start: (e.sentenceStart + w.Start).Milliseconds(),
+				End:   (e.sentenceStart + w.End).Milliseconds(),
+			})
+		}
+	}
+	resp.Start = e.sentenceStart.Milliseconds()
+	if err := e.ws.WriteJSON(resp); err != nil {
+		log.Error("write caption err:", err)
+	}
+}
+
 // Chat 长对话的主体部分 #生产者
 func (e *Engine) Chat() {
-	var req dto.ChatReq
 	var err error
 	defer func() {
 		if err != nil {
@@ -168,6 +402,7 @@ func (e *Engine) Chat() {
 
 	for {
 		// 获取前端对话内容
+		var req dto.ChatReq
 		err = e.ws.ReadJSON(&req)
 		if err != nil {
 			return
@@ -175,6 +410,7 @@ func (e *Engine) Chat() {
 		// 判断是否结束
 		switch req.Cmd {
 		case consts.EndCmd:
+			e.farewell(e.round.Add(1))
 			return
 		case consts.Ping:
 			err := e.ws.WriteBytes([]byte{})
@@ -185,28 +421,111 @@ func (e *Engine) Chat() {
 
 		}
 		// 写入用户消息
-		e.userHistory <- req.Msg
-		e.round++
+		round := e.round.Add(1)
+		source := req.Source
+		if source != consts.SourceAsr {
+			source = consts.SourceText
+		}
+		msg, raw := req.Msg, ""
+		if source == consts.SourceAsr {
+			// 识别结果去除口头禅与口吃, 方言写法转为普通话后再交给大模型, 原文保存用于研究
+			raw = req.Msg
+			if msg = e.transcript.Normalize(raw); msg == "" {
+				msg = raw
+			}
+		}
+		e.userHistory <- &dto.ChatHistory{
+			Content: msg,
+			Raw:     raw,
+			Turn:    round,
+			Source:  source,
+		}
+		// 调整语速音量等的指令直接处理, 不调用大模型
+		if e.adjust(round, msg) {
+			continue
+		}
 		// 调用ai, 流式响应
-		go e.streamCall(req.Msg)
+		if e.exhausted() {
+			e.say(round, e.recorder.Message())
+			continue
+		}
+		e.workers.Add(1)
+		go func() {
+			defer e.workers.Done()
+			e.streamCall(round, msg)
+		}()
 	}
 }
 
 // streamCall 调用chatApp并流式写入响应 #生产者
-func (e *Engine) streamCall(msg string) {
-	var record string
+func (e *Engine) streamCall(round int64, msg string) {
+	var content string
 	var data *dto.ChatData
 
+	// 记录本轮开始时间, 用于统计首token与首音频耗时
+	start := time.Now()
+	first := true
+	e.turnStart.Store(start.UnixNano())
+	e.firstAudio.Store(0)
+	e.waitAudio.Store(true)
+
+	// 已降级为纯文字时, 在每轮开始时尝试恢复语音合成
+	if e.textOnly.Load() {
+		e.restore()
+	}
+
+	// 本轮AI回复的记录
+	record := &dto.ChatHistory{
+		Turn:          round,
+		Provider:      e.chatProvider,
+		PromptVersion: e.promptVersion,
+	}
+	// 本轮回复的语音风格, 由老人这句话与回复开头的情绪决定
+	style := &replyStyle{user: emotion.Detect(msg)}
+
+	// 本轮对话的span, 模型调用与音频合成均为其子span
+	ctx, turn := trace.Start(e.ctx, "chat.turn", attribute.Int64("round", round))
+	_, audio := trace.Start(ctx, "tts.receive", attribute.String("provider", e.ttsProvider))
+	e.setTurn(ctx, audio)
+	_, stream := trace.Start(ctx, "bailian.stream", attribute.String("provider", e.chatProvider))
+
 	// 流式响应的scanner
-	scanner, err := e.chatApp.StreamCall(msg, e.sessionId)
+	scanner, err := e.chatApp.Stream(ctx, []*model.Message{{Role: model.RoleUser, Content: msg}}, &model.ChatOptions{SessionId: e.chatSession})
 	defer func() {
-		_ = scanner.Close()
+		if errors.Is(err, io.EOF) {
+			stream.AddEvent("completion")
+			trace.End(stream, nil)
+		} else {
+			trace.End(stream, err)
+		}
+		turn.End()
+		if scanner != nil {
+			_ = scanner.Close()
+		}
+
+		record.Content = content
+		record.Duration = metrics.Since(start)
+		record.Emotion, record.VoiceStyle = style.choose(e, content)
+		// 用户在回复完成前开始了新一轮, 视为被打断, 此时的首音频耗时也不再属于本轮
+		if e.round.Load() != round {
+			record.Interrupted = true
+		} else {
+			record.FirstAudio = e.firstAudio.Load()
+		}
+		e.addUsage(record)
 		switch {
 		case errors.Is(err, io.EOF):
-			e.aiHistory <- record
+			e.reply(record)
+		case e.ctx.Err() != nil:
+			// 会话已结束, 通道即将关闭, 不再写入
+		case content == "":
+			// 所有对话后端均不可用, 播报提示语, 避免老人听不到任何回应
+			log.Error("stream call err:", err)
+			e.say(round, unavailableMsg)
 		default:
 			// 错误时写入异常值, 避免主协程无限等待
-			e.aiHistory <- "stop:" + err.Error()
+			record.Content = "stop:" + err.Error()
+			e.reply(record)
 		}
 	}()
 
@@ -221,14 +540,32 @@ func (e *Engine) streamCall(msg string) {
 			if err != nil {
 				return
 			}
+			if first {
+				first = false
+				record.FirstToken = metrics.Since(start)
+				stream.AddEvent("first_token")
+				metrics.ChatFirstToken.Observe(record.FirstToken, e.chatProvider, e.lang)
+			}
+			if data.Model != "" {
+				record.Model = data.Model
+			}
+			if data.Usage != nil {
+				record.Usage = data.Usage
+			}
 			// 第一次调用, 写入sessionId
+			if e.chatSession == "" {
+				e.chatSession = data.SessionId
+			}
 			if e.sessionId == "" {
 				e.sessionId = data.SessionId
 			}
+			data.SessionId = e.sessionId
 			// 风险分析
 			analyse(&data.Content)
-			// 写入文本, 用于音频合成
-			e.outw <- data.Content
+			// 写入文本, 用于音频合成, 回复结束时合成剩余的文本
+			if !e.synthesize(ttsText{text: data.Content, end: finished(data.Finish), style: style}) {
+				return
+			}
 			// 写入响应 TODO: test待删除
 			log.Info("data: ", data)
 			err = e.ws.WriteJSON(data)
@@ -236,20 +573,305 @@ func (e *Engine) streamCall(msg string) {
 				return
 			}
 			// 拼接聊天记录
-			record += data.Content
+			content += data.Content
+		}
+	}
+}
+
+// say 播报固定话术, 不调用大模型, 如额度用尽时的提示语, 会话结束后不再播报
+func (e *Engine) say(round int64, text string) {
+	if e.sessionId == "" {
+		// 尚未调用过大模型, 没有第三方给出的sessionId
+		e.sessionId = uuid.NewString()
+	}
+	record := &dto.ChatHistory{
+		Content:  text,
+		Turn:     round,
+		Provider: consts.Script,
+	}
+	style := &replyStyle{user: emotion.Neutral}
+	record.Emotion, record.VoiceStyle = style.choose(e, text)
+	e.waitAudio.Store(false)
+	if e.ctx.Err() != nil {
+		return
+	}
+	// 优先播放缓存的音频, 与合成的文本一起排队, 在之前的回复之后播放
+	cached := e.cachedAudio(text)
+	record.AudioCached = cached != nil
+	if !e.synthesize(ttsText{text: text, end: true, style: style, audio: cached}) {
+		return
+	}
+	if err := e.ws.WriteJSON(&dto.ChatData{
+		Content:   text,
+		SessionId: e.sessionId,
+		Timestamp: time.Now().Unix(),
+		Finish:    "stop",
+	}); err != nil {
+		log.Error("write script err:", err)
+	}
+	e.addUsage(record)
+	e.reply(record)
+}
+
+// synthesize 写入送去合成的文本, 会话结束时返回false
+// 通道在会话结束后关闭, 写入前需确认会话未结束
+func (e *Engine) synthesize(t ttsText) bool {
+	select {
+	case e.outw <- t:
+		return true
+	case <-e.ctx.Done():
+		return false
+	}
+}
+
+// reply 写入一轮回复的记录, 会话结束后不再写入
+func (e *Engine) reply(his *dto.ChatHistory) {
+	select {
+	case e.aiHistory <- his:
+	case <-e.ctx.Done():
+	}
+}
+
+// farewell 老人结束对话时播报告别语, 等待音频下发完成后返回, 最多等待goodbyeWait
+func (e *Engine) farewell(round int64) {
+	start := time.Now()
+	e.say(round, e.scripts.Goodbye)
+	if e.textOnly.Load() {
+		return
+	}
+	deadline := time.NewTimer(goodbyeWait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(goodbyeQuiet / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+			// 告别语的音频已开始下发, 且一段时间内没有新的音频
+			if last := e.lastAudio.Load(); last > start.UnixNano() && time.Since(time.Unix(0, last)) > goodbyeQuiet {
+				return
+			}
 		}
 	}
 }
 
+// cachedAudio 获取固定话术缓存的音频, 未命中时在后台合成并缓存, 下次即可直接播放
+func (e *Engine) cachedAudio(text string) []byte {
+	s, ok := e.ttsApp.(model.PhraseSynthesizer)
+	// 缓存的音频按默认的语音合成, 老人调整过语音时不使用
+	if !ok || e.textOnly.Load() || !preference.Default(e.preference()) {
+		return nil
+	}
+	audio := e.phrases.Get(e.ctx, s.Voice(), text)
+	if audio == nil {
+		go func() {
+			if err := e.phrases.Fill(trace.Detach(e.ctx), s, text); err != nil {
+				log.Error("fill phrase audio err:", err)
+			}
+		}()
+	}
+	return audio
+}
+
+// playCached 播放缓存的固定话术音频, 由ttsUp调用, 先等待之前送去合成的音频下发完成, 避免两段音频交错
+func (e *Engine) playCached(text string, audio []byte) {
+	e.drain()
+	// 缓存的音频没有逐字的时间, 只下发整句的字幕
+	if e.captions {
+		e.onCaption(&model.Caption{Text: text})
+		defer e.onCaption(&model.Caption{Text: text, End: true})
+	}
+	for len(audio) > 0 {
+		n := min(len(audio), phraseChunk)
+		if err := e.writeAudio(audio[:n]); err != nil {
+			log.Error("ws write audio err:", err)
+			return
+		}
+		audio = audio[n:]
+	}
+}
+
+// drain 等待已送去合成的文本的音频下发完成, 即收到音频后一段时间内没有新的音频, 最多等待到发送后drainWait
+func (e *Engine) drain() {
+	sent := e.lastSend.Load()
+	if sent == 0 {
+		return
+	}
+	deadline := time.Unix(0, sent).Add(drainWait)
+	ticker := time.NewTicker(goodbyeQuiet / 4)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		if last := e.lastAudio.Load(); last > sent && time.Since(time.Unix(0, last)) > goodbyeQuiet {
+			return
+		}
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// preference 获取老人偏好的副本, 没有偏好时返回nil
+func (e *Engine) preference() *preference.Preference {
+	e.prefMu.Lock()
+	defer e.prefMu.Unlock()
+	if e.pref == nil {
+		return nil
+	}
+	p := *e.pref
+	return &p
+}
+
+// adjust 处理调整偏好的语音指令, 之后的回复立即生效, 并保存到老人的偏好中
+func (e *Engine) adjust(round int64, msg string) bool {
+	intent, ok := preference.Match(msg)
+	if !ok {
+		return false
+	}
+	e.prefMu.Lock()
+	if e.pref == nil {
+		// 有老人标记时没有记录也会读到默认偏好, 为nil说明没有老人标记或读取失败
+		// 此时不带老人标记, 只在本次对话中生效, 避免覆盖已保存的偏好
+		e.pref = &preference.Preference{}
+	}
+	reply, changed := intent.Apply(e.pref)
+	p := *e.pref
+	e.prefMu.Unlock()
+	if changed {
+		e.span.AddEvent(consts.Preference)
+		e.writePreference(&p)
+		// 没有老人标记或偏好读取失败时只在本次对话中生效
+		if p.SeniorId != "" {
+			if err := e.prefs.Save(e.ctx, &p); err != nil {
+				log.Error("save preference err:", err)
+			}
+		}
+	}
+	e.say(round, reply)
+	return true
+}
+
+// writePreference 下发老人的偏好
+func (e *Engine) writePreference(p *preference.Preference) {
+	if err := e.ws.WriteJSON(&dto.ChatPreference{
+		Type:       consts.Preference,
+		Speaker:    p.Speaker,
+		SpeechRate: p.SpeechRate,
+		Volume:     p.Volume,
+		TextSize:   p.TextSize,
+	}); err != nil {
+		log.Error("write preference err:", err)
+	}
+}
+
+// exhausted 检查额度是否用尽, 检查失败时不拦截对话
+func (e *Engine) exhausted() bool {
+	exceeded, err := e.recorder.Exceeded(e.ctx, e.owner, usage.KindChat)
+	if err != nil {
+		log.Error("check quota err:", err)
+		return false
+	}
+	return exceeded
+}
+
+// addUsage 统计一轮回复的用量, 合成字数按送去合成的回复计算
+func (e *Engine) addUsage(record *dto.ChatHistory) {
+	if !record.AudioCached {
+		record.TtsChars = int64(utf8.RuneCountInString(record.Content))
+	}
+	stat := &dto.UsageStat{TtsChars: record.TtsChars}
+	if record.Usage != nil {
+		stat.InputTokens = record.Usage.InputTokens
+		stat.OutputTokens = record.Usage.OutputTokens
+	}
+	if stat.Empty() {
+		return
+	}
+	e.usageMu.Lock()
+	e.stat.Add(stat)
+	e.usageMu.Unlock()
+	// 会话可能已经结束, 不继承取消信号
+	if err := e.recorder.Add(trace.Detach(e.turnContext()), e.owner, stat); err != nil {
+		log.Error("add usage err:", err)
+	}
+}
+
 // tts 初始化tts app 并启动发送和接受goroutine
+// 初始化失败时同样启动goroutine, 以便消费文本并在之后恢复
 func (e *Engine) tts() error {
-	err := e.ttsInit()
-	if err != nil {
-		return err
+	if r, ok := e.ttsApp.(model.Reconnectable); ok {
+		r.OnReconnect(e.onTtsReconnect)
+	}
+	if c, ok := e.ttsApp.(model.Captioner); ok && e.captions {
+		c.OnCaption(e.onCaption)
 	}
+	err := e.ttsInit()
 	go e.ttsUp(e.outw)
 	go e.ttsDown()
-	return nil
+	return err
+}
+
+// degrade 降级为纯文字对话, 并通知前端
+func (e *Engine) degrade() {
+	if !e.textOnly.CompareAndSwap(false, true) {
+		return
+	}
+	e.span.AddEvent(consts.EventTtsDegraded)
+	if err := e.ws.WriteJSON(&dto.ChatNotice{
+		Type:  consts.Notice,
+		Event: consts.EventTtsDegraded,
+		Msg:   "语音服务暂时不可用, 先用文字和您聊天",
+	}); err != nil {
+		log.Error("write notice err:", err)
+	}
+}
+
+// onTtsReconnect 语音合成断线重连时通知前端, 重连失败时由ttsDown降级
+func (e *Engine) onTtsReconnect(state model.ReconnectState) {
+	var event, msg string
+	switch state {
+	case model.Reconnecting:
+		if !e.reconnecting.CompareAndSwap(false, true) {
+			return
+		}
+		event, msg = consts.EventTtsReconnecting, "语音服务连接中断, 正在重新连接"
+	case model.Reconnected:
+		if !e.reconnecting.CompareAndSwap(true, false) {
+			return
+		}
+		event, msg = consts.EventTtsReconnected, "语音服务已重新连接"
+	default:
+		e.reconnecting.Store(false)
+		return
+	}
+	e.span.AddEvent(event)
+	if err := e.ws.WriteJSON(&dto.ChatNotice{
+		Type:  consts.Notice,
+		Event: event,
+		Msg:   msg,
+	}); err != nil {
+		log.Error("write notice err:", err)
+	}
+}
+
+// restore 尝试恢复语音合成, 成功后通知前端
+func (e *Engine) restore() {
+	if err := e.ttsInit(); err != nil {
+		return
+	}
+	e.textOnly.Store(false)
+	e.span.AddEvent(consts.EventTtsRestored)
+	if err := e.ws.WriteJSON(&dto.ChatNotice{
+		Type:  consts.Notice,
+		Event: consts.EventTtsRestored,
+		Msg:   "语音服务已恢复",
+	}); err != nil {
+		log.Error("write notice err:", err)
+	}
 }
 
 // ttsInit 初始话音频生成
@@ -264,31 +886,97 @@ func (e *Engine) ttsInit() (err error) {
 }
 
 // ttsUp 上传合成音频用文字 #消费者
-func (e *Engine) ttsUp(texts chan string) {
-	var err error
-	if e.ttsStream {
-		for text := range texts {
-			if err = e.ttsApp.Send(text); err != nil {
-				log.Error("send tts err:", err)
+// 文本按句切分后发送, 回复结束或超时未凑成一句时发送剩余文本
+// 降级为纯文字时只消费文本, 不再合成
+func (e *Engine) ttsUp(texts chan ttsText) {
+	c := config.GetConfig().Segment
+	seg := speech.NewSegmenter(c.MinChars, c.MaxChars)
+	timeout := time.Duration(c.FlushTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultFlushTimeout
+	}
+	timer := time.NewTimer(timeout)
+	timer.Stop()
+	defer timer.Stop()
+	// last 缓冲区中文本所属回复的风格, 超时发送时使用
+	var last *replyStyle
+	for {
+		select {
+		case t, ok := <-texts:
+			if !ok {
 				return
 			}
-		}
-	} else {
-		var sb strings.Builder
-		for text := range texts {
-			if text != "" {
-				sb.WriteString(text)
-			} else {
-				if err = e.ttsApp.Send(sb.String()); err != nil {
-					log.Error("send tts err:", err)
-					return
+			if t.audio != nil {
+				// 先合成缓冲中剩余的文本, 缓存的音频在其后播放
+				if rest := seg.Flush(); rest != "" {
+					e.applyStyle(last, rest)
+					e.send(rest)
 				}
-				sb.Reset()
+				timer.Stop()
+				e.playCached(t.text, t.audio)
+				continue
+			}
+			if t.style != nil {
+				last = t.style
+			}
+			for _, sentence := range seg.Write(t.text) {
+				e.applyStyle(t.style, sentence)
+				e.send(sentence)
+			}
+			if t.end {
+				rest := seg.Flush()
+				e.applyStyle(t.style, rest)
+				e.send(rest)
+			}
+			timer.Stop()
+			if seg.Len() > 0 {
+				timer.Reset(timeout)
+			}
+		case <-timer.C:
+			// 大模型输出停顿, 避免剩余文本迟迟不能合成
+			if rest := seg.Flush(); rest != "" {
+				e.applyStyle(last, rest)
+				e.send(rest)
 			}
 		}
 	}
 }
 
+// applyStyle 在一轮回复的第一句合成前选定并设置语音风格, 之后的句子沿用
+func (e *Engine) applyStyle(style *replyStyle, sentence string) {
+	s, ok := e.ttsApp.(model.Stylable)
+	if !ok || style == nil || style.applied.Swap(true) {
+		return
+	}
+	style.choose(e, sentence)
+	s.SetStyle(style.style)
+}
+
+// send 规范化一段文字后发送用于合成, 所有后端均失败时降级为纯文字
+func (e *Engine) send(text string) {
+	if e.textOnly.Load() {
+		return
+	}
+	if text = e.normalizer.Normalize(text); text == "" {
+		return
+	}
+	if err := e.sendTts(text); err != nil {
+		metrics.TtsError.Inc(e.ttsProvider, e.lang)
+		log.Error("send tts err:", err)
+		e.degrade()
+		return
+	}
+	e.lastSend.Store(time.Now().UnixNano())
+}
+
+// sendTts 发送一段文字用于合成, 并记录span
+func (e *Engine) sendTts(text string) (err error) {
+	_, span := trace.Start(e.turnContext(), "tts.send",
+		attribute.String("provider", e.ttsProvider), attribute.Int("text_len", len(text)))
+	defer func() { trace.End(span, err) }()
+	return e.ttsApp.Send(text)
+}
+
 // ttsDown 获取生成的音频 #生产者
 func (e *Engine) ttsDown() {
 	for {
@@ -296,10 +984,28 @@ func (e *Engine) ttsDown() {
 		case <-e.ctx.Done():
 			return
 		default:
-			audio := e.ttsApp.Receive()
+			audio, err := e.ttsApp.Receive()
+			if err != nil {
+				if e.ctx.Err() != nil {
+					return
+				}
+				// 连接正常结束, 下次发送时重新建立, 不降级
+				if !errors.Is(err, io.EOF) {
+					metrics.TtsError.Inc(e.ttsProvider, e.lang)
+					log.Error("tts receive err:", err)
+					// 所有后端均不可用或重连失败, 降级后由下一轮尝试恢复
+					e.degrade()
+				}
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
 			if audio != nil {
-				err := e.ws.WriteBytes(audio)
-				if err != nil {
+				if e.waitAudio.CompareAndSwap(true, false) {
+					e.firstAudio.Store(metrics.Since(time.Unix(0, e.turnStart.Load())))
+					metrics.ChatFirstAudio.Observe(e.firstAudio.Load(), e.ttsProvider, e.lang)
+					e.endAudioSpan()
+				}
+				if err = e.writeAudio(audio); err != nil {
 					log.Error("ws write audio err:", err)
 				}
 			}
@@ -308,15 +1014,15 @@ func (e *Engine) ttsDown() {
 }
 
 // history 处理聊天记录 #消费者
-func (e *Engine) history(ai, user chan string) {
+func (e *Engine) history(ai, user chan *dto.ChatHistory) {
 	for {
 		select {
 		case his, ok := <-ai:
 			if !ok {
 				ai = nil
 			}
-			if his != "" {
-				if err := e.rs.AddAi(e.sessionId, his); err != nil {
+			if his != nil && his.Content != "" {
+				if err := e.rs.AddAi(e.turnContext(), e.sessionId, his); err != nil {
 					log.Error("ai history err:", err)
 				}
 			}
@@ -325,8 +1031,8 @@ func (e *Engine) history(ai, user chan string) {
 			if !ok {
 				user = nil
 			}
-			if his != "" {
-				if err := e.rs.AddUser(e.sessionId, his); err != nil {
+			if his != nil && his.Content != "" {
+				if err := e.rs.AddUser(e.turnContext(), e.sessionId, his); err != nil {
 					log.Error("user history err:", err)
 				}
 			}
@@ -336,6 +1042,13 @@ func (e *Engine) history(ai, user chan string) {
 
 // Close 结束本轮对话
 func (e *Engine) Close() {
+	defer e.span.End()
+	if e.started {
+		metrics.ChatSessionActive.Dec(e.chatProvider, e.lang)
+	}
+	// 连接已断开时同样等待录音保存完成并汇总用量, 录音在回复协程结束后关闭
+	defer e.track.Close()
+	defer e.flushUsage()
 	// 发送结束标识
 	err := e.ws.WriteJSON(&dto.ChatEndResp{
 		Code: 0,
@@ -345,18 +1058,32 @@ func (e *Engine) Close() {
 		log.Error(err.Error())
 		return
 	}
-	// 关闭所有协程
+	// 关闭所有协程, 等待调用大模型的协程结束后再关闭通道
 	e.cancel()
+	e.workers.Wait()
+	e.endAudioSpan()
 	_ = e.close()
 	// 发送对话历史记录消息
-	if e.round >= 0 {
-		if err = e.provider.Produce(e.ctx, e.sessionId, e.startTime, time.Now()); err != nil {
+	if e.round.Load() >= 0 {
+		if err = e.provider.Produce(e.ctx, e.sessionId, e.lang, e.owner, e.startTime, time.Now()); err != nil {
 			log.Error("消息发送失败, sessionId: ", e.sessionId)
 		}
 	}
 
 }
 
+// flushUsage 等待各轮回复结束后汇总本次会话的用量
+func (e *Engine) flushUsage() {
+	e.cancel()
+	e.workers.Wait()
+	e.usageMu.Lock()
+	stat := e.stat
+	e.usageMu.Unlock()
+	if err := e.recorder.Flush(trace.Detach(e.ctx), e.owner, &stat); err != nil {
+		log.Error("flush usage err:", err)
+	}
+}
+
 // close 释放相关资源
 // 所有的通道由close统一关闭, 生产者不负责关闭, 生成者由ctx.Done()关闭
 // 消费者需要因为所有的通道关闭结束
@@ -381,6 +1108,95 @@ func (e *Engine) close() (err error) {
 	return
 }
 
+// setTurn 记录当前轮次的追踪信息, 并结束上一轮未结束的音频span
+func (e *Engine) setTurn(ctx context.Context, audio oteltrace.Span) {
+	e.turnMu.Lock()
+	defer e.turnMu.Unlock()
+	if e.audioSpan != nil {
+		e.audioSpan.End()
+	}
+	e.turnCtx, e.audioSpan = ctx, audio
+}
+
+// turnContext 获取当前轮次的上下文, 尚未开始时返回会话上下文
+func (e *Engine) turnContext() context.Context {
+	e.turnMu.Lock()
+	defer e.turnMu.Unlock()
+	if e.turnCtx == nil {
+		return e.ctx
+	}
+	return e.turnCtx
+}
+
+// endAudioSpan 结束当前轮次等待音频的span
+func (e *Engine) endAudioSpan() {
+	e.turnMu.Lock()
+	defer e.turnMu.Unlock()
+	if e.audioSpan != nil {
+		e.audioSpan.End()
+		e.audioSpan = nil
+	}
+}
+
+// unavailableMsg 对话服务不可用时播报的提示语
+const unavailableMsg = "不好意思，我刚才没听清楚，您能再说一遍吗？"
+
+// goodbyeWait 等待告别语播放的最长时间, goodbyeQuiet 没有新音频多久后认为播放完成
+const (
+	goodbyeWait  = 5 * time.Second
+	goodbyeQuiet = 400 * time.Millisecond
+)
+
+// phraseChunk 缓存音频分包发送的大小, 与合成音频的包大小相近
+const phraseChunk = 8192
+
+// drainWait 播放缓存的音频前, 等待之前合成的音频下发完成的最长时间
+const drainWait = 5 * time.Second
+
+// defaultFlushTimeout 未凑成一句的文本等待该时间后直接合成
+const defaultFlushTimeout = 800 * time.Millisecond
+
+// ttsText 送去合成的文本片段
+type ttsText struct {
+	text string
+	// end 本轮回复结束, 需要合成缓冲中剩余的文本
+	end bool
+	// style 所属回复的语音风格, 为nil时沿用之前的风格
+	style *replyStyle
+	// audio 固定话术缓存的音频, 不为空时直接播放, 不再合成
+	audio []byte
+}
+
+// replyStyle 一轮回复的语音风格, 在合成第一句前选定, 回复未合成时在结束时选定
+type replyStyle struct {
+	once sync.Once
+	// applied 是否已设置给语音合成
+	applied atomic.Bool
+	// user 老人这句话的情绪
+	user  string
+	tone  string
+	style model.Style
+}
+
+// choose 根据回复的开头选定风格, 只有第一次调用生效, 返回情绪与风格名称
+func (r *replyStyle) choose(e *Engine, reply string) (string, string) {
+	r.once.Do(func() {
+		r.tone = emotion.Choose(r.user, emotion.Detect(reply))
+		p := e.preference()
+		speaker := e.speaker
+		if p != nil && p.Speaker != "" {
+			speaker = p.Speaker
+		}
+		r.style = preference.Apply(p, e.styler.Style(speaker, r.tone))
+	})
+	return r.tone, r.style.Name
+}
+
+// finished 根据结束原因判断回复是否结束, 流式输出过程中百炼返回"null"
+func finished(reason string) bool {
+	return reason != "" && reason != "null"
+}
+
 // analyse 风险分析
 func analyse(text *string) {
 	//if strings.Contains(*text, "&") {
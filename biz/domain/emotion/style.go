@@ -0,0 +1,67 @@
+package emotion
+
+import (
+	"sync"
+
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+// defaults 内置的风格, 只调整语速与音调, 情感需要音色支持, 由配置指定
+var defaults = map[string]config.Style{
+	Sad:     {Name: "gentle", SpeechRate: -15, PitchRate: -5},
+	Anxious: {Name: "calm", SpeechRate: -10},
+	Happy:   {Name: "cheerful", SpeechRate: 5, PitchRate: 5},
+	Neutral: {Name: "chat", PitchRate: 3},
+}
+
+// Styler 按音色与情绪选择语音风格
+type Styler struct {
+	disable  bool
+	base     map[string]config.Style
+	speakers map[string]map[string]config.Style
+}
+
+var (
+	styler *Styler
+	once   sync.Once
+)
+
+func GetStyler() *Styler {
+	once.Do(func() {
+		styler = NewStyler(config.GetConfig().VoiceStyle)
+	})
+	return styler
+}
+
+func NewStyler(c config.VoiceStyle) *Styler {
+	s := &Styler{disable: c.Disable, base: make(map[string]config.Style), speakers: c.Speakers}
+	for tone, style := range defaults {
+		s.base[tone] = style
+	}
+	for tone, style := range c.Default {
+		s.base[tone] = style
+	}
+	return s
+}
+
+// Style 获取音色在一种情绪下的风格, 关闭时返回默认的风格
+func (s *Styler) Style(speaker, tone string) model.Style {
+	if s == nil || s.disable {
+		return model.Style{}
+	}
+	style, ok := s.speakers[speaker][tone]
+	if !ok {
+		style = s.base[tone]
+	}
+	if style.Name == "" {
+		style.Name = tone
+	}
+	return model.Style{
+		Name:       style.Name,
+		Emotion:    style.Emotion,
+		SpeechRate: style.SpeechRate,
+		PitchRate:  style.PitchRate,
+		Volume:     style.Volume,
+	}
+}
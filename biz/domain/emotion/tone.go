@@ -0,0 +1,85 @@
+package emotion
+
+import "strings"
+
+// 根据关键词粗略判断一句话的情绪, 用于选择合成语音的风格, 不用于风险分析
+
+// 情绪
+const (
+	Sad     = "sad"
+	Anxious = "anxious"
+	Happy   = "happy"
+	Neutral = "neutral"
+)
+
+var (
+	sadWords     = []string{"难过", "伤心", "孤单", "孤独", "寂寞", "想念", "去世", "哭", "没意思", "难受", "委屈", "心酸", "唉"}
+	anxiousWords = []string{"担心", "害怕", "着急", "紧张", "焦虑", "睡不着", "不放心", "心慌", "发慌", "头晕", "不舒服"}
+	happyWords   = []string{"开心", "高兴", "快乐", "好玩", "哈哈", "不错", "喜欢", "太好了", "真好", "有意思"}
+	// negations 出现在积极词语之前时视为消极
+	negations = []string{"不", "没", "没有", "不太", "不怎么"}
+)
+
+// Detect 判断一句话的情绪, 没有明显情绪时为Neutral
+// 得分相同时消极的情绪优先, 宁可温和也不要在老人难过时语气欢快
+func Detect(text string) string {
+	sad := count(text, sadWords)
+	anxious := count(text, anxiousWords)
+	happy := 0
+	for _, w := range happyWords {
+		for i := strings.Index(text, w); i >= 0; {
+			if negated(text[:i]) {
+				sad++
+			} else {
+				happy++
+			}
+			next := strings.Index(text[i+len(w):], w)
+			if next < 0 {
+				break
+			}
+			i += len(w) + next
+		}
+	}
+	switch {
+	case sad == 0 && anxious == 0 && happy == 0:
+		return Neutral
+	case sad >= anxious && sad >= happy:
+		return Sad
+	case anxious >= happy:
+		return Anxious
+	default:
+		return Happy
+	}
+}
+
+// Choose 根据老人最后一句话与回复的情绪选择回复语音的情绪
+// 老人难过或焦虑时始终安抚, 否则跟随回复的情绪
+func Choose(user, reply string) string {
+	if user == Sad || user == Anxious {
+		return user
+	}
+	if reply != Neutral && reply != "" {
+		return reply
+	}
+	if user == "" {
+		return Neutral
+	}
+	return user
+}
+
+func count(text string, words []string) int {
+	n := 0
+	for _, w := range words {
+		n += strings.Count(text, w)
+	}
+	return n
+}
+
+func negated(prefix string) bool {
+	for _, n := range negations {
+		if strings.HasSuffix(prefix, n) {
+			return true
+		}
+	}
+	return false
+}
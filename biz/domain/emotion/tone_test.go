@@ -0,0 +1,54 @@
+package emotion
+
+import (
+	"testing"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		"老伴去世以后我一个人挺孤单的":  Sad,
+		"这几天老是头晕, 有点担心":   Anxious,
+		"今天孙子来看我了, 真开心":   Happy,
+		"我今天不开心":          Sad,
+		"今天吃了饺子":          Neutral,
+		"挺开心的, 就是有点想念老朋友": Sad,
+	}
+	for text, want := range cases {
+		if got := Detect(text); got != want {
+			t.Errorf("Detect(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestChoose(t *testing.T) {
+	if got := Choose(Sad, Happy); got != Sad {
+		t.Errorf("sad senior should keep sad, got %s", got)
+	}
+	if got := Choose(Neutral, Happy); got != Happy {
+		t.Errorf("got %s", got)
+	}
+	if got := Choose(Happy, Neutral); got != Happy {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestStyler(t *testing.T) {
+	s := NewStyler(config.VoiceStyle{
+		Default:  map[string]config.Style{Happy: {Name: "bright", SpeechRate: 10}},
+		Speakers: map[string]map[string]config.Style{"v1": {Sad: {Name: "soft", Emotion: "sad"}}},
+	})
+	if got := s.Style("v1", Sad); got.Name != "soft" || got.Emotion != "sad" {
+		t.Errorf("speaker style = %+v", got)
+	}
+	if got := s.Style("v1", Happy); got.Name != "bright" || got.SpeechRate != 10 {
+		t.Errorf("default style = %+v", got)
+	}
+	if got := s.Style("v2", Sad); got.Name != "gentle" || got.SpeechRate >= 0 {
+		t.Errorf("builtin style = %+v", got)
+	}
+	if got := NewStyler(config.VoiceStyle{Disable: true}).Style("v1", Sad); got.Name != "" {
+		t.Errorf("disabled style = %+v", got)
+	}
+}
@@ -0,0 +1,117 @@
+package hotword
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/xh-polaris/psych-senior/biz/application/dto"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/mapper/hotword"
+)
+
+// 识别上下文的限制, 过多的热词会降低识别的准确率
+const (
+	// MaxWords 每位老人最多的热词数
+	MaxWords = 100
+	// MaxWordChars 单个热词的最多字数
+	MaxWordChars = 10
+	// maxDialog 作为上下文的最近对话条数
+	maxDialog = 5
+	// maxDialogChars 每条对话最多保留的字数, 保留结尾部分
+	maxDialogChars = 100
+)
+
+// Hotword 老人的识别热词
+type Hotword = hotword.Hotword
+
+// Store 老人热词的读取与保存
+type Store struct {
+	mapper hotword.IMongoMapper
+}
+
+var (
+	store *Store
+	once  sync.Once
+)
+
+func GetStore() *Store {
+	once.Do(func() {
+		store = &Store{mapper: hotword.GetMongoMapper()}
+	})
+	return store
+}
+
+// Load 读取老人的热词, 没有保存过时返回空的热词
+func (s *Store) Load(ctx context.Context, seniorId string) (*Hotword, error) {
+	h, err := s.mapper.FindOne(ctx, seniorId)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		h = &Hotword{SeniorId: seniorId}
+	}
+	return h, nil
+}
+
+// Save 清理后保存老人的热词, 热词过多或过长时返回参数错误
+func (s *Store) Save(ctx context.Context, h *Hotword) error {
+	for _, list := range []*[]string{&h.Family, &h.Pets, &h.Places, &h.Medications, &h.Others} {
+		*list = clean(*list)
+		for _, w := range *list {
+			if utf8.RuneCountInString(w) > MaxWordChars {
+				return consts.ErrInvalidParam
+			}
+		}
+	}
+	if len(Words(h)) > MaxWords {
+		return consts.ErrInvalidParam
+	}
+	return s.mapper.Upsert(ctx, h)
+}
+
+// Words 合并各类热词并去重, 家人与药品优先
+func Words(h *Hotword) []string {
+	var words []string
+	seen := make(map[string]bool)
+	for _, list := range [][]string{h.Family, h.Medications, h.Pets, h.Places, h.Others} {
+		for _, w := range list {
+			if !seen[w] {
+				seen[w] = true
+				words = append(words, w)
+			}
+		}
+	}
+	return words
+}
+
+// Recent 最近几条对话的内容, 作为识别的上下文, 跳过系统提示词
+func Recent(histories []*dto.ChatHistory) []string {
+	var dialog []string
+	for _, his := range histories {
+		if his.Role == consts.RoleSystem || his.Content == "" {
+			continue
+		}
+		text := []rune(his.Content)
+		if len(text) > maxDialogChars {
+			text = text[len(text)-maxDialogChars:]
+		}
+		dialog = append(dialog, string(text))
+	}
+	if len(dialog) > maxDialog {
+		dialog = dialog[len(dialog)-maxDialog:]
+	}
+	return dialog
+}
+
+// clean 去掉空白与空的热词
+func clean(words []string) []string {
+	out := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
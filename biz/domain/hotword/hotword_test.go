@@ -0,0 +1,36 @@
+package hotword
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xh-polaris/psych-senior/biz/application/dto"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+)
+
+func TestWords(t *testing.T) {
+	h := &Hotword{
+		Family:      []string{"小宝", "老伴"},
+		Pets:        []string{"小宝"},
+		Medications: []string{"阿司匹林"},
+	}
+	if got := strings.Join(Words(h), ","); got != "小宝,老伴,阿司匹林" {
+		t.Errorf("words = %s", got)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	histories := []*dto.ChatHistory{{Role: consts.RoleSystem, Content: "你好呀"}}
+	for i := 0; i < maxDialog+2; i++ {
+		histories = append(histories, &dto.ChatHistory{Role: consts.RoleUser, Content: strings.Repeat("字", maxDialogChars+i)})
+	}
+	dialog := Recent(histories)
+	if len(dialog) != maxDialog {
+		t.Fatalf("got %d dialogs", len(dialog))
+	}
+	for _, d := range dialog {
+		if n := len([]rune(d)); n != maxDialogChars {
+			t.Errorf("dialog length = %d", n)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package language
+
+import (
+	"sync"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+)
+
+// Language 一种语言或方言的配置, 未配置的服务已替换为默认的服务
+type Language = config.Language
+
+// Registry 支持的语言与方言, 新增方言只需修改配置
+type Registry struct {
+	langs []*Language
+	index map[string]*Language
+}
+
+var (
+	registry *Registry
+	once     sync.Once
+)
+
+func GetRegistry() *Registry {
+	once.Do(func() {
+		registry = NewRegistry(config.GetConfig())
+	})
+	return registry
+}
+
+// NewRegistry 由配置生成, 未配置Languages时使用内置的普通话与沪语
+func NewRegistry(c *config.Config) *Registry {
+	langs := c.Languages
+	if len(langs) == 0 {
+		langs = builtin(c)
+	}
+	r := &Registry{index: make(map[string]*Language)}
+	for _, l := range langs {
+		if l.Code == "" || r.index[l.Code] != nil {
+			log.Error("invalid or duplicate language:", l.Code)
+			continue
+		}
+		if l.Chat.AppId == "" {
+			l.Chat, l.ChatFailover = c.BaiLianChat, c.Failover.BaiLianChat
+		}
+		switch l.Tts.Backend {
+		case "":
+			l.Tts.Backend = consts.VolcTts
+		case consts.VolcTts, consts.VolcNoModelTts:
+		default:
+			log.Error("invalid tts backend of language "+l.Code+":", l.Tts.Backend)
+			continue
+		}
+		if l.Tts.Backend == consts.VolcNoModelTts && l.Tts.Lang == "" {
+			l.Tts.Lang = c.VolcNoModelTts.Lang
+		}
+		if l.Name == "" {
+			l.Name = l.Code
+		}
+		l.Scripts = withDefaults(l.Scripts, defaultScripts)
+		r.langs = append(r.langs, &l)
+		r.index[l.Code] = &l
+	}
+	return r
+}
+
+// builtin 普通话使用双向流式合成, 沪语使用沪语对话应用与逐句合成
+func builtin(c *config.Config) []Language {
+	langs := []Language{{Code: "zh", Name: "普通话"}}
+	if c.BaiLianShanghaiChat.AppId != "" {
+		langs = append(langs, Language{
+			Code:         "zh-shanghai",
+			Name:         "上海话",
+			Chat:         c.BaiLianShanghaiChat,
+			ChatFailover: c.Failover.BaiLianShanghaiChat,
+			Tts:          config.LanguageTts{Backend: consts.VolcNoModelTts},
+			Scripts:      shanghaiScripts,
+		})
+	}
+	return langs
+}
+
+// Get 获取一种语言, 不支持时返回false
+func (r *Registry) Get(code string) (*Language, bool) {
+	l, ok := r.index[code]
+	return l, ok
+}
+
+// List 按配置顺序返回所有语言
+func (r *Registry) List() []*Language {
+	return r.langs
+}
@@ -0,0 +1,62 @@
+package language
+
+import (
+	"testing"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+)
+
+func TestBuiltin(t *testing.T) {
+	c := &config.Config{
+		BaiLianChat:         config.BaiLianChat{AppId: "mandarin"},
+		BaiLianShanghaiChat: config.BaiLianChat{AppId: "shanghai", PromptVersion: "v2"},
+		VolcNoModelTts:      config.VolcNoModelTts{Lang: "zh-shanghai"},
+	}
+	r := NewRegistry(c)
+	if got := len(r.List()); got != 2 {
+		t.Fatalf("got %d languages", got)
+	}
+	zh, _ := r.Get("zh")
+	if zh.Chat.AppId != "mandarin" || zh.Tts.Backend != consts.VolcTts {
+		t.Errorf("zh = %+v", zh)
+	}
+	sh, _ := r.Get("zh-shanghai")
+	if sh.Chat.AppId != "shanghai" || sh.Tts.Backend != consts.VolcNoModelTts || sh.Tts.Lang != "zh-shanghai" {
+		t.Errorf("zh-shanghai = %+v", sh)
+	}
+	if _, ok := r.Get("en"); ok {
+		t.Error("en should not be supported")
+	}
+	if zh.Scripts.Greeting != defaultScripts.Greeting || sh.Scripts.Greeting != shanghaiScripts.Greeting || sh.Scripts.Goodbye != shanghaiScripts.Goodbye {
+		t.Errorf("unexpected scripts %+v, %+v", zh.Scripts, sh.Scripts)
+	}
+}
+
+func TestConfigured(t *testing.T) {
+	c := &config.Config{
+		BaiLianChat: config.BaiLianChat{AppId: "mandarin"},
+		Languages: []config.Language{
+			{Code: "zh-cantonese", Name: "粤语", Chat: config.BaiLianChat{AppId: "cantonese"},
+				Tts: config.LanguageTts{Backend: consts.VolcNoModelTts, Lang: "zh-cantonese", Speaker: "yue"}},
+			{Code: "zh-sichuan", Tts: config.LanguageTts{Speaker: "chuan"}},
+			{Code: "zh-sichuan"},
+			{Code: "bad", Tts: config.LanguageTts{Backend: "unknown"}},
+		},
+	}
+	r := NewRegistry(c)
+	if got := len(r.List()); got != 2 {
+		t.Fatalf("got %d languages", got)
+	}
+	if _, ok := r.Get("zh"); ok {
+		t.Error("builtin languages should be replaced")
+	}
+	yue, _ := r.Get("zh-cantonese")
+	if yue.Chat.AppId != "cantonese" || yue.Tts.Lang != "zh-cantonese" || yue.Tts.Speaker != "yue" {
+		t.Errorf("zh-cantonese = %+v", yue)
+	}
+	chuan, _ := r.Get("zh-sichuan")
+	if chuan.Chat.AppId != "mandarin" || chuan.Tts.Backend != consts.VolcTts || chuan.Name != "zh-sichuan" {
+		t.Errorf("zh-sichuan = %+v", chuan)
+	}
+}
@@ -0,0 +1,34 @@
+package language
+
+import "github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+
+// Scripts 一种语言的固定话术
+type Scripts = config.Scripts
+
+// defaultScripts 未配置时使用的普通话话术
+var defaultScripts = Scripts{
+	Greeting: "你好呀，今天过得怎么样？",
+	Goodbye:  "那我们今天就先聊到这里，您注意休息，再见！",
+}
+
+// shanghaiScripts 内置沪语的话术
+var shanghaiScripts = Scripts{
+	Greeting: "侬好呀，今朝过得哪能？",
+	Goodbye:  "阿拉今朝就先讲到此地，侬好好叫休息，再会！",
+}
+
+// withDefaults 为空的项使用默认话术
+func withDefaults(s, d Scripts) Scripts {
+	if s.Greeting == "" {
+		s.Greeting = d.Greeting
+	}
+	if s.Goodbye == "" {
+		s.Goodbye = d.Goodbye
+	}
+	return s
+}
+
+// Texts 需要缓存音频的话术
+func Texts(s Scripts) []string {
+	return []string{s.Greeting, s.Goodbye}
+}
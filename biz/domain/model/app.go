@@ -1,16 +1,51 @@
 package model
 
 import (
+	"context"
+	"time"
+
 	"github.com/xh-polaris/psych-senior/biz/application/dto"
 )
 
+// 对话消息的角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message 一条对话消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatOptions 对话调用的选项
+type ChatOptions struct {
+	// SessionId 使用云端上下文管理时的会话id, 为空表示新的会话
+	SessionId string
+	// Parameters 透传给第三方的额外参数
+	Parameters map[string]any
+}
+
+// Completion 一次完整调用的结果
+type Completion struct {
+	Content   string
+	SessionId string
+	Model     string
+	Finish    string
+	Usage     *dto.Usage
+}
+
 // ChatApp 是第三方对话大模型应用的抽象
+// 摘要, 标题, 分类等一次性的调用使用Complete, 实时对话使用Stream
 type ChatApp interface {
-	// Call 整体调用
-	Call(msg string) error
+	// Complete 整体调用, 返回完整的结果
+	Complete(ctx context.Context, messages []*Message, opts *ChatOptions) (*Completion, error)
 
-	// StreamCall 流式调用, 默认应该采用增量输出, 即后续的输出不包括之前的输出
-	StreamCall(msg string, sessionId string) (ChatAppScanner, error)
+	// Stream 流式调用, 默认应该采用增量输出, 即后续的输出不包括之前的输出
+	// ctx结束时中断响应的读取
+	Stream(ctx context.Context, messages []*Message, opts *ChatOptions) (ChatAppScanner, error)
 
 	// Close 关闭资源
 	Close() error
@@ -25,12 +60,18 @@ type ChatAppScanner interface {
 // ReportApp 是第三方报告分析大模型应用的抽象
 type ReportApp interface {
 	// Call 获取报告结果
-	Call(msg string) (*dto.Report, error)
+	Call(ctx context.Context, msg string) (*dto.Report, error)
 
 	// Close 关闭资源
 	Close() error
 }
 
+// 语音合成与识别使用的音频编码, 为空时使用pcm
+const (
+	EncodingPCM     = "pcm"
+	EncodingOggOpus = "ogg_opus"
+)
+
 // TtsApp 是第三方语音合成大模型的抽象
 type TtsApp interface {
 	// Dial 建立ws连接
@@ -42,13 +83,90 @@ type TtsApp interface {
 	// Send 发送文字请求
 	Send(texts string) error
 
-	// Receive 接受音频流响应
-	Receive() []byte
+	// Receive 接受音频流响应, 没有音频时返回nil, 连接关闭后返回io.EOF
+	Receive() ([]byte, error)
 
 	// Close 断开连接, 释放资源
 	Close() error
 }
 
+// Voice 合成音频的音色与格式, 相同的Voice合成的同一段文字音频相同
+type Voice struct {
+	Speaker    string
+	Format     string
+	SampleRate int
+	Speed      float64
+}
+
+// PhraseSynthesizer 支持一次性合成整段文字的语音合成, 用于缓存固定话术的音频
+type PhraseSynthesizer interface {
+	// Voice 当前使用的音色与格式
+	Voice() Voice
+
+	// Synthesize 合成一段文字, 返回完整的音频
+	Synthesize(text string) ([]byte, error)
+}
+
+// ReconnectState 长连接自动重连的状态
+type ReconnectState int
+
+const (
+	// Reconnecting 连接断开, 开始重连
+	Reconnecting ReconnectState = iota
+	// Reconnected 重连成功, 未完成的数据已重发
+	Reconnected
+	// ReconnectFailed 重连失败
+	ReconnectFailed
+)
+
+// Reconnectable 断线后自动重连的长连接
+type Reconnectable interface {
+	// OnReconnect 设置重连状态变化的回调
+	OnReconnect(f func(state ReconnectState))
+}
+
+// Word 字幕中一个字词的时间, 相对于所在句子音频的开始
+type Word struct {
+	Text  string
+	Start time.Duration
+	End   time.Duration
+}
+
+// Caption 合成语音的字幕事件
+type Caption struct {
+	// Text 句子的文字
+	Text string
+	// End 为false表示句子开始合成, 为true表示句子合成完毕, 此时Words为各字词的时间
+	End   bool
+	Words []Word
+}
+
+// Captioner 支持字幕时间戳的语音合成
+type Captioner interface {
+	// OnCaption 设置字幕事件的回调, 在Receive中调用, 与音频的顺序一致
+	OnCaption(f func(c *Caption))
+}
+
+// Style 合成语音的风格, 各项调整为0时使用默认值
+type Style struct {
+	// Name 风格名称, 随对话记录保存
+	Name string
+	// Speaker 音色, 为空时使用默认的音色
+	Speaker string
+	// Emotion 音色的情感, 需要音色支持多情感
+	Emotion string
+	// SpeechRate, PitchRate, Volume 语速, 音调, 音量的调整, 取值-50~100, 0为默认
+	SpeechRate int
+	PitchRate  int
+	Volume     int
+}
+
+// Stylable 支持调整语音风格的语音合成
+type Stylable interface {
+	// SetStyle 设置之后发送的文字使用的风格
+	SetStyle(s Style)
+}
+
 // AsrApp 是第三方通用语音识别的抽象
 type AsrApp interface {
 	// Dial 建立ws连接
@@ -63,9 +181,47 @@ type AsrApp interface {
 	// Last 最后一个包
 	Last() error
 
-	// Receive 接受文字
-	Receive() (string, error)
+	// Receive 接受识别结果, 没有结果时返回nil, 服务端识别完毕后返回io.EOF
+	Receive() (*AsrResult, error)
 
 	// Close  关闭连接, 释放资源
 	Close() error
 }
+
+// Utterance 识别出的一句话
+type Utterance struct {
+	Text string
+	// Definite 句子已经确定, 之后不会再修改, 否则为中间结果
+	Definite bool
+	// Start, End 句子在本次识别的音频中的起止时间
+	Start time.Duration
+	End   time.Duration
+	// Confidence 置信度, 0~1, 服务端未返回时为0
+	Confidence float64
+}
+
+// AsrResult 一次识别响应
+type AsrResult struct {
+	// Text 服务端返回的文本
+	Text string
+	// Utterances 新确定的句子与当前未确定的句子, 已经返回过的确定的句子不再包含
+	Utterances []Utterance
+}
+
+// AsrContext 个性化的识别上下文
+type AsrContext struct {
+	// Uid 用户标记, 为空时使用连接id
+	Uid string
+	// Hotwords 热词, 如家人的名字, 药品名
+	Hotwords []string
+	// Dialog 最近的对话内容, 按时间顺序
+	Dialog []string
+	// Reload 重新读取最近的对话内容, 每次开始识别与重连时调用, 失败时沿用Dialog, 为nil时不刷新
+	Reload func() ([]string, error)
+}
+
+// Contextual 支持个性化上下文的语音识别
+type Contextual interface {
+	// SetContext 设置识别上下文, 需要在Start之前调用, 重连后同样生效
+	SetContext(c *AsrContext)
+}
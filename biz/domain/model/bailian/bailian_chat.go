@@ -1,17 +1,17 @@
 package bailian
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/xh-polaris/psych-senior/biz/application/dto"
 	"github.com/xh-polaris/psych-senior/biz/domain/model"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/sse"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/util"
 	"io"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -24,7 +24,6 @@ type BLChatApp struct {
 	apiKey string
 	url    string
 	header http.Header
-	body   map[string]any
 }
 
 // NewBLChatApp 创建一个百炼模型应用实例
@@ -34,39 +33,71 @@ func NewBLChatApp(appId string, apiKey string) model.ChatApp {
 		apiKey: apiKey,
 		url:    fmt.Sprintf("https://dashscope.aliyuncs.com/api/v1/apps/%s/completion", appId),
 		header: http.Header{},
-		body:   make(map[string]any),
 	}
 
-	// 初始化请求模板
-	app.body["input"] = make(map[string]string)
-	// 设置增量流式响应
-	app.body["parameters"] = map[string]any{
-		"incremental_output": true,
-	}
-
-	// 设置请求头,其中X-DashScope-SSE设置为enable，表示开启流式响应
+	// 设置请求头
 	app.header.Set("Authorization", "Bearer "+apiKey)
 	app.header.Set("Content-Type", "application/json")
-	app.header.Set("X-DashScope-SSE", "enable")
 
 	return app
 }
 
-// Call 非流式调用，暂时没用上
-func (app *BLChatApp) Call(msg string) error {
-	panic("implement me")
+// body 构造请求体, 每次调用单独构造, 避免并发调用互相覆盖
+// 只有一条消息时作为prompt, 配合session_id使用云端上下文; 多条消息时以messages传入完整上下文
+func (app *BLChatApp) body(messages []*model.Message, opts *model.ChatOptions, incremental bool) map[string]any {
+	if opts == nil {
+		opts = &model.ChatOptions{}
+	}
+	input := map[string]any{}
+	if len(messages) == 1 {
+		input["prompt"] = messages[0].Content
+	} else {
+		input["messages"] = messages
+	}
+	if opts.SessionId != "" {
+		input["session_id"] = opts.SessionId
+	}
+	parameters := map[string]any{}
+	for k, v := range opts.Parameters {
+		parameters[k] = v
+	}
+	if incremental {
+		// 设置增量流式响应
+		parameters["incremental_output"] = true
+	}
+	return map[string]any{
+		"input":      input,
+		"parameters": parameters,
+	}
+}
+
+// Complete 非流式调用
+func (app *BLChatApp) Complete(ctx context.Context, messages []*model.Message, opts *model.ChatOptions) (*model.Completion, error) {
+	var raw bLRawChatData
+	client := util.GetHttpClient()
+	if err := client.ReqInto(ctx, consts.Post, app.url, app.header, app.body(messages, opts, false), &raw); err != nil {
+		return nil, err
+	}
+	data := raw.toChatData()
+	return &model.Completion{
+		Content:   data.Content,
+		SessionId: data.SessionId,
+		Model:     data.Model,
+		Finish:    data.Finish,
+		Usage:     data.Usage,
+	}, nil
 }
 
-// StreamCall 流式调用
-func (app *BLChatApp) StreamCall(msg string, sessionId string) (model.ChatAppScanner, error) {
+// Stream 流式调用
+func (app *BLChatApp) Stream(ctx context.Context, messages []*model.Message, opts *model.ChatOptions) (model.ChatAppScanner, error) {
 	client := util.GetHttpClient()
 
-	// 设置调用提示词
-	app.body["input"].(map[string]string)["prompt"] = msg
-	app.body["input"].(map[string]string)["session_id"] = sessionId
+	// 设置请求头,其中X-DashScope-SSE设置为enable，表示开启流式响应
+	header := app.header.Clone()
+	header.Set("X-DashScope-SSE", "enable")
 
 	// 获取流式响应reader
-	reader, err := client.StreamReq(consts.Post, app.url, app.header, app.body)
+	reader, err := client.StreamReq(ctx, consts.Post, app.url, header, app.body(messages, opts, true))
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +113,7 @@ func (app *BLChatApp) Close() error {
 // BLChatAppScanner 是百炼对话调用的响应
 type BLChatAppScanner struct {
 	closer  io.ReadCloser
-	scanner *bufio.Scanner
+	decoder *sse.Decoder
 }
 
 // bLRawChatData 是百炼模型的原始响应
@@ -94,6 +125,11 @@ type bLRawChatData struct {
 	} `json:"output"`
 
 	Usage struct {
+		Models []struct {
+			ModelId      string `json:"model_id"`
+			InputTokens  int64  `json:"input_tokens"`
+			OutputTokens int64  `json:"output_tokens"`
+		} `json:"models"`
 	} `json:"usage"`
 }
 
@@ -102,48 +138,65 @@ type bLRawChatData struct {
 func newBLChatAppScanner(r io.ReadCloser) *BLChatAppScanner {
 	return &BLChatAppScanner{
 		closer:  r,
-		scanner: bufio.NewScanner(r),
+		decoder: sse.NewDecoder(r),
 	}
 }
 
 // Next 返回下一个读取到的对象或错误
 func (s *BLChatAppScanner) Next() (*dto.ChatData, error) {
-	var data dto.ChatData
-	var err error
-
-	for s.scanner.Scan() {
-		line := strings.TrimSpace(s.scanner.Text())
-		// 跳过空行
-		if line == "" {
-			continue
+	for {
+		ev, err := s.decoder.Next()
+		if err != nil {
+			// 流结束时返回io.EOF
+			return nil, err
 		}
-
-		switch {
-		// 解析id
-		case strings.HasPrefix(line, "id:"):
-			if data.Id, err = strconv.ParseUint(strings.TrimPrefix(line, "id:"), 10, 64); err != nil {
-				return nil, err
-			}
-		// 解析消息主体
-		case strings.HasPrefix(line, "data:"):
-			var raw bLRawChatData
-			if err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &raw); err != nil {
-				return nil, err
-			}
-			data.SessionId = raw.Output.SessionId
-			data.Content = raw.Output.Text
-			data.Finish = raw.Output.FinishReason
-			data.Timestamp = time.Now().Unix()
-			return &data, nil
+		switch ev.Type {
+		case eventError:
+			return nil, newAPIError(ev.Data)
+		case eventResult, sse.DefaultEvent:
+			return parseChatData(ev)
+		default:
+			// 其他事件暂时忽略
 		}
 	}
+}
 
-	if err = s.scanner.Err(); err != nil {
+// parseChatData 解析消息主体
+func parseChatData(ev *sse.Event) (*dto.ChatData, error) {
+	var raw bLRawChatData
+	var id uint64
+	var err error
+	if ev.Id != "" {
+		if id, err = strconv.ParseUint(ev.Id, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	if err = json.Unmarshal([]byte(ev.Data), &raw); err != nil {
 		return nil, err
 	}
+	data := raw.toChatData()
+	data.Id = id
+	return data, nil
+}
 
-	// 没有更多内容
-	return nil, io.EOF
+// toChatData 转换为通用的响应
+func (raw *bLRawChatData) toChatData() *dto.ChatData {
+	data := &dto.ChatData{
+		SessionId: raw.Output.SessionId,
+		Content:   raw.Output.Text,
+		Finish:    raw.Output.FinishReason,
+		Timestamp: time.Now().Unix(),
+	}
+	// 应用可能调用多个模型, 用量累加, 模型取第一个
+	for i, m := range raw.Usage.Models {
+		if i == 0 {
+			data.Model = m.ModelId
+			data.Usage = &dto.Usage{}
+		}
+		data.Usage.InputTokens += m.InputTokens
+		data.Usage.OutputTokens += m.OutputTokens
+	}
+	return data
 }
 
 // Close 释放资源
@@ -1,14 +1,19 @@
 package bailian
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
+
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
 )
 
 func TestBaiLianChatApp_StreamCall(t *testing.T) {
 	app := NewBLChatApp("d37840a0f7d6490f87952dd3ca0bb441", "sk-02654c3231f54c90b3500a1b75003e5f")
-	scanner, err := app.StreamCall("你好", "")
+	scanner, err := app.Stream(context.Background(), []*model.Message{{Role: model.RoleUser, Content: "你好"}}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -25,3 +30,28 @@ func TestBaiLianChatApp_StreamCall(t *testing.T) {
 		fmt.Println(data)
 	}
 }
+
+func TestBaiLianChatApp_Complete(t *testing.T) {
+	app := NewBLChatApp("d37840a0f7d6490f87952dd3ca0bb441", "sk-02654c3231f54c90b3500a1b75003e5f")
+	c, err := app.Complete(context.Background(), []*model.Message{{Role: model.RoleUser, Content: "你好"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(c.Content, c.SessionId, c.Model, c.Usage)
+}
+
+func TestBLChatAppScanner_Next(t *testing.T) {
+	stream := "id:1\nevent:result\n:HTTP_STATUS/200\ndata:{\"output\":{\"session_id\":\"s\",\"text\":\"你好\"}}\n\n" +
+		"id:2\nevent:error\n:HTTP_STATUS/400\ndata:{\"code\":\"InvalidParameter\",\"message\":\"bad\",\"request_id\":\"r\"}\n\n"
+	scanner := newBLChatAppScanner(io.NopCloser(strings.NewReader(stream)))
+
+	data, err := scanner.Next()
+	if err != nil || data.Id != 1 || data.Content != "你好" || data.SessionId != "s" {
+		t.Fatalf("unexpected data %v, err %v", data, err)
+	}
+	_, err = scanner.Next()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "InvalidParameter" || apiErr.RequestId != "r" {
+		t.Fatalf("unexpected err %v", err)
+	}
+}
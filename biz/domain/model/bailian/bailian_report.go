@@ -1,6 +1,7 @@
 package bailian
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/xh-polaris/gopkg/util/log"
@@ -12,28 +13,38 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 )
 
+// DefaultReportTimeout 报告生成的默认超时时间, 报告生成耗时远长于普通请求, 不使用HttpClient的默认超时
+const DefaultReportTimeout = 3 * time.Minute
+
 var _ model.ReportApp = (*BLReportApp)(nil)
 
 // BLReportApp 是阿里云报告分析大模型应用
 // 单次对话, 无需管理上下文
 type BLReportApp struct {
-	appId  string
-	apiKey string
-	url    string
-	header http.Header
-	body   map[string]any
+	appId   string
+	apiKey  string
+	url     string
+	timeout time.Duration
+	header  http.Header
+	body    map[string]any
 }
 
 // NewBLReportApp 创建一个百炼报告分析模型应用实例
-func NewBLReportApp(appId string, apiKey string) model.ReportApp {
+// timeout 单位为ms, 不大于0时使用默认值
+func NewBLReportApp(appId string, apiKey string, timeout int64) model.ReportApp {
 	app := &BLReportApp{
-		appId:  appId,
-		apiKey: apiKey,
-		url:    fmt.Sprintf("https://dashscope.aliyuncs.com/api/v1/apps/%s/completion", appId),
-		header: http.Header{},
-		body:   make(map[string]any),
+		appId:   appId,
+		apiKey:  apiKey,
+		url:     fmt.Sprintf("https://dashscope.aliyuncs.com/api/v1/apps/%s/completion", appId),
+		timeout: DefaultReportTimeout,
+		header:  http.Header{},
+		body:    make(map[string]any),
+	}
+	if timeout > 0 {
+		app.timeout = time.Duration(timeout) * time.Millisecond
 	}
 
 	// 初始化请求模板
@@ -55,19 +66,19 @@ var once sync.Once
 func GetBLReportApp() model.ReportApp {
 	once.Do(func() {
 		c := config.GetConfig()
-		instance = NewBLReportApp(c.BaiLianReport.AppId, c.BaiLianReport.ApiKey)
+		instance = NewBLReportApp(c.BaiLianReport.AppId, c.BaiLianReport.ApiKey, c.BaiLianReport.Timeout)
 	})
 	return instance
 }
 
-func (app *BLReportApp) Call(prompt string) (*dto.Report, error) {
+func (app *BLReportApp) Call(ctx context.Context, prompt string) (*dto.Report, error) {
 	var err error
 	var report dto.Report
 	client := util.GetHttpClient()
 
 	// 设置调用提示词
 	app.body["input"].(map[string]string)["prompt"] = prompt
-	res, err := client.Req(consts.Post, app.url, app.header, app.body)
+	res, err := client.Req(ctx, consts.Post, app.url, app.header, app.body, util.Idempotent(), util.Timeout(app.timeout))
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package bailian
 
 import (
+	"context"
 	"fmt"
 	"testing"
 )
@@ -12,7 +13,7 @@ func TestBaiLianReportApp_Call(t *testing.T) {
 	)
 
 	// 创建应用实例
-	app := NewBLReportApp(appId, apiKey)
+	app := NewBLReportApp(appId, apiKey, 0)
 	defer func() { _ = app.Close() }()
 
 	// 完整对话文本（注意保留换行符）
@@ -26,7 +27,7 @@ AI:好朋友的态度变化确实让人困扰，你们之前有发生过什么
 AI:这些压力交织在一起确实不容易，我们试试用呼吸法缓解紧张好吗?`
 
 	// 调用大模型进行综合分析
-	resp, err := app.Call(msg)
+	resp, err := app.Call(context.Background(), msg)
 	if err != nil {
 		fmt.Printf("API调用失败: %v\n", err)
 		return
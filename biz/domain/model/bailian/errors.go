@@ -0,0 +1,32 @@
+package bailian
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 百炼流式响应的事件类型
+const (
+	eventResult = "result"
+	eventError  = "error"
+)
+
+// APIError 百炼返回的错误, 流式响应中以error事件返回
+type APIError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestId string `json:"request_id"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("bailian error: code=%s, message=%s, request id=%s", e.Code, e.Message, e.RequestId)
+}
+
+// newAPIError 解析error事件, 无法解析时保留原始内容
+func newAPIError(data string) *APIError {
+	var e APIError
+	if err := json.Unmarshal([]byte(data), &e); err != nil || (e.Code == "" && e.Message == "") {
+		return &APIError{Message: data}
+	}
+	return &e
+}
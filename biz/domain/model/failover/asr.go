@@ -0,0 +1,117 @@
+package failover
+
+import (
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+)
+
+var (
+	_ model.Reconnectable = (*AsrApp)(nil)
+	_ model.Contextual    = (*AsrApp)(nil)
+)
+
+// AsrApp 带有备用后端的语音识别
+// 识别的上下文保存在服务端, 所以只在建立连接时切换后端, 断线后由后端自身重连
+type AsrApp struct {
+	backends []*backend[model.AsrApp]
+	app      model.AsrApp
+	idx      int
+	notify   func(state model.ReconnectState)
+	context  *model.AsrContext
+}
+
+func newAsrApp(backends []*backend[model.AsrApp]) *AsrApp {
+	return &AsrApp{backends: backends}
+}
+
+// Dial 依次尝试各后端, 同时完成连接与握手, 以便握手失败时也能切换
+func (a *AsrApp) Dial() error {
+	for i, b := range a.backends {
+		call, ok := b.breaker.Allow()
+		if !ok {
+			continue
+		}
+		app := b.new()
+		if c, ok := app.(model.Contextual); ok && a.context != nil {
+			c.SetContext(a.context)
+		}
+		start := time.Now()
+		err := app.Dial()
+		if err == nil {
+			err = app.Start()
+		}
+		call.Mark(err, time.Since(start))
+		if err != nil {
+			_ = app.Close()
+			metrics.Failover.Inc(slotAsr, b.breaker.Name())
+			log.Error("asr backend "+b.breaker.Name()+" failed:", err)
+			continue
+		}
+		a.app, a.idx = app, i
+		if r, ok := app.(model.Reconnectable); ok && a.notify != nil {
+			r.OnReconnect(a.notify)
+		}
+		return nil
+	}
+	return ErrUnavailable
+}
+
+// SetContext 设置识别上下文, 需要在Dial之前调用
+func (a *AsrApp) SetContext(c *model.AsrContext) {
+	a.context = c
+}
+
+// Start 握手已在Dial中完成
+func (a *AsrApp) Start() error {
+	if a.app == nil {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// Send 发送音频流
+func (a *AsrApp) Send(bytes []byte) error {
+	if a.app == nil {
+		return ErrUnavailable
+	}
+	err := a.app.Send(bytes)
+	if err != nil {
+		a.backends[a.idx].breaker.Report(err)
+	}
+	return err
+}
+
+// Last 最后一个包
+func (a *AsrApp) Last() error {
+	if a.app == nil {
+		return ErrUnavailable
+	}
+	return a.app.Last()
+}
+
+// Receive 接受识别结果
+func (a *AsrApp) Receive() (*model.AsrResult, error) {
+	if a.app == nil {
+		return nil, ErrUnavailable
+	}
+	return a.app.Receive()
+}
+
+// OnReconnect 设置重连状态变化的回调, 需在Dial之前调用
+func (a *AsrApp) OnReconnect(f func(state model.ReconnectState)) {
+	a.notify = f
+	if r, ok := a.app.(model.Reconnectable); ok {
+		r.OnReconnect(f)
+	}
+}
+
+// Close 关闭连接
+func (a *AsrApp) Close() error {
+	if a.app == nil {
+		return nil
+	}
+	return a.app.Close()
+}
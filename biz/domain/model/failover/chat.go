@@ -0,0 +1,175 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/application/dto"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+)
+
+// ChatApp 带有备用后端的对话应用, 每轮调用选择第一个可用的后端
+// 切换只发生在一轮调用开始时, 预读到首个响应后才视为调用成功, 之后的错误不再切换
+// 各后端的会话相互独立, 由ChatApp分别记录sessionId, 调用方传入的SessionId不再使用
+type ChatApp struct {
+	backends []*backend[model.ChatApp]
+
+	mu       sync.Mutex
+	apps     []model.ChatApp
+	sessions []string
+}
+
+func newChatApp(backends []*backend[model.ChatApp]) *ChatApp {
+	return &ChatApp{
+		backends: backends,
+		apps:     make([]model.ChatApp, len(backends)),
+		sessions: make([]string, len(backends)),
+	}
+}
+
+// app 获取后端实例, 不存在时创建
+func (a *ChatApp) app(i int) model.ChatApp {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.apps[i] == nil {
+		a.apps[i] = a.backends[i].new()
+	}
+	return a.apps[i]
+}
+
+func (a *ChatApp) session(i int) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sessions[i]
+}
+
+func (a *ChatApp) setSession(i int, sessionId string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.sessions[i] == "" {
+		a.sessions[i] = sessionId
+	}
+}
+
+// options 替换为对应后端的会话id
+func (a *ChatApp) options(i int, opts *model.ChatOptions) *model.ChatOptions {
+	o := model.ChatOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.SessionId = a.session(i)
+	return &o
+}
+
+// Complete 依次尝试各后端, 返回第一个成功的结果
+func (a *ChatApp) Complete(ctx context.Context, messages []*model.Message, opts *model.ChatOptions) (*model.Completion, error) {
+	errs := []error{ErrUnavailable}
+	for i, b := range a.backends {
+		call, ok := b.breaker.Allow()
+		if !ok {
+			continue
+		}
+		start := time.Now()
+		c, err := a.app(i).Complete(ctx, messages, a.options(i, opts))
+		if err != nil && ctx.Err() != nil {
+			// 调用方主动取消, 不视为后端故障
+			call.Cancel()
+			return nil, ctx.Err()
+		}
+		call.Mark(err, time.Since(start))
+		if err == nil {
+			a.setSession(i, c.SessionId)
+			return c, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Stream 依次尝试各后端, 返回第一个成功响应的后端的scanner
+func (a *ChatApp) Stream(ctx context.Context, messages []*model.Message, opts *model.ChatOptions) (model.ChatAppScanner, error) {
+	errs := []error{ErrUnavailable}
+	for i, b := range a.backends {
+		call, ok := b.breaker.Allow()
+		if !ok {
+			continue
+		}
+		start := time.Now()
+		scanner, err := a.app(i).Stream(ctx, messages, a.options(i, opts))
+		var first *dto.ChatData
+		if err == nil {
+			// 预读首个响应, 确认后端可用
+			first, err = scanner.Next()
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			if ctx.Err() != nil {
+				// 调用方主动取消, 不视为后端故障, 释放可能占用的探测名额
+				call.Cancel()
+				if scanner != nil {
+					_ = scanner.Close()
+				}
+				return nil, ctx.Err()
+			}
+			call.Mark(err, time.Since(start))
+			if scanner != nil {
+				_ = scanner.Close()
+			}
+			metrics.Failover.Inc(slotChat, b.breaker.Name())
+			log.Error("chat backend "+b.breaker.Name()+" failed:", err)
+			errs = append(errs, err)
+			continue
+		}
+		call.Mark(nil, time.Since(start))
+		s := &chatScanner{ChatAppScanner: scanner, app: a, idx: i, prefetched: true, first: first, firstErr: err}
+		if first != nil {
+			a.setSession(i, first.SessionId)
+		}
+		return s, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Close 释放所有后端的资源
+func (a *ChatApp) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var errs []error
+	for _, app := range a.apps {
+		if app != nil {
+			errs = append(errs, app.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// chatScanner 返回预读的首个响应, 并记录后端的会话与错误
+type chatScanner struct {
+	model.ChatAppScanner
+	app *ChatApp
+	idx int
+
+	prefetched bool
+	first      *dto.ChatData
+	firstErr   error
+}
+
+func (s *chatScanner) Next() (*dto.ChatData, error) {
+	if s.prefetched {
+		s.prefetched = false
+		return s.first, s.firstErr
+	}
+	data, err := s.ChatAppScanner.Next()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			s.app.backends[s.idx].breaker.Report(err)
+		}
+		return data, err
+	}
+	s.app.setSession(s.idx, data.SessionId)
+	return data, nil
+}
@@ -0,0 +1,135 @@
+package failover
+
+import (
+	"cmp"
+	"errors"
+	"sync"
+
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/domain/model/bailian"
+	"github.com/xh-polaris/psych-senior/biz/domain/model/volc"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/breaker"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+)
+
+// 各服务的备用链路, 主后端与备用后端按配置顺序排列, 每个后端由独立的熔断器保护
+// 对话与报告在每次调用时选择后端, 语音合成与识别在建立连接时选择后端
+
+// ErrUnavailable 所有后端均不可用
+var ErrUnavailable = errors.New("all backends are unavailable")
+
+// 服务类别, 用于指标统计
+const (
+	slotChat   = "chat"
+	slotTts    = "tts"
+	slotAsr    = "asr"
+	slotReport = "report"
+)
+
+// backend 一个后端及其熔断器
+type backend[T any] struct {
+	// new 创建后端实例, 长连接类的后端每次连接都使用新的实例
+	new func() T
+	// dial 创建实例并完成连接与握手, 仅用于语音合成, 启用连接池时从池中取用
+	dial    func() (T, error)
+	breaker *breaker.Breaker
+}
+
+// NewChatApp 创建带有备用后端的百炼对话应用
+func NewChatApp(primary config.BaiLianChat, fallbacks []config.BaiLianChat) model.ChatApp {
+	c := config.GetConfig()
+	var backends []*backend[model.ChatApp]
+	for _, b := range append([]config.BaiLianChat{primary}, fallbacks...) {
+		backends = append(backends, &backend[model.ChatApp]{
+			new:     func() model.ChatApp { return bailian.NewBLChatApp(b.AppId, b.ApiKey) },
+			breaker: breaker.Get(consts.BaiLian+":"+b.AppId, b.Breaker, c.Failover.Breaker),
+		})
+	}
+	return newChatApp(backends)
+}
+
+// NewLangTtsApp 按语言配置的合成方式与音色创建语音合成, format为合成音频的编码
+func NewLangTtsApp(t config.LanguageTts, format string) model.TtsApp {
+	if t.Backend == consts.VolcNoModelTts {
+		return NewNoModelTtsApp(format, t.Speaker, t.Lang)
+	}
+	return NewTtsApp(format, t.Speaker)
+}
+
+// NewTtsApp 创建带有备用后端的火山双向流式语音合成, format为合成音频的编码, speaker为空时使用后端配置的音色
+func NewTtsApp(format, speaker string) model.TtsApp {
+	c := config.GetConfig()
+	var backends []*backend[model.TtsApp]
+	for i, b := range append([]config.VolcTts{c.VolcTts}, c.Failover.VolcTts...) {
+		name := consts.VolcTts + ":" + b.AppKey + ":" + b.Url
+		speaker := cmp.Or(speaker, b.Speaker)
+		newApp := func() model.TtsApp {
+			return volc.NewVcTtsApp(b.AppKey, b.AccessKey, speaker, b.ResourceId, b.Url, format)
+		}
+		backends = append(backends, &backend[model.TtsApp]{
+			new:     newApp,
+			dial:    ttsDialer(name+":"+speaker+":"+format, i == 0, newApp),
+			breaker: breaker.Get(name, b.Breaker, c.Failover.Breaker),
+		})
+	}
+	return newTtsApp(backends)
+}
+
+// NewNoModelTtsApp 创建带有备用后端的火山非流式语音合成, format为合成音频的编码
+// speaker与lang为空时使用后端配置的音色与语言
+func NewNoModelTtsApp(format, speaker, lang string) model.TtsApp {
+	c := config.GetConfig()
+	var backends []*backend[model.TtsApp]
+	for i, b := range append([]config.VolcNoModelTts{c.VolcNoModelTts}, c.Failover.VolcNoModelTts...) {
+		name := consts.VolcNoModelTts + ":" + b.AppKey + ":" + b.Url
+		speaker, lang := cmp.Or(speaker, b.Speaker), cmp.Or(lang, b.Lang)
+		newApp := func() model.TtsApp {
+			return volc.NewVcNoModelTtsApp(b.AppKey, b.AccessKey, speaker, b.Cluster, lang, b.Url, format)
+		}
+		backends = append(backends, &backend[model.TtsApp]{
+			new:     newApp,
+			dial:    ttsDialer(name+":"+speaker+":"+lang+":"+format, i == 0, newApp),
+			breaker: breaker.Get(name, b.Breaker, c.Failover.Breaker),
+		})
+	}
+	return newTtsApp(backends)
+}
+
+// NewAsrApp 创建带有备用后端的火山语音识别, format为上传音频的编码, lang为语言指定的识别模型与语言
+func NewAsrApp(format string, lang config.LanguageAsr) model.AsrApp {
+	c := config.GetConfig()
+	var backends []*backend[model.AsrApp]
+	for _, b := range append([]config.VolcAsr{c.VolcAsr}, c.Failover.VolcAsr...) {
+		resourceId := cmp.Or(lang.ResourceId, b.ResourceId)
+		backends = append(backends, &backend[model.AsrApp]{
+			new: func() model.AsrApp {
+				return volc.NewVcAsrApp(b.AppKey, b.AccessKey, resourceId, b.Url, format, b.ResultType, lang.Language)
+			},
+			breaker: breaker.Get(consts.VolcAsr+":"+b.AppKey+":"+b.Url, b.Breaker, c.Failover.Breaker),
+		})
+	}
+	return newAsrApp(backends)
+}
+
+var report model.ReportApp
+var once sync.Once
+
+// GetReportApp 获取带有备用后端的百炼报告分析应用单例
+func GetReportApp() model.ReportApp {
+	once.Do(func() {
+		c := config.GetConfig()
+		var backends []*backend[model.ReportApp]
+		for _, b := range append([]config.BaiLianReport{c.BaiLianReport}, c.Failover.BaiLianReport...) {
+			// 报告生成的耗时远长于对话, 慢调用的阈值取超时时间, 熔断器与对话应用分开
+			latency := cmp.Or(b.Timeout, bailian.DefaultReportTimeout.Milliseconds())
+			backends = append(backends, &backend[model.ReportApp]{
+				new: func() model.ReportApp { return bailian.NewBLReportApp(b.AppId, b.ApiKey, b.Timeout) },
+				breaker: breaker.Get(consts.BaiLian+":report:"+b.AppId,
+					b.Breaker, config.Breaker{Latency: latency}, c.Failover.Breaker),
+			})
+		}
+		report = newReportApp(backends)
+	})
+	return report
+}
@@ -0,0 +1,39 @@
+package failover
+
+import (
+	"sync"
+
+	"github.com/xh-polaris/psych-senior/biz/domain/language"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/pool"
+)
+
+var (
+	poolOnce sync.Once
+	// pools 各语音合成后端的连接池, 按后端与音色区分, 在所有会话间共享
+	pools *pool.Group[model.TtsApp]
+)
+
+// ttsDialer 返回后端建立连接并握手的方法, 启用连接池时从池中取用
+// 连接池可能因长时间未使用被关闭, 因此每次取用时重新获取
+// 主后端创建时即建立连接池, 使预热与第一次取用时已有空闲连接, 备用后端在第一次切换时才建立
+func ttsDialer(name string, primary bool, new func() model.TtsApp) func() (model.TtsApp, error) {
+	c := config.GetConfig().TtsPool
+	if c.Size < 0 {
+		return func() (model.TtsApp, error) { return pool.Dial(new) }
+	}
+	poolOnce.Do(func() { pools = pool.NewGroup[model.TtsApp](c) })
+	if primary {
+		pools.Pool(name, new)
+	}
+	return func() (model.TtsApp, error) { return pools.Pool(name, new).Get() }
+}
+
+// WarmTts 为各语言的语音合成预先建立pcm编码的连接, 服务启动时调用, 使第一通对话也能立即播报
+// 其他编码的连接池在第一次使用时建立
+func WarmTts() {
+	for _, l := range language.GetRegistry().List() {
+		_ = NewLangTtsApp(l.Tts, model.EncodingPCM)
+	}
+}
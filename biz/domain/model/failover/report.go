@@ -0,0 +1,56 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/application/dto"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+)
+
+// ReportApp 带有备用后端的报告分析应用, 每次调用依次尝试各后端
+type ReportApp struct {
+	backends []*backend[model.ReportApp]
+	apps     []model.ReportApp
+}
+
+func newReportApp(backends []*backend[model.ReportApp]) *ReportApp {
+	apps := make([]model.ReportApp, len(backends))
+	for i, b := range backends {
+		apps[i] = b.new()
+	}
+	return &ReportApp{backends: backends, apps: apps}
+}
+
+// Call 获取报告结果
+func (a *ReportApp) Call(ctx context.Context, msg string) (*dto.Report, error) {
+	errs := []error{ErrUnavailable}
+	for i, b := range a.backends {
+		call, ok := b.breaker.Allow()
+		if !ok {
+			continue
+		}
+		start := time.Now()
+		report, err := a.apps[i].Call(ctx, msg)
+		call.Mark(err, time.Since(start))
+		if err == nil {
+			return report, nil
+		}
+		metrics.Failover.Inc(slotReport, b.breaker.Name())
+		log.Error("report backend "+b.breaker.Name()+" failed:", err)
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Close 释放相关资源
+func (a *ReportApp) Close() error {
+	var errs []error
+	for _, app := range a.apps {
+		errs = append(errs, app.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,254 @@
+package failover
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/pool"
+)
+
+// idleWait 没有可用连接时Receive的等待时间, 避免调用方空转
+const idleWait = 50 * time.Millisecond
+
+var (
+	_ model.PhraseSynthesizer = (*TtsApp)(nil)
+	_ model.Reconnectable     = (*TtsApp)(nil)
+	_ model.Captioner         = (*TtsApp)(nil)
+	_ model.Stylable          = (*TtsApp)(nil)
+)
+
+// TtsApp 带有备用后端的语音合成
+// 后端自身会先尝试重连, 重连失败后依次尝试各后端重新建立连接, 并重发失败的文本
+type TtsApp struct {
+	backends []*backend[model.TtsApp]
+
+	// connMu 保证同一时间只有一次重连
+	connMu  sync.Mutex
+	mu      sync.RWMutex
+	app     model.TtsApp
+	idx     int
+	notify  func(state model.ReconnectState)
+	caption func(c *model.Caption)
+	style   *model.Style
+}
+
+func newTtsApp(backends []*backend[model.TtsApp]) *TtsApp {
+	return &TtsApp{backends: backends}
+}
+
+// Dial 依次尝试各后端, 同时完成连接与握手, 以便握手失败时也能切换
+// 启用连接池时直接取用已握手的连接
+func (a *TtsApp) Dial() error {
+	return a.connect()
+}
+
+// Start 握手已在Dial中完成
+func (a *TtsApp) Start() error {
+	if app, _ := a.current(); app == nil {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+func (a *TtsApp) connect() error {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	for i, b := range a.backends {
+		call, ok := b.breaker.Allow()
+		if !ok {
+			continue
+		}
+		start := time.Now()
+		app, err := b.dial()
+		call.Mark(err, time.Since(start))
+		if err != nil {
+			metrics.Failover.Inc(slotTts, b.breaker.Name())
+			log.Error("tts backend "+b.breaker.Name()+" failed:", err)
+			continue
+		}
+		a.mu.Lock()
+		old := a.app
+		a.app, a.idx = app, i
+		notify, caption, style := a.notify, a.caption, a.style
+		a.mu.Unlock()
+		if s, ok := app.(model.Stylable); ok && style != nil {
+			s.SetStyle(*style)
+		}
+		if r, ok := app.(model.Reconnectable); ok && notify != nil {
+			r.OnReconnect(notify)
+		}
+		if c, ok := app.(model.Captioner); ok && caption != nil {
+			c.OnCaption(caption)
+		}
+		if old != nil {
+			_ = old.Close()
+		}
+		return nil
+	}
+	return ErrUnavailable
+}
+
+func (a *TtsApp) current() (model.TtsApp, int) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.app, a.idx
+}
+
+// Send 发送文字, 失败时切换后端并重发
+func (a *TtsApp) Send(text string) error {
+	app, idx := a.current()
+	if app == nil {
+		return ErrUnavailable
+	}
+	b := a.backends[idx]
+	err := app.Send(text)
+	if err == nil {
+		return nil
+	}
+	b.breaker.Report(err)
+	metrics.Failover.Inc(slotTts, b.breaker.Name())
+	log.Error("tts backend "+b.breaker.Name()+" send failed:", err)
+
+	a.mu.Lock()
+	if a.app == app {
+		a.app = nil
+	}
+	a.mu.Unlock()
+	_ = app.Close()
+	if err = a.connect(); err != nil {
+		return err
+	}
+	app, _ = a.current()
+	return app.Send(text)
+}
+
+// Receive 接收当前连接的音频, 没有可用连接时返回nil
+// 连接出错且后端重连失败时切换后端, 所有后端均不可用时返回ErrUnavailable
+func (a *TtsApp) Receive() ([]byte, error) {
+	app, idx := a.current()
+	if app == nil {
+		time.Sleep(idleWait)
+		return nil, nil
+	}
+	data, err := app.Receive()
+	if err == nil {
+		return data, nil
+	}
+	a.mu.Lock()
+	if a.app != app {
+		// 连接已被Send或Close替换, 旧连接的错误与EOF不再返回
+		a.mu.Unlock()
+		return nil, nil
+	}
+	if errors.Is(err, io.EOF) {
+		a.mu.Unlock()
+		return nil, err
+	}
+	a.app = nil
+	notify := a.notify
+	a.mu.Unlock()
+
+	b := a.backends[idx]
+	b.breaker.Report(err)
+	metrics.Failover.Inc(slotTts, b.breaker.Name())
+	log.Error("tts backend "+b.breaker.Name()+" receive failed:", err)
+	_ = app.Close()
+	if notify != nil {
+		notify(model.Reconnecting)
+	}
+	if err = a.connect(); err != nil {
+		if notify != nil {
+			notify(model.ReconnectFailed)
+		}
+		return nil, err
+	}
+	if notify != nil {
+		notify(model.Reconnected)
+	}
+	return nil, nil
+}
+
+// OnReconnect 设置重连状态变化的回调, 包括后端自身的重连与切换后端
+func (a *TtsApp) OnReconnect(f func(state model.ReconnectState)) {
+	a.mu.Lock()
+	a.notify = f
+	app := a.app
+	a.mu.Unlock()
+	if r, ok := app.(model.Reconnectable); ok {
+		r.OnReconnect(f)
+	}
+}
+
+// OnCaption 设置字幕事件的回调, 切换后端后同样生效
+func (a *TtsApp) OnCaption(f func(c *model.Caption)) {
+	a.mu.Lock()
+	a.caption = f
+	app := a.app
+	a.mu.Unlock()
+	if c, ok := app.(model.Captioner); ok {
+		c.OnCaption(f)
+	}
+}
+
+// SetStyle 设置之后发送的文字使用的风格, 切换后端后同样生效
+func (a *TtsApp) SetStyle(s model.Style) {
+	a.mu.Lock()
+	a.style = &s
+	app := a.app
+	a.mu.Unlock()
+	if st, ok := app.(model.Stylable); ok {
+		st.SetStyle(s)
+	}
+}
+
+// Close 关闭当前连接
+func (a *TtsApp) Close() error {
+	a.mu.Lock()
+	app := a.app
+	a.app = nil
+	a.mu.Unlock()
+	if app == nil {
+		return nil
+	}
+	return app.Close()
+}
+
+// Voice 当前后端的音色与格式, 尚未建立连接时为主后端的音色与格式
+func (a *TtsApp) Voice() model.Voice {
+	app, idx := a.current()
+	if app == nil {
+		app = a.backends[idx].new()
+	}
+	if s, ok := app.(model.PhraseSynthesizer); ok {
+		return s.Voice()
+	}
+	return model.Voice{}
+}
+
+// Synthesize 使用当前后端的单独连接合成一段文字, 不影响会话中的连接
+// 不切换后端, 以保证音频与Voice一致
+func (a *TtsApp) Synthesize(text string) ([]byte, error) {
+	_, idx := a.current()
+	b := a.backends[idx]
+	call, ok := b.breaker.Allow()
+	if !ok {
+		return nil, ErrUnavailable
+	}
+	start := time.Now()
+	app, err := pool.Dial(b.new)
+	call.Mark(err, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = app.Close() }()
+	s, ok := app.(model.PhraseSynthesizer)
+	if !ok {
+		return nil, ErrUnavailable
+	}
+	return s.Synthesize(text)
+}
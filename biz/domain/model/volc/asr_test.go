@@ -2,12 +2,19 @@ package volc
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
 )
 
 const (
@@ -23,7 +30,7 @@ const (
 // TestASRStreaming 流式语音识别测试
 func TestASRStreaming(t *testing.T) {
 	// 1. 初始化ASR客户端
-	asrApp := NewVcAsrApp(testAsrAppKey, testAsrAccessKey, testAsrResourceId, testAsrURL)
+	asrApp := NewVcAsrApp(testAsrAppKey, testAsrAccessKey, testAsrResourceId, testAsrURL, "", "", "")
 
 	// 2. 建立连接
 	if err := asrApp.Dial(); err != nil {
@@ -129,12 +136,139 @@ func receiveResults(ctx context.Context, t *testing.T, app *VcAsrApp, output *os
 				return
 			}
 
-			if len(res) > 0 {
-				log.Printf("识别结果: %s", res)
-				if _, err := output.WriteString(string(res) + "\n"); err != nil {
+			if res != nil && res.Text != "" {
+				log.Printf("识别结果: %s", res.Text)
+				if _, err := output.WriteString(res.Text + "\n"); err != nil {
 					t.Errorf("写入结果失败: %v", err)
 				}
 			}
 		}
 	}
 }
+
+func TestAsrContext(t *testing.T) {
+	starts := make(chan map[string]any, 1)
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		serveAsr(ws, func(int) bool { return false }, func(req map[string]any) { starts <- req })
+	})
+	app := NewVcAsrApp("app", "key", "resource", url, "", "", "")
+	app.SetContext(&model.AsrContext{Uid: "senior", Hotwords: []string{"小宝", "阿司匹林"}, Dialog: []string{"您孙子叫什么名字呀"}})
+	if err := app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+
+	req := <-starts
+	if uid := req["user"].(map[string]any)["uid"]; uid != "senior" {
+		t.Errorf("uid = %v", uid)
+	}
+	corpus, _ := req["request"].(map[string]any)["corpus"].(map[string]any)
+	var ctx struct {
+		Hotwords []struct {
+			Word string `json:"word"`
+		} `json:"hotwords"`
+		ContextType string `json:"context_type"`
+		ContextData []struct {
+			Text string `json:"text"`
+		} `json:"context_data"`
+	}
+	if err := json.Unmarshal([]byte(corpus["context"].(string)), &ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx.Hotwords) != 2 || ctx.Hotwords[1].Word != "阿司匹林" {
+		t.Errorf("hotwords = %+v", ctx.Hotwords)
+	}
+	if ctx.ContextType != "dialog_ctx" || len(ctx.ContextData) != 1 {
+		t.Errorf("context = %+v", ctx)
+	}
+}
+
+func TestAsrContextReload(t *testing.T) {
+	starts := make(chan map[string]any, 2)
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		// 第一个连接收到音频时断开
+		serveAsr(ws, func(int) bool { return n == 1 }, func(req map[string]any) { starts <- req })
+	})
+	app := NewVcAsrApp("app", "key", "resource", url, "", "", "")
+	app.policy = testPolicy
+	var reloads atomic.Int32
+	app.SetContext(&model.AsrContext{Dialog: []string{"旧的对话"}, Reload: func() ([]string, error) {
+		return []string{fmt.Sprintf("第%d次读取", reloads.Add(1))}, nil
+	}})
+	if err := app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+	if err := app.Send([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Receive(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每次开始识别都读取最新的对话
+	for i, want := range []string{"第1次读取", "第2次读取"} {
+		corpus := (<-starts)["request"].(map[string]any)["corpus"].(map[string]any)["context"].(string)
+		if !strings.Contains(corpus, want) {
+			t.Errorf("start %d corpus = %s", i, corpus)
+		}
+	}
+}
+
+func TestAsrFullResult(t *testing.T) {
+	results := make(chan map[string]any, 1)
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		serveAsr(ws, func(int) bool { return true }, func(req map[string]any) {
+			results <- req
+			// 全量返回, 每次包含之前确定的句子与当前未确定的句子
+			first := map[string]any{"text": "你好。", "definite": true, "start_time": 0, "end_time": 800, "confidence": 0.9}
+			_ = writeAsrResult(ws, 1, "你好。我", first, map[string]any{"text": "我", "start_time": 900, "end_time": 1000})
+			_ = writeAsrResult(ws, 2, "你好。我吃过了。", first,
+				map[string]any{"text": "我吃过了。", "definite": true, "start_time": 900, "end_time": 1800})
+			_ = writeAsrResult(ws, -3, "你好。我吃过了。", first,
+				map[string]any{"text": "我吃过了。", "definite": true, "start_time": 900, "end_time": 1800})
+		})
+	})
+	app := NewVcAsrApp("app", "key", "resource", url, "", ResultFull, "")
+	if err := app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+	if req := <-results; req["request"].(map[string]any)["result_type"] != ResultFull {
+		t.Errorf("request = %v", req["request"])
+	}
+
+	r, err := app.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Utterances) != 2 || !r.Utterances[0].Definite || r.Utterances[1].Definite ||
+		r.Utterances[0].End != 800*time.Millisecond || r.Utterances[0].Confidence != 0.9 {
+		t.Fatalf("first = %+v", r.Utterances)
+	}
+	// 已确定的句子不再重复返回
+	r, err = app.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Utterances) != 1 || r.Utterances[0].Text != "我吃过了。" || !r.Utterances[0].Definite {
+		t.Fatalf("second = %+v", r.Utterances)
+	}
+	r, err = app.Receive()
+	if err != nil || len(r.Utterances) != 0 {
+		t.Fatalf("last = %+v, %v", r, err)
+	}
+	// 最后一个包之后结束
+	if _, err = app.Receive(); err != io.EOF {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package volc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// pingTimeout 发送ping帧的超时时间
+const pingTimeout = 3 * time.Second
+
+var errNotConnected = errors.New("websocket is not connected")
+
+// ping 发送ping帧, 控制帧可以与其他写操作并发
+func ping(ws *websocket.Conn) error {
+	if ws == nil {
+		return errNotConnected
+	}
+	return ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingTimeout))
+}
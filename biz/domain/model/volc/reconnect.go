@@ -0,0 +1,113 @@
+package volc
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+)
+
+// 长连接断开后的自动重连, 按指数退避重试, 重连后重新握手并重发未完成的数据
+
+// 未配置时的默认值
+const (
+	defaultReconnectAttempts   = 3
+	defaultReconnectBackoff    = 200
+	defaultReconnectMaxBackoff = 2000
+)
+
+// 连接类别, 用于指标统计
+const (
+	slotTts = "tts"
+	slotAsr = "asr"
+)
+
+// errClosed 连接已被主动关闭, 不再重连
+var errClosed = errors.New("connection is closed")
+
+type retryPolicy struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func getRetryPolicy() retryPolicy {
+	p := retryPolicy{
+		attempts:   defaultReconnectAttempts,
+		backoff:    defaultReconnectBackoff * time.Millisecond,
+		maxBackoff: defaultReconnectMaxBackoff * time.Millisecond,
+	}
+	c := config.GetConfig()
+	if c == nil {
+		return p
+	}
+	if c.Reconnect.Attempts > 0 {
+		p.attempts = c.Reconnect.Attempts
+	}
+	if c.Reconnect.Backoff > 0 {
+		p.backoff = time.Duration(c.Reconnect.Backoff) * time.Millisecond
+	}
+	if c.Reconnect.MaxBackoff > 0 {
+		p.maxBackoff = time.Duration(c.Reconnect.MaxBackoff) * time.Millisecond
+	}
+	return p
+}
+
+// reconnector 重连的通用逻辑, 由各长连接嵌入
+type reconnector struct {
+	slot   string
+	policy retryPolicy
+
+	mu     sync.Mutex
+	notify func(state model.ReconnectState)
+}
+
+func newReconnector(slot string) reconnector {
+	return reconnector{slot: slot, policy: getRetryPolicy()}
+}
+
+// OnReconnect 设置重连状态变化的回调
+func (r *reconnector) OnReconnect(f func(state model.ReconnectState)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notify = f
+}
+
+func (r *reconnector) emit(state model.ReconnectState) {
+	r.mu.Lock()
+	f := r.notify
+	r.mu.Unlock()
+	if f != nil {
+		f(state)
+	}
+}
+
+// retry 按退避策略重试dial, closed返回true时放弃并返回errClosed, 不通知重连失败
+func (r *reconnector) retry(dial func() error, closed func() bool) error {
+	r.emit(model.Reconnecting)
+	var err error
+	backoff := r.policy.backoff
+	for i := 0; i < r.policy.attempts; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff = min(backoff*2, r.policy.maxBackoff)
+		}
+		if closed() {
+			// 主动关闭不是重连失败, 不通知也不计入失败
+			return errClosed
+		}
+		if err = dial(); err == nil {
+			metrics.Reconnect.Inc(r.slot, "success")
+			r.emit(model.Reconnected)
+			return nil
+		}
+		log.Error(r.slot+" reconnect err:", err)
+	}
+	metrics.Reconnect.Inc(r.slot, "failure")
+	r.emit(model.ReconnectFailed)
+	return err
+}
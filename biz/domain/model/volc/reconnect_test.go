@@ -0,0 +1,481 @@
+package volc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xh-polaris/psych-senior/biz/domain/audio"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/util"
+)
+
+var testPolicy = retryPolicy{attempts: 3, backoff: 10 * time.Millisecond, maxBackoff: 10 * time.Millisecond}
+
+// fakeServer 本地的ws服务, handle处理第n个连接, n从1开始
+func fakeServer(t *testing.T, handle func(n int, ws *websocket.Conn)) string {
+	var conns atomic.Int32
+	upgrader := websocket.Upgrader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		handle(int(conns.Add(1)), ws)
+	}))
+	t.Cleanup(s.Close)
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// stateRecorder 记录重连状态的变化
+type stateRecorder struct {
+	mu     sync.Mutex
+	states []model.ReconnectState
+}
+
+func (r *stateRecorder) record(state model.ReconnectState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states = append(r.states, state)
+}
+
+func (r *stateRecorder) get() []model.ReconnectState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]model.ReconnectState(nil), r.states...)
+}
+
+// writeTtsEvent 写入一个带事件的服务端消息
+func writeTtsEvent(ws *websocket.Conn, mt MsgType, event Event, sessionId string, payload []byte) error {
+	msg, err := NewMessage(mt, MsgTypeFlagWithEvent)
+	if err != nil {
+		return err
+	}
+	msg.Event, msg.SessionID, msg.Payload = int32(event), sessionId, payload
+	frame, err := protocol.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if event == EventConnectionStarted {
+		// 编码时不写入connect id, 手动补上长度为0的connect id之后的payload长度
+		frame = append(frame, 0, 0, 0, 0)
+	}
+	return ws.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// serveTts 模拟双向流式合成, 每句文字原样作为音频返回, 每个字0.1秒, drop返回true时直接断开连接
+// onRequest不为nil时接收开始会话与合成的请求
+func serveTts(ws *websocket.Conn, drop func(tasks int) bool, onRequest func(req *TTSRequest)) {
+	tasks := 0
+	for {
+		_, frame, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		msg, _, err := Unmarshal(frame, ContainsSequence)
+		if err != nil {
+			return
+		}
+		switch Event(msg.Event) {
+		case EventStartConnection:
+			err = writeTtsEvent(ws, MsgTypeFullServer, EventConnectionStarted, "", nil)
+		case EventStartSession:
+			if onRequest != nil {
+				var req TTSRequest
+				_ = json.Unmarshal(msg.Payload, &req)
+				onRequest(&req)
+			}
+			err = writeTtsEvent(ws, MsgTypeFullServer, EventSessionStarted, msg.SessionID, []byte("{}"))
+		case EventTaskRequest:
+			if tasks++; drop(tasks) {
+				return
+			}
+			var req TTSRequest
+			_ = json.Unmarshal(msg.Payload, &req)
+			if onRequest != nil {
+				onRequest(&req)
+			}
+			text := req.ReqParams.Text
+			start, _ := json.Marshal(map[string]any{"res_params": map[string]string{"text": text}})
+			if err = writeTtsEvent(ws, MsgTypeFullServer, EventTTSSentenceStart, msg.SessionID, start); err != nil {
+				return
+			}
+			if err = writeTtsEvent(ws, MsgTypeAudioOnlyServer, EventTTSResponse, msg.SessionID, []byte(text)); err != nil {
+				return
+			}
+			var words []map[string]any
+			for i, r := range []rune(text) {
+				words = append(words, map[string]any{"word": string(r), "startTime": float64(i) / 10, "endTime": float64(i+1) / 10})
+			}
+			end, _ := json.Marshal(map[string]any{"text": text, "words": words})
+			err = writeTtsEvent(ws, MsgTypeFullServer, EventTTSSentenceEnd, msg.SessionID, end)
+		case EventFinishSession:
+			err = writeTtsEvent(ws, MsgTypeFullServer, EventSessionFinished, msg.SessionID, []byte("{}"))
+		case EventFinishConnection:
+			err = writeTtsEvent(ws, MsgTypeFullServer, EventConnectionFinished, "", []byte("{}"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func TestTtsReconnect(t *testing.T) {
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		// 第一个连接在收到第二句时断开
+		serveTts(ws, func(tasks int) bool { return n == 1 && tasks == 2 }, nil)
+	})
+	app := NewVcTtsApp("app", "key", "speaker", "resource", url, "")
+	app.policy = testPolicy
+	var rec stateRecorder
+	app.OnReconnect(rec.record)
+	if err := app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+
+	var audio []byte
+	receive := func() {
+		data, err := app.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		audio = append(audio, data...)
+	}
+	if err := app.Send("第一句。"); err != nil {
+		t.Fatal(err)
+	}
+	receive()
+	if err := app.Send("第二句。"); err != nil {
+		t.Fatal(err)
+	}
+	receive()
+
+	if got := string(audio); got != "第一句。第二句。" {
+		t.Errorf("audio = %q", got)
+	}
+	if got := rec.get(); len(got) != 2 || got[0] != model.Reconnecting || got[1] != model.Reconnected {
+		t.Errorf("states = %v", got)
+	}
+}
+
+func TestTtsReconnectFailed(t *testing.T) {
+	var down atomic.Bool
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		if down.Load() {
+			return
+		}
+		serveTts(ws, func(int) bool { down.Store(true); return true }, nil)
+	})
+	app := NewVcTtsApp("app", "key", "speaker", "resource", url, "")
+	app.policy = testPolicy
+	var rec stateRecorder
+	app.OnReconnect(rec.record)
+	if err := app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+
+	_ = app.Send("第一句。")
+	if _, err := app.Receive(); err == nil {
+		t.Fatal("expect error after reconnect failed")
+	}
+	if got := rec.get(); len(got) == 0 || got[len(got)-1] != model.ReconnectFailed {
+		t.Errorf("states = %v", got)
+	}
+}
+
+// serveAsr 模拟流式识别, 每个音频包原样作为一句确定的识别结果返回, 每句100毫秒, drop返回true时直接断开连接
+// onStart不为nil时接收开始请求中的配置
+func serveAsr(ws *websocket.Conn, drop func(packets int) bool, onStart func(req map[string]any)) {
+	packets := 0
+	for {
+		_, frame, err := ws.ReadMessage()
+		if err != nil || len(frame) < 12 {
+			return
+		}
+		if frame[1]>>4 == FullClientRequest && onStart != nil {
+			req := make(map[string]any)
+			payload, _ := util.GzipDecompress(frame[12:])
+			_ = json.Unmarshal(payload, &req)
+			onStart(req)
+		}
+		if frame[1]>>4 != AudioOnlyRequest || frame[1]&0x0f != PosSequence {
+			continue
+		}
+		if packets++; drop(packets) {
+			return
+		}
+		audio, err := util.GzipDecompress(frame[12:])
+		if err != nil {
+			return
+		}
+		utterance := map[string]any{
+			"text": string(audio), "definite": true,
+			"start_time": (packets - 1) * 100, "end_time": packets * 100,
+		}
+		if err = writeAsrResult(ws, packets, string(audio), utterance); err != nil {
+			return
+		}
+	}
+}
+
+// writeAsrResult 写入一个识别结果, seq小于0表示最后一个包
+func writeAsrResult(ws *websocket.Conn, seq int, text string, utterances ...map[string]any) error {
+	payload, _ := json.Marshal(map[string]any{"result": map[string]any{"text": text, "utterances": utterances}})
+	flag := PosSequence
+	if seq < 0 {
+		flag = NegSequence1
+	}
+	resp := getHeader(FullServerResponse, flag, JSON, NoCompression, 0)
+	resp = append(resp, util.IntToBytes(seq)...)
+	resp = append(resp, util.IntToBytes(len(payload))...)
+	resp = append(resp, payload...)
+	return ws.WriteMessage(websocket.BinaryMessage, resp)
+}
+
+// asrText 连接一次结果中各句的文字
+func asrText(r *model.AsrResult) string {
+	var texts []string
+	for _, u := range r.Utterances {
+		texts = append(texts, u.Text)
+	}
+	return strings.Join(texts, "")
+}
+
+func TestAsrReconnect(t *testing.T) {
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		// 第一个连接在收到第二个音频包时断开
+		serveAsr(ws, func(packets int) bool { return n == 1 && packets == 2 }, nil)
+	})
+	app := NewVcAsrApp("app", "key", "resource", url, "", "", "")
+	app.policy = testPolicy
+	var rec stateRecorder
+	app.OnReconnect(rec.record)
+	if err := app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+
+	var texts []string
+	for _, audio := range []string{"a", "b"} {
+		if err := app.Send([]byte(audio)); err != nil {
+			t.Fatal(err)
+		}
+		r, err := app.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, asrText(r))
+	}
+
+	if got := strings.Join(texts, ","); got != "a,b" {
+		t.Errorf("texts = %q", got)
+	}
+	if got := rec.get(); len(got) != 2 || got[0] != model.Reconnecting || got[1] != model.Reconnected {
+		t.Errorf("states = %v", got)
+	}
+}
+
+func TestTtsOggFormat(t *testing.T) {
+	formats := make(chan string, 4)
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		serveTts(ws, func(int) bool { return false }, func(req *TTSRequest) {
+			formats <- req.ReqParams.AudioParams.Format
+		})
+	})
+	app := NewVcTtsApp("app", "key", "speaker", "resource", url, model.EncodingOggOpus)
+	if err := app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+	if err := app.Send("你好。"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if f := <-formats; f != model.EncodingOggOpus {
+			t.Errorf("format = %q", f)
+		}
+	}
+	if v := app.Voice(); v.Format != model.EncodingOggOpus {
+		t.Errorf("voice format = %q", v.Format)
+	}
+}
+
+func TestAsrOggReconnect(t *testing.T) {
+	starts := make(chan map[string]any, 4)
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		// 第一个连接在收到头部与两个数据页后断开
+		serveAsr(ws, func(packets int) bool { return n == 1 && packets == 3 }, func(req map[string]any) {
+			starts <- req
+		})
+	})
+	app := NewVcAsrApp("app", "key", "resource", url, model.EncodingOggOpus, "", "")
+	app.policy = testPolicy
+	if err := app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+
+	// 头部页只在连接开始时发送, 重连后应首先重发
+	var texts []string
+	for _, page := range []string{"head", "a", "b"} {
+		if err := app.Send([]byte(page)); err != nil {
+			t.Fatal(err)
+		}
+		r, err := app.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, asrText(r))
+	}
+	r, err := app.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts = append(texts, asrText(r))
+
+	if got := strings.Join(texts, ","); got != "head,a,head,b" {
+		t.Errorf("texts = %q", got)
+	}
+	for i := 0; i < 2; i++ {
+		audio := (<-starts)["audio"].(map[string]any)
+		if audio["format"] != "ogg" || audio["codec"] != "opus" {
+			t.Errorf("audio = %v", audio)
+		}
+	}
+}
+
+// TestAsrOggUplink 前端以ogg封装上传opus, 经语音识别会话的转换后发送给服务端
+func TestAsrOggUplink(t *testing.T) {
+	starts := make(chan map[string]any, 1)
+	frames := make(chan []byte, 16)
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		seq := 0
+		for {
+			_, frame, err := ws.ReadMessage()
+			if err != nil || len(frame) < 12 {
+				return
+			}
+			payload, err := util.GzipDecompress(frame[12:])
+			if err != nil {
+				return
+			}
+			switch frame[1] >> 4 {
+			case FullClientRequest:
+				req := make(map[string]any)
+				_ = json.Unmarshal(payload, &req)
+				starts <- req
+			case AudioOnlyRequest:
+				seq++
+				frames <- payload
+				if err = writeAsrResult(ws, seq, "ok"); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	// 协商: ogg上传时以ogg/opus开始识别
+	up, err := audio.NewUplink(audio.Format{Encoding: audio.Ogg, SampleRate: 16000}, audio.Format{Encoding: audio.PCM16, SampleRate: 16000, Channels: 1}, 1)
+	if err != nil || !up.Ogg() {
+		t.Fatalf("uplink %v, %v", up, err)
+	}
+	app := NewVcAsrApp("app", "key", "resource", url, model.EncodingOggOpus, "", "")
+	if err = app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err = app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+	if cfg := (<-starts)["audio"].(map[string]any); cfg["format"] != "ogg" || cfg["codec"] != "opus" {
+		t.Errorf("audio = %v", cfg)
+	}
+
+	// 前端的ogg流按任意长度分块上传, 包含需要多个lacing值的数据包
+	client := audio.NewOggWriter(7, 1, 16000)
+	packets := [][]byte{
+		append([]byte{0xf8}, bytes.Repeat([]byte{1}, 100)...),
+		append([]byte{0xf8}, bytes.Repeat([]byte{2}, 600)...),
+		append([]byte{0xf8}, bytes.Repeat([]byte{3}, 254)...),
+	}
+	stream := client.Header()
+	for _, p := range packets {
+		stream = append(stream, client.Page(p)...)
+	}
+	if err = app.Send(up.Header()); err != nil {
+		t.Fatal(err)
+	}
+	var d time.Duration
+	for len(stream) > 0 {
+		n := min(len(stream), 50)
+		pages, pd, err := up.Encode(stream[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, page := range pages {
+			if err = app.Send(page); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d += pd
+		stream = stream[n:]
+	}
+	if d != 60*time.Millisecond {
+		t.Errorf("duration = %v", d)
+	}
+
+	// 服务端收到一条完整的ogg流, 头部之后每页一个数据包
+	r := audio.NewOggReader()
+	var got [][]byte
+	for i := 0; i <= len(packets); i++ {
+		select {
+		case frame := <-frames:
+			out, err := r.Write(frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i > 0 && len(out) != 1 {
+				t.Fatalf("frame %d has %d packets", i, len(out))
+			}
+			got = append(got, out...)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d frames", i)
+		}
+	}
+	if len(got) != len(packets) {
+		t.Fatalf("got %d packets", len(got))
+	}
+	for i := range packets {
+		if !bytes.Equal(got[i], packets[i]) {
+			t.Errorf("packet %d mismatch", i)
+		}
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
 )
 
 // 测试前请替换以下参数为有效值
@@ -23,6 +26,7 @@ func TestTTSGeneration(t *testing.T) {
 		testTtsSpeaker,
 		testTtsResourceId,
 		testTtsUrl,
+		"",
 	)
 
 	// 建立连接
@@ -60,8 +64,8 @@ func TestTTSGeneration(t *testing.T) {
 	}()
 
 	for {
-		data := app.Receive()
-		if data == nil || len(data) == 0 {
+		data, err := app.Receive()
+		if err != nil || len(data) == 0 {
 			break
 		}
 		t.Logf("get a data with len: %d, at %s ", len(data), time.Now().String())
@@ -86,3 +90,43 @@ func TestTTSGeneration(t *testing.T) {
 
 	t.Logf("成功生成音频文件，大小: %d 字节", fileInfo.Size())
 }
+
+func TestTtsCaption(t *testing.T) {
+	url := fakeServer(t, func(n int, ws *websocket.Conn) {
+		serveTts(ws, func(int) bool { return false }, nil)
+	})
+	app := NewVcTtsApp("app", "key", "speaker", "resource", url, "")
+	var captions []*model.Caption
+	app.OnCaption(func(c *model.Caption) { captions = append(captions, c) })
+	if err := app.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = app.Close() }()
+	if err := app.Send("你好"); err != nil {
+		t.Fatal(err)
+	}
+	// 句子开始在音频之前回调, 句子结束在下一次读取时回调
+	if _, err := app.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	if len(captions) != 1 || captions[0].End || captions[0].Text != "你好" {
+		t.Fatalf("captions = %+v", captions)
+	}
+	if err := app.Send("再见"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	if len(captions) != 3 {
+		t.Fatalf("got %d captions", len(captions))
+	}
+	end := captions[1]
+	if !end.End || len(end.Words) != 2 || end.Words[1].Text != "好" ||
+		end.Words[1].Start != 100*time.Millisecond || end.Words[1].End != 200*time.Millisecond {
+		t.Errorf("sentence end = %+v", end)
+	}
+}
@@ -13,17 +13,27 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var _ model.AsrApp = (*VcAsrApp)(nil)
+var _ model.Contextual = (*VcAsrApp)(nil)
+
+// maxReplay 断线重连后最多重发的音频字节数, 16k采样率, 16位, 单声道的10秒音频
+const maxReplay = 320000
 
 // VcAsrApp 是火山引擎的大模型语音识别
 // 默认双向流式, 暂定一次对话共用一个连接, 如果太长了就一段话一个连接
 // 目前只支持单声道音频, 默认使用pcm格式, 16000采样频率, 增量返回
+// 连接断开时自动重连, 并重发最近一次识别结果之后的音频
 type VcAsrApp struct {
-	// ws 连接
+	reconnector
+
+	// ws 连接, mu保护ws的写入与重连
 	ws         *websocket.Conn
 	mu         sync.Mutex
+	closed     atomic.Bool
 	appKey     string
 	accessKey  string
 	resourceId string
@@ -39,24 +49,67 @@ type VcAsrApp struct {
 	sessionId string
 	// header 是请求头, 携带鉴权信息
 	header http.Header
+
+	// replay 最近一次识别结果之后发送的音频
+	replay [][]byte
+	// replaySize replay中的字节数
+	replaySize int
+	// lastSent 是否已经发送最后一个包
+	lastSent bool
+
+	// format 上传音频的编码, pcm或ogg_opus
+	format string
+	// head ogg_opus编码时第一次发送的头部页, 重连后首先重发
+	head []byte
+
+	// context 个性化的识别上下文, 为nil时不使用
+	context *model.AsrContext
+
+	// resultType 结果的返回方式, single增量返回, full全量返回
+	resultType string
+	// language 识别的语言, 为空时由服务端自动判断
+	language string
+	// finished 已收到服务端的最后一个包
+	finished atomic.Bool
+	// committed 已确定的句子的结束时间, 全量返回时用于去掉已经返回过的句子
+	committed time.Duration
+	// offset 重连后服务端的时间从0开始, 加上该偏移与之前的时间对齐
+	offset time.Duration
 }
 
-// NewVcAsrApp 构造一个新的
-func NewVcAsrApp(appKey, accessKey, resourceId, url string) *VcAsrApp {
+// 识别结果的返回方式
+const (
+	ResultSingle = "single"
+	ResultFull   = "full"
+)
+
+// NewVcAsrApp 构造一个新的, format为空时使用pcm, resultType为空时增量返回, language为空时自动判断语言
+// format为ogg_opus时, 第一次发送的数据应为完整的头部页, 之后每次发送完整的页
+func NewVcAsrApp(appKey, accessKey, resourceId, url, format, resultType, language string) *VcAsrApp {
+	if format == "" {
+		format = model.EncodingPCM
+	}
+	if resultType != ResultFull {
+		resultType = ResultSingle
+	}
 	connId := uuid.New().String()
 	logId := genLogID()
 	sessionId := uuid.New().String()
 	app := &VcAsrApp{
-		ws:         nil,
-		appKey:     appKey,
-		accessKey:  accessKey,
-		url:        url,
-		resourceId: resourceId,
-		connId:     connId,
-		logId:      logId,
-		sessionId:  sessionId,
-		seq:        1,
-		mu:         sync.Mutex{},
+		reconnector: newReconnector(slotAsr),
+		ws:          nil,
+		appKey:      appKey,
+		accessKey:   accessKey,
+		url:         url,
+		resourceId:  resourceId,
+		format:      format,
+		resultType:  resultType,
+		language:    language,
+		connId:      connId,
+		logId:       logId,
+		sessionId:   sessionId,
+		seq:         1,
+		mu:          sync.Mutex{},
 	}
 	app.buildHTTPHeader()
 	return app
@@ -86,25 +139,44 @@ func (app *VcAsrApp) Dial() error {
 func (app *VcAsrApp) Start() error {
 	var err error
 
+	// ogg封装的opus由服务端解码
+	format, codec := "pcm", "raw"
+	if app.format == model.EncodingOggOpus {
+		format, codec = "ogg", "opus"
+	}
+
 	// 协商配置参数
+	uid := app.connId
+	if app.context != nil && app.context.Uid != "" {
+		uid = app.context.Uid
+	}
+	request := map[string]any{
+		"model_name":      "bigmodel",     // 目前只有这个模型
+		"enable_punc":     true,           // 启用标点
+		"result_type":     app.resultType, // 增量或全量返回
+		"show_utterances": true,           // 返回分句与时间信息
+	}
+	if corpus := app.corpus(); corpus != "" {
+		request["corpus"] = map[string]any{"context": corpus}
+	}
+	// 音频参数 TODO: 目前格式均固定, 之后允许配置
+	audio := map[string]any{
+		"format":      format, // 格式,  pcm/wav/ogg
+		"sample_rate": 16000,  // 采样频率, 只支持16000
+		"bits":        16,     // 采样位数, 默认16 TODO: 确认
+		"channels":    1,      // 单声道, TODO: 确认
+		"codec":       codec,  // 编码方式, raw(pcm)/opus
+	}
+	if app.language != "" {
+		audio["language"] = app.language
+	}
 	req := map[string]any{
 		// 用户参数
 		"user": map[string]any{
-			"uid": "test",
-		},
-		// 音频参数 TODO: 目前格式均固定, 之后允许配置
-		"audio": map[string]any{
-			"format":      "pcm", // 格式,  pcm/wav/ogg
-			"sample_rate": 16000, // 采样频率, 只支持16000
-			"bits":        16,    // 采样位数, 默认16 TODO: 确认
-			"channels":    1,     // 单声道, TODO: 确认
-			"codec":       "raw", // 编码方式, raw(pcm)
-		},
-		"request": map[string]any{
-			"model_name":  "bigmodel", // 目前只有这个模型
-			"enable_punc": true,       // 启用标点
-			"result_type": "single",   // 增量返回
+			"uid": uid,
 		},
+		"audio":   audio,
+		"request": request,
 	}
 
 	// 序列化为字节
@@ -132,10 +204,81 @@ func (app *VcAsrApp) Start() error {
 	return nil
 }
 
-// Send 发送音频流
+// SetContext 设置个性化的识别上下文
+func (app *VcAsrApp) SetContext(c *model.AsrContext) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.context = c
+}
+
+// corpus 将热词与最近的对话编码为请求中的上下文, 没有上下文时返回空
+func (app *VcAsrApp) corpus() string {
+	c := app.context
+	if c == nil {
+		return ""
+	}
+	// 对话在识别期间不断增加, 每次开始时读取最新的内容
+	dialog := c.Dialog
+	if c.Reload != nil {
+		if d, err := c.Reload(); err != nil {
+			log.Error("reload asr dialog err:", err)
+		} else {
+			dialog = d
+		}
+	}
+	if len(c.Hotwords) == 0 && len(dialog) == 0 {
+		return ""
+	}
+	type word struct {
+		Word string `json:"word"`
+	}
+	type text struct {
+		Text string `json:"text"`
+	}
+	v := struct {
+		Hotwords    []word `json:"hotwords,omitempty"`
+		ContextType string `json:"context_type,omitempty"`
+		ContextData []text `json:"context_data,omitempty"`
+	}{}
+	for _, w := range c.Hotwords {
+		v.Hotwords = append(v.Hotwords, word{w})
+	}
+	if len(dialog) > 0 {
+		v.ContextType = "dialog_ctx"
+		for _, t := range dialog {
+			v.ContextData = append(v.ContextData, text{t})
+		}
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// Send 发送音频流, 发送失败时重连并重发最近一次识别结果之后的音频
 func (app *VcAsrApp) Send(data []byte) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if app.format == model.EncodingOggOpus && app.head == nil {
+		// 头部单独保存, 不计入重发的窗口
+		app.head = data
+	} else {
+		app.replay = append(app.replay, data)
+		app.replaySize += len(data)
+	}
+	for app.replaySize > maxReplay && len(app.replay) > 1 {
+		app.replaySize -= len(app.replay[0])
+		app.replay = app.replay[1:]
+	}
+	if err := app.sendAudio(data); err != nil {
+		log.Error("send asr err, reconnecting:", err)
+		return app.reconnectLocked(app.ws)
+	}
+	return nil
+}
+
+// sendAudio 发送一个音频包
+func (app *VcAsrApp) sendAudio(data []byte) error {
 	if app.ws == nil {
-		log.Error("ws is nil")
+		return errNotConnected
 	}
 	// 此处本来应该在最后一个包时, 将seq置为负数, 然后采用结束帧类型, 但是考虑到采用Close方法结束, 所以这里就不用这种方式了, 而是在Close中粗暴退出
 	app.seq++
@@ -153,8 +296,6 @@ func (app *VcAsrApp) Send(data []byte) error {
 
 	audioOnlyRequest := append(header, append(seqBytes, append(payloadSize, payloadBytes...)...)...)
 
-	app.mu.Lock()
-	defer app.mu.Unlock()
 	if err = app.ws.WriteMessage(websocket.BinaryMessage, audioOnlyRequest); err != nil {
 		return err
 	}
@@ -163,8 +304,20 @@ func (app *VcAsrApp) Send(data []byte) error {
 
 // Last 发送最后一个包
 func (app *VcAsrApp) Last() error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.lastSent = true
+	if err := app.sendLast(); err != nil {
+		log.Error("send asr last err, reconnecting:", err)
+		return app.reconnectLocked(app.ws)
+	}
+	return nil
+}
+
+// sendLast 发送结束包
+func (app *VcAsrApp) sendLast() error {
 	if app.ws == nil {
-		log.Error("ws is nil")
+		return errNotConnected
 	}
 	// 此处本来应该在最后一个包时, 将seq置为负数, 然后采用结束帧类型, 但是考虑到采用Close方法结束, 所以这里就不用这种方式了, 而是在Close中粗暴退出
 	app.seq++
@@ -184,67 +337,179 @@ func (app *VcAsrApp) Last() error {
 	//audioOnlyRequest := append(header, append(seqBytes, append(payloadSize, payloadBytes...)...)...)
 	audioOnlyRequest := append(header, append(payloadSize, payloadBytes...)...)
 
-	app.mu.Lock()
-	defer app.mu.Unlock()
 	if err = app.ws.WriteMessage(websocket.BinaryMessage, audioOnlyRequest); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Receive 接受响应
-func (app *VcAsrApp) Receive() (string, error) {
-	if app.ws == nil {
-		log.Error("ws is nil")
-	}
-	mt, res, err := app.ws.ReadMessage()
-	if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-		return "", io.EOF
-	}
-	if err != nil {
-		return "", err
+// Receive 接受响应, 连接意外断开时重连并重发最近一次确定的句子之后的音频
+// 收到服务端的最后一个包之后返回io.EOF
+func (app *VcAsrApp) Receive() (*model.AsrResult, error) {
+	for {
+		if app.finished.Load() {
+			return nil, io.EOF
+		}
+		ws := app.conn()
+		var mt int
+		var res []byte
+		err := errNotConnected
+		if ws != nil {
+			mt, res, err = ws.ReadMessage()
+		}
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure) || (err != nil && app.closed.Load()) {
+			return nil, io.EOF
+		}
+		if err != nil {
+			log.Error("receive asr err, reconnecting:", err)
+			if err = app.reconnect(ws); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var result *model.AsrResult
+		switch mt {
+		case websocket.BinaryMessage:
+			result, err = app.receiveBytes(res)
+		case websocket.TextMessage:
+			err = app.receiveText(res)
+		default:
+			err = fmt.Errorf("invalid websocket message")
+		}
+		return result, err
 	}
+}
+
+func (app *VcAsrApp) conn() *websocket.Conn {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.ws
+}
+
+// reconnect 重新建立连接并握手, 然后重发最近一次确定的句子之后的音频
+// failed为断开的连接, 其他协程已经完成重连时直接返回
+func (app *VcAsrApp) reconnect(failed *websocket.Conn) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.reconnectLocked(failed)
+}
 
-	switch mt {
-	case websocket.BinaryMessage:
-		return app.receiveBytes(res)
-	case websocket.TextMessage:
-		return app.receiveText(res)
-	default:
-		return "", fmt.Errorf("invalid websocket message")
+func (app *VcAsrApp) reconnectLocked(failed *websocket.Conn) error {
+	if app.ws != failed {
+		return nil
 	}
+	return app.retry(func() error {
+		if app.ws != nil {
+			_ = app.ws.Close()
+		}
+		app.connId, app.seq = uuid.New().String(), 1
+		// 重发的音频从最近一次确定的句子之后开始
+		app.offset = app.committed
+		app.buildHTTPHeader()
+		if err := app.Dial(); err != nil {
+			return err
+		}
+		if err := app.Start(); err != nil {
+			return err
+		}
+		if app.head != nil {
+			if err := app.sendAudio(app.head); err != nil {
+				return err
+			}
+		}
+		for _, data := range app.replay {
+			if err := app.sendAudio(data); err != nil {
+				return err
+			}
+		}
+		if app.lastSent {
+			return app.sendLast()
+		}
+		return nil
+	}, app.closed.Load)
 }
 
 // receiveText 接受到文本消息, 暂无实际用途
-func (app *VcAsrApp) receiveText(res []byte) (string, error) {
+func (app *VcAsrApp) receiveText(res []byte) error {
 	log.Info("receiveText: ", string(res))
-	return "", nil
+	return nil
+}
+
+// asrResponse 识别结果, 时间的单位为毫秒
+type asrResponse struct {
+	Result *struct {
+		Text       string `json:"text"`
+		Utterances []struct {
+			Text       string  `json:"text"`
+			Definite   bool    `json:"definite"`
+			StartTime  int64   `json:"start_time"`
+			EndTime    int64   `json:"end_time"`
+			Confidence float64 `json:"confidence"`
+		} `json:"utterances"`
+	} `json:"result"`
 }
 
-// receiveBytes 接收到字节流
-func (app *VcAsrApp) receiveBytes(res []byte) (string, error) {
+// receiveBytes 接收到字节流, seq小于0表示服务端的最后一个包, 之后Receive返回io.EOF
+func (app *VcAsrApp) receiveBytes(res []byte) (*model.AsrResult, error) {
 	data, seq, err := parse(res)
-	// seq 小于0 表示这是最后一个包, 后续没有了, 暂时没有通过这个来中止
-	if err != nil || seq < 0 {
-		return "", err
+	if err != nil {
+		return nil, err
+	}
+	if seq < 0 {
+		app.finished.Store(true)
+	}
+	if len(data) == 0 {
+		return nil, nil
 	}
 
-	// 反序列化, 提前识别后的文字
-	r := make(map[string]any)
-	err = json.Unmarshal(data, &r)
-	if err != nil {
-		return "", err
+	// 反序列化, 提取识别后的文字与分句
+	var r asrResponse
+	if err = json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if r.Result == nil {
+		if seq < 0 {
+			return nil, nil
+		}
+		return nil, errors.New("invalid result")
 	}
 
-	text, ok := r["result"].(map[string]any)["text"].(string)
-	if !ok {
-		return "", errors.New("invalid result")
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	result := &model.AsrResult{Text: r.Result.Text}
+	definite := false
+	for _, u := range r.Result.Utterances {
+		end := app.offset + time.Duration(u.EndTime)*time.Millisecond
+		if u.Definite && end <= app.committed {
+			// 全量返回时已经返回过的句子
+			continue
+		}
+		if u.Text == "" {
+			continue
+		}
+		result.Utterances = append(result.Utterances, model.Utterance{
+			Text:       u.Text,
+			Definite:   u.Definite,
+			Start:      app.offset + time.Duration(u.StartTime)*time.Millisecond,
+			End:        end,
+			Confidence: u.Confidence,
+		})
+		if u.Definite {
+			app.committed, definite = end, true
+		}
 	}
-	return text, nil
+	if definite {
+		app.replay, app.replaySize = nil, 0
+	}
+	return result, nil
 }
 
 // Close 释放资源
 func (app *VcAsrApp) Close() error {
+	app.closed.Store(true)
+	app.mu.Lock()
+	defer app.mu.Unlock()
 	if app.ws != nil {
 		return app.ws.Close()
 	}
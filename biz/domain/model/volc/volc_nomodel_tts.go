@@ -1,6 +1,7 @@
 package volc
 
 import (
+	"cmp"
 	"context"
 	"encoding/binary"
 	"encoding/json"
@@ -14,15 +15,22 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
 )
 
 var _ model.TtsApp = (*VcNoModelTtsApp)(nil)
+var _ model.PhraseSynthesizer = (*VcNoModelTtsApp)(nil)
+var _ model.Stylable = (*VcNoModelTtsApp)(nil)
 
+// VcNoModelTtsApp 是火山引擎的非流式语音合成, 每句话发送一次请求
+// 连接断开时自动重连, 并重发未合成完的句子
 type VcNoModelTtsApp struct {
-	// ws 连接
+	reconnector
+
+	// ws 连接, mu保护ws的写入与重连
 	ws     *websocket.Conn
 	mu     sync.Mutex
-	closed bool
+	closed atomic.Bool
 
 	appKey    string
 	accessKey string
@@ -43,26 +51,40 @@ type VcNoModelTtsApp struct {
 	sessionId string
 	// header 是请求头, 携带鉴权信息
 	header http.Header
+
+	// format 合成音频的编码
+	format string
+
+	// pending 已发送但还未合成完的句子
+	pending []string
+	// style 之后发送的句子使用的风格, 由mu保护
+	style model.Style
 }
 
-// NewVcNoModelTtsApp 构造一个新的
-func NewVcNoModelTtsApp(appKey, accessKey, speaker, cluster, url string) *VcNoModelTtsApp {
+// NewVcNoModelTtsApp 构造一个新的, format为空时使用pcm, lang为合成的语言
+func NewVcNoModelTtsApp(appKey, accessKey, speaker, cluster, lang, url, format string) *VcNoModelTtsApp {
+	if format == "" {
+		format = model.EncodingPCM
+	}
 	connId := uuid.New().String()
 	logId := genLogID()
 	sessionId := uuid.New().String()
 	app := &VcNoModelTtsApp{
-		ws:        nil,
-		appKey:    appKey,
-		accessKey: accessKey,
-		url:       url,
-		speaker:   speaker,
-		cluster:   cluster,
-		opt:       optSubmit,
-		connId:    connId,
-		logId:     logId,
-		sessionId: sessionId,
-		seq:       1,
-		mu:        sync.Mutex{},
+		reconnector: newReconnector(slotTts),
+		ws:          nil,
+		appKey:      appKey,
+		accessKey:   accessKey,
+		url:         url,
+		speaker:     speaker,
+		cluster:     cluster,
+		lang:        lang,
+		opt:         optSubmit,
+		format:      format,
+		connId:      connId,
+		logId:       logId,
+		sessionId:   sessionId,
+		seq:         1,
+		mu:          sync.Mutex{},
 	}
 	app.buildHTTPHeader()
 	return app
@@ -100,9 +122,9 @@ func (app *VcNoModelTtsApp) Start() (err error) {
 	params["audio"] = make(map[string]interface{})
 	params["audio"]["language"] = app.lang
 	params["audio"]["voice_type"] = app.speaker
-	params["audio"]["encoding"] = "pcm"
-	params["audio"]["rate"] = 24000
-	params["audio"]["speed_ratio"] = 1.0
+	params["audio"]["encoding"] = app.format
+	params["audio"]["rate"] = ttsSampleRate
+	params["audio"]["speed_ratio"] = noModelSpeedRatio
 	params["audio"]["volume_ratio"] = 1.0
 	params["audio"]["pitch_ratio"] = 1.0
 	params["request"] = make(map[string]interface{})
@@ -114,8 +136,35 @@ func (app *VcNoModelTtsApp) Start() (err error) {
 	return nil
 }
 
+// Send 发送一句文字, 发送失败时重连并重发未合成完的句子
 func (app *VcNoModelTtsApp) Send(text string) (err error) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.pending = append(app.pending, text)
+	if len(app.pending) > maxPending {
+		app.pending = app.pending[1:]
+	}
+	if err = app.write(text); err != nil {
+		glog.Errorf("Send message error: %v", err)
+		return app.reconnectLocked(app.ws)
+	}
+	return nil
+}
+
+// write 发送一次合成请求
+func (app *VcNoModelTtsApp) write(text string) (err error) {
 	app.params["request"]["text"] = text
+	audio := app.params["audio"]
+	audio["voice_type"] = cmp.Or(app.style.Speaker, app.speaker)
+	audio["speed_ratio"] = noModelSpeedRatio * styleRatio(app.style.SpeechRate)
+	audio["pitch_ratio"] = styleRatio(app.style.PitchRate)
+	audio["volume_ratio"] = styleRatio(app.style.Volume)
+	if app.style.Emotion != "" {
+		audio["emotion"], audio["enable_emotion"] = app.style.Emotion, true
+	} else {
+		delete(audio, "emotion")
+		delete(audio, "enable_emotion")
+	}
 	input, err := json.Marshal(app.params)
 	if err != nil {
 		return err
@@ -138,26 +187,146 @@ func (app *VcNoModelTtsApp) Send(text string) (err error) {
 	return nil
 }
 
-func (app *VcNoModelTtsApp) Receive() []byte {
-	if app.ws == nil || app.closed {
-		return nil
+// Receive 接收音频, 连接断开时重连并重发未合成完的句子
+func (app *VcNoModelTtsApp) Receive() ([]byte, error) {
+	for {
+		if app.closed.Load() {
+			return nil, io.EOF
+		}
+		ws := app.conn()
+		var msg []byte
+		err := errNotConnected
+		if ws != nil {
+			_, msg, err = ws.ReadMessage()
+		}
+		if err != nil {
+			if app.closed.Load() {
+				return nil, io.EOF
+			}
+			glog.Errorf("Receive message error: %v", err)
+			if err = app.reconnect(ws); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		resp, err := parseResponse(msg)
+		if err != nil {
+			// 服务端拒绝了这一句, 重发也不会成功
+			glog.Errorf("Receive response error: %v", err)
+			app.ack()
+			continue
+		}
+		if resp.IsLast {
+			app.ack()
+		}
+		if len(resp.Audio) > 0 {
+			return resp.Audio, nil
+		}
 	}
-	_, msg, err := app.ws.ReadMessage()
-	if err != nil {
-		glog.Errorf("Receive message error: %v", err)
+}
+
+func (app *VcNoModelTtsApp) conn() *websocket.Conn {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.ws
+}
+
+// reconnect 重新建立连接, 并重发未合成完的句子
+// failed为断开的连接, 其他协程已经完成重连时直接返回
+func (app *VcNoModelTtsApp) reconnect(failed *websocket.Conn) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.reconnectLocked(failed)
+}
+
+func (app *VcNoModelTtsApp) reconnectLocked(failed *websocket.Conn) error {
+	if app.ws != failed {
 		return nil
 	}
-	resp, err := parseResponse(msg)
-	if err != nil {
-		glog.Errorf("Receive response error: %v", err)
+	return app.retry(func() error {
+		if app.ws != nil {
+			_ = app.ws.Close()
+		}
+		app.connId = uuid.New().String()
+		if err := app.Dial(); err != nil {
+			return err
+		}
+		if err := app.Start(); err != nil {
+			return err
+		}
+		for _, text := range app.pending {
+			if err := app.write(text); err != nil {
+				return err
+			}
+		}
 		return nil
+	}, app.closed.Load)
+}
+
+// SetStyle 设置之后发送的句子使用的风格
+func (app *VcNoModelTtsApp) SetStyle(s model.Style) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.style = s
+}
+
+// styleRatio 将风格的调整换算为非流式合成的倍率, 如-20为0.8倍
+func styleRatio(rate int) float64 {
+	return 1 + float64(max(-50, min(100, rate)))/100
+}
+
+// ack 一句合成完成, 每句请求的音频以最后一个包结束
+func (app *VcNoModelTtsApp) ack() {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if len(app.pending) > 0 {
+		app.pending = app.pending[1:]
+	}
+}
+
+// noModelSpeedRatio 非流式合成的语速
+const noModelSpeedRatio = 1.0
+
+// Voice 当前使用的音色与格式
+func (app *VcNoModelTtsApp) Voice() model.Voice {
+	return model.Voice{Speaker: app.speaker, Format: app.format, SampleRate: ttsSampleRate, Speed: noModelSpeedRatio}
+}
+
+// Synthesize 合成一段文字, 收到最后一个音频包后返回
+func (app *VcNoModelTtsApp) Synthesize(text string) ([]byte, error) {
+	if err := app.Send(text); err != nil {
+		return nil, err
+	}
+	var audio []byte
+	for {
+		_, msg, err := app.ws.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := parseResponse(msg)
+		if err != nil {
+			return nil, err
+		}
+		audio = append(audio, resp.Audio...)
+		if resp.IsLast {
+			return audio, nil
+		}
+	}
+}
+
+// Ping 发送ping帧检查连接是否可用, 用于连接池的健康检查
+func (app *VcNoModelTtsApp) Ping() error {
+	if app.closed.Load() {
+		return errNotConnected
 	}
-	return resp.Audio
+	return ping(app.conn())
 }
 
 // Close 关闭连接释放资源
 func (app *VcNoModelTtsApp) Close() (err error) {
-	app.closed = true
+	app.closed.Store(true)
+	app.mu.Lock()
+	defer app.mu.Unlock()
 	if app.ws == nil {
 		return
 	}
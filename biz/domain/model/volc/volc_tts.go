@@ -1,6 +1,7 @@
 package volc
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -15,24 +16,47 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
 var _ model.TtsApp = (*VcTtsApp)(nil)
+var _ model.PhraseSynthesizer = (*VcTtsApp)(nil)
+var _ model.Captioner = (*VcTtsApp)(nil)
+var _ model.Stylable = (*VcTtsApp)(nil)
+
+// 合成音频的格式, 编码可以为pcm或ogg_opus
+const (
+	ttsSampleRate = 24000
+	ttsSpeechRate = 14
+)
+
+// maxPending 最多保留的未合成完的句子数, 用于断线重连后重发
+const maxPending = 20
+
+// closeTimeout 关闭连接时等待服务端响应的超时时间
+const closeTimeout = 2 * time.Second
 
 // VcTtsApp 是火山引擎的大模型语音合成
 // 默认双向流式, 暂定一次对话共用一个连接, 如果太长了之后就一轮话一个连接
 // 默认使用PCM格式, 24000采样频率
+// 连接断开时自动重连, 并重发未合成完的句子
 type VcTtsApp struct {
-	// ws 连接
-	ws *websocket.Conn
-	mu sync.Mutex
+	reconnector
+
+	// ws 连接, mu保护ws的写入与重连
+	ws     *websocket.Conn
+	mu     sync.Mutex
+	closed atomic.Bool
 
 	appKey     string
 	accessKey  string
 	speaker    string
 	resourceId string
 	url        string
+	// format 合成音频的编码
+	format string
 
 	// connId 连接id, 标识一次连接
 	connId string
@@ -42,23 +66,43 @@ type VcTtsApp struct {
 	sessionId string
 	// header 是请求头, 携带鉴权信息
 	header http.Header
+	// sessionDone 会话已经结束, 关闭时不再发送结束会话请求
+	sessionDone bool
+
+	// pending 已发送但还未合成完的句子
+	pending []string
+	// acked 第一个句子中已经合成完的字数
+	acked int
+
+	// style 之后发送的句子使用的风格, 由mu保护
+	style model.Style
+
+	captionMu sync.Mutex
+	// caption 字幕事件的回调
+	caption func(c *model.Caption)
 }
 
-func NewVcTtsApp(appKey, accessKey, speaker, resourceId, url string) *VcTtsApp {
+// NewVcTtsApp 构造一个新的, format为空时使用pcm
+func NewVcTtsApp(appKey, accessKey, speaker, resourceId, url, format string) *VcTtsApp {
+	if format == "" {
+		format = model.EncodingPCM
+	}
 	connId := uuid.New().String()
 	logId := genLogID()
 	sessionId := uuid.New().String()
 	app := &VcTtsApp{
-		ws:         nil,
-		appKey:     appKey,
-		accessKey:  accessKey,
-		speaker:    speaker,
-		url:        url,
-		resourceId: resourceId,
-		connId:     connId,
-		logId:      logId,
-		sessionId:  sessionId,
-		mu:         sync.Mutex{},
+		reconnector: newReconnector(slotTts),
+		ws:          nil,
+		appKey:      appKey,
+		accessKey:   accessKey,
+		speaker:     speaker,
+		url:         url,
+		resourceId:  resourceId,
+		format:      format,
+		connId:      connId,
+		logId:       logId,
+		sessionId:   sessionId,
+		mu:          sync.Mutex{},
 	}
 	app.buildHTTPHeader()
 	return app
@@ -91,9 +135,10 @@ func (app *VcTtsApp) Start() (err error) {
 	params := &TTSReqParams{
 		Speaker: app.speaker,
 		AudioParams: &AudioParams{
-			Format:     "pcm",
-			SampleRate: 24000,
-			SpeechRate: 14,
+			Format:          app.format,
+			SampleRate:      ttsSampleRate,
+			SpeechRate:      ttsSpeechRate,
+			EnableTimestamp: true,
 		},
 	}
 	if err = app.startTTSSession(namespace, params); err != nil {
@@ -201,9 +246,19 @@ func (app *VcTtsApp) startTTSSession(namespace string, params *TTSReqParams) err
 	return nil
 }
 
-// Send 发送请求
+// Send 发送请求, 发送失败时重连并重发未合成完的句子
 func (app *VcTtsApp) Send(text string) (err error) {
-	return app.sendTtsMessage(text)
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.pending = append(app.pending, text)
+	if len(app.pending) > maxPending {
+		app.pending, app.acked = app.pending[1:], 0
+	}
+	if err = app.sendTtsMessage(text); err != nil {
+		glog.Errorf("Send message error: %v", err)
+		return app.reconnectLocked(app.ws)
+	}
+	return nil
 }
 
 // sendTtsMessage 发送一条tts消息
@@ -213,10 +268,15 @@ func (app *VcTtsApp) sendTtsMessage(text string) error {
 		Namespace: "BidirectionalTTS",
 		ReqParams: &TTSReqParams{
 			Text:    text,
-			Speaker: app.speaker,
+			Speaker: cmp.Or(app.style.Speaker, app.speaker),
 			AudioParams: &AudioParams{
-				Format:     "pcm",
-				SampleRate: 24000,
+				Format:          app.format,
+				SampleRate:      ttsSampleRate,
+				SpeechRate:      styleRate(ttsSpeechRate + app.style.SpeechRate),
+				PitchRate:       styleRate(app.style.PitchRate),
+				Volume:          styleRate(app.style.Volume),
+				Emotion:         app.style.Emotion,
+				EnableTimestamp: true,
 			},
 		},
 	}
@@ -238,8 +298,6 @@ func (app *VcTtsApp) sendTtsMessage(text string) error {
 		return fmt.Errorf("marshal TaskRequest request message: %w", err)
 	}
 
-	app.mu.Lock()
-	defer app.mu.Unlock()
 	if err := app.ws.WriteMessage(websocket.BinaryMessage, frame); err != nil {
 		return fmt.Errorf("send TaskRequest request: %w", err)
 	}
@@ -248,40 +306,226 @@ func (app *VcTtsApp) sendTtsMessage(text string) error {
 	return nil
 }
 
-// Receive 接收请求
-func (app *VcTtsApp) Receive() []byte {
+// Receive 接收音频, 连接断开或会话出错时重连并重发未合成完的句子
+func (app *VcTtsApp) Receive() ([]byte, error) {
 	for {
-		msg, err := app.receiveMessage()
+		ws := app.conn()
+		msg, err := app.receiveMessage(ws)
+		if err == nil && msg.Type == MsgTypeError {
+			err = fmt.Errorf("receive error message (code=%d): %s", msg.ErrorCode, msg.Payload)
+		}
 		if err != nil {
+			if app.closed.Load() {
+				return nil, io.EOF
+			}
 			glog.Errorf("Receive message error: %v", err)
-			return nil
+			if err = app.reconnect(ws); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		switch msg.Type {
 		case MsgTypeFullServer:
 			glog.Infof("Receive text message (event=%s, session_id=%s): %s", Event(msg.Event), msg.SessionID, msg.Payload)
-			if msg.Event == int32(EventSessionFinished) {
+			switch Event(msg.Event) {
+			case EventTTSSentenceStart:
+				c := parseSentence(msg.Payload)
+				app.emitCaption(&model.Caption{Text: c.Text})
+			case EventTTSSentenceEnd:
+				c := parseSentence(msg.Payload)
+				app.ack(c.Text)
+				c.End = true
+				app.emitCaption(c)
+			case EventSessionFinished:
 				log.Info("event type:", msg.Event)
-				return nil
+				return nil, io.EOF
 			}
-			continue
-
 		case MsgTypeAudioOnlyServer:
 			glog.Infof("Receive audio message (event=%s): session_id=%s", Event(msg.Event), msg.SessionID)
-			return msg.Payload
-
-		case MsgTypeError:
-			glog.Errorf("Receive Error message (code=%d): %s", msg.ErrorCode, msg.Payload)
-			return nil
+			return msg.Payload, nil
 		default:
 			glog.Errorf("Received unexpected message type: %s", msg.Type)
-			return nil
+		}
+	}
+}
+
+func (app *VcTtsApp) conn() *websocket.Conn {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.ws
+}
+
+// reconnect 重新建立连接与会话, 并重发未合成完的句子
+// failed为断开的连接, 其他协程已经完成重连时直接返回
+func (app *VcTtsApp) reconnect(failed *websocket.Conn) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.reconnectLocked(failed)
+}
+
+func (app *VcTtsApp) reconnectLocked(failed *websocket.Conn) error {
+	if app.ws != failed {
+		return nil
+	}
+	return app.retry(func() error {
+		if app.ws != nil {
+			_ = app.ws.Close()
+		}
+		app.connId, app.sessionId = uuid.New().String(), uuid.New().String()
+		app.buildHTTPHeader()
+		if err := app.Dial(); err != nil {
+			return err
+		}
+		if err := app.Start(); err != nil {
+			return err
+		}
+		app.acked = 0
+		for _, text := range app.pending {
+			if err := app.sendTtsMessage(text); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, app.closed.Load)
+}
+
+// ack 服务端合成完一句, 按字数从未合成完的句子中移除
+// 服务端的分句与发送的句子不一定一致, 无法解析出文本时按一句移除
+func (app *VcTtsApp) ack(text string) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	n := speakable(text)
+	if n == 0 {
+		if len(app.pending) > 0 {
+			app.pending, app.acked = app.pending[1:], 0
+		}
+		return
+	}
+	for n > 0 && len(app.pending) > 0 {
+		rest := speakable(app.pending[0]) - app.acked
+		if n < rest {
+			app.acked += n
+			return
+		}
+		n -= rest
+		app.pending, app.acked = app.pending[1:], 0
+	}
+}
+
+// SetStyle 设置之后发送的句子使用的风格, 重连后重发的句子同样使用该风格
+func (app *VcTtsApp) SetStyle(s model.Style) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.style = s
+}
+
+// styleRate 将风格的调整限制在服务端允许的范围内
+func styleRate(rate int) int32 {
+	return int32(max(-50, min(100, rate)))
+}
+
+// OnCaption 设置字幕事件的回调
+func (app *VcTtsApp) OnCaption(f func(c *model.Caption)) {
+	app.captionMu.Lock()
+	defer app.captionMu.Unlock()
+	app.caption = f
+}
+
+func (app *VcTtsApp) emitCaption(c *model.Caption) {
+	app.captionMu.Lock()
+	f := app.caption
+	app.captionMu.Unlock()
+	if f != nil {
+		f(c)
+	}
+}
+
+// sentenceWord 开启时间戳后句子结束事件中各字词的时间, 单位秒
+type sentenceWord struct {
+	Word      string  `json:"word"`
+	StartTime float64 `json:"startTime"`
+	EndTime   float64 `json:"endTime"`
+}
+
+// parseSentence 解析句子事件中的文本与各字词的时间
+func parseSentence(payload []byte) *model.Caption {
+	var v struct {
+		Text      string         `json:"text"`
+		Words     []sentenceWord `json:"words"`
+		ResParams struct {
+			Text  string         `json:"text"`
+			Words []sentenceWord `json:"words"`
+		} `json:"res_params"`
+	}
+	_ = json.Unmarshal(payload, &v)
+	c := &model.Caption{Text: v.Text}
+	if c.Text == "" {
+		c.Text = v.ResParams.Text
+	}
+	words := v.Words
+	if len(words) == 0 {
+		words = v.ResParams.Words
+	}
+	for _, w := range words {
+		c.Words = append(c.Words, model.Word{
+			Text:  w.Word,
+			Start: time.Duration(w.StartTime * float64(time.Second)),
+			End:   time.Duration(w.EndTime * float64(time.Second)),
+		})
+	}
+	return c
+}
+
+// speakable 统计会被读出的字数, 不含标点与空白
+func speakable(text string) int {
+	n := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// Voice 当前使用的音色与格式
+func (app *VcTtsApp) Voice() model.Voice {
+	return model.Voice{Speaker: app.speaker, Format: app.format, SampleRate: ttsSampleRate, Speed: ttsSpeechRate}
+}
+
+// Synthesize 在已握手的连接上合成一段文字, 结束会话并收齐音频后返回
+func (app *VcTtsApp) Synthesize(text string) ([]byte, error) {
+	if err := app.Send(text); err != nil {
+		return nil, err
+	}
+	if err := app.finishSession(); err != nil {
+		return nil, err
+	}
+	app.sessionDone = true
+	var audio []byte
+	for {
+		msg, err := app.receiveMessage(app.ws)
+		if err != nil {
+			return nil, err
+		}
+		switch msg.Type {
+		case MsgTypeAudioOnlyServer:
+			audio = append(audio, msg.Payload...)
+		case MsgTypeFullServer:
+			if msg.Event == int32(EventSessionFinished) {
+				return audio, nil
+			}
+		case MsgTypeError:
+			return nil, fmt.Errorf("synthesize error (code=%d): %s", msg.ErrorCode, msg.Payload)
 		}
 	}
 }
 
 // receiveMessage 从ws中接受消息
-func (app *VcTtsApp) receiveMessage() (*Message, error) {
-	mt, frame, err := app.ws.ReadMessage()
+func (app *VcTtsApp) receiveMessage(ws *websocket.Conn) (*Message, error) {
+	if ws == nil {
+		return nil, errNotConnected
+	}
+	mt, frame, err := ws.ReadMessage()
 	if err != nil {
 		return nil, err
 	}
@@ -300,13 +544,24 @@ func (app *VcTtsApp) receiveMessage() (*Message, error) {
 	return msg, nil
 }
 
+// Ping 发送ping帧检查连接是否可用, 用于连接池的健康检查
+func (app *VcTtsApp) Ping() error {
+	return ping(app.conn())
+}
+
 // Close 关闭连接释放资源
 func (app *VcTtsApp) Close() (err error) {
+	app.closed.Store(true)
+	app.mu.Lock()
+	defer app.mu.Unlock()
 	if app.ws == nil {
 		return nil
 	}
-	if err = app.finishSession(); err != nil {
-		glog.Errorf("Close session finished with error: %v", err)
+	_ = app.ws.SetReadDeadline(time.Now().Add(closeTimeout))
+	if !app.sessionDone {
+		if err = app.finishSession(); err != nil {
+			glog.Errorf("Close session finished with error: %v", err)
+		}
 	}
 	if err = app.finishConnection(); err != nil {
 		glog.Errorf("Close connection finished with error: %v", err)
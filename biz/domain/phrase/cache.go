@@ -0,0 +1,172 @@
+package phrase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/domain/language"
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/domain/model/failover"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	rs "github.com/xh-polaris/psych-senior/biz/infrastructure/redis"
+	"github.com/zeromicro/go-zero/core/syncx"
+)
+
+// 固定话术的音频缓存, 如额度用尽, 服务不可用时的提示语, 命中时直接播放, 省去合成的耗时与费用
+// 以 文字, 音色, 格式, 采样率, 语速 区分, 未命中时在后台合成并写入, 也可以通过warm接口预先合成
+// warm接口只合成配置的话术与各语言的固定话术, 避免被用于合成任意文字
+
+// 存储方式
+const (
+	StoreDisk  = "disk"
+	StoreRedis = "redis"
+	StoreNone  = "none"
+)
+
+const defaultDir = "data/phrases"
+
+// ErrUnknownPhrase 要求预先合成的话术不在配置中
+var ErrUnknownPhrase = errors.New("unknown phrase")
+
+type Cache struct {
+	// store 为nil时不缓存
+	store  Store
+	flight syncx.SingleFlight
+	// phrases 配置的需要预先合成的话术
+	phrases []string
+}
+
+// WarmResult 预先合成的结果
+type WarmResult struct {
+	// Filled 本次合成的音频数
+	Filled int
+	// Cached 已经缓存的音频数
+	Cached int
+	// Failed 合成失败的音频数
+	Failed int
+}
+
+var (
+	instance *Cache
+	once     sync.Once
+)
+
+func GetCache() *Cache {
+	once.Do(func() {
+		c := config.GetConfig()
+		instance = NewCache(c, c.PhraseCache)
+	})
+	return instance
+}
+
+func NewCache(c *config.Config, pc config.PhraseCache) *Cache {
+	cache := &Cache{
+		flight:  syncx.NewSingleFlight(),
+		phrases: pc.Phrases,
+	}
+	// 额度用尽的提示语总是需要预先合成
+	if c.Quota.Message != "" {
+		cache.phrases = append([]string{c.Quota.Message}, pc.Phrases...)
+	}
+	switch pc.Store {
+	case StoreNone:
+	case StoreRedis:
+		cache.store = &redisStore{rds: rs.NewRedis(c), expire: int(pc.Expire)}
+	default:
+		dir := pc.Dir
+		if dir == "" {
+			dir = defaultDir
+		}
+		cache.store = &diskStore{dir: dir}
+	}
+	return cache
+}
+
+// Key 音频的缓存key
+func Key(voice model.Voice, text string) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%g|%s", voice.Speaker, voice.Format, voice.SampleRate, voice.Speed, text)))
+	return hex.EncodeToString(h[:])
+}
+
+// Get 读取缓存的音频, 未命中或读取失败时返回nil
+func (c *Cache) Get(ctx context.Context, voice model.Voice, text string) []byte {
+	if c.store == nil || voice.Speaker == "" {
+		return nil
+	}
+	audio, err := c.store.Get(ctx, Key(voice, text))
+	if err != nil {
+		log.Error("get phrase audio err:", err)
+		return nil
+	}
+	return audio
+}
+
+// Fill 合成一段文字并写入缓存, 同一段音频同时只合成一次
+func (c *Cache) Fill(ctx context.Context, s model.PhraseSynthesizer, text string) error {
+	voice := s.Voice()
+	if c.store == nil || voice.Speaker == "" {
+		return nil
+	}
+	key := Key(voice, text)
+	_, err := c.flight.Do(key, func() (any, error) {
+		audio, err := s.Synthesize(text)
+		if err != nil {
+			return nil, err
+		}
+		if len(audio) == 0 {
+			return nil, fmt.Errorf("empty audio for phrase %q", text)
+		}
+		return nil, c.store.Set(ctx, key, audio)
+	})
+	return err
+}
+
+// Warm 为各语言的语音合成预先合成话术, phrases为空时合成全部配置的话术
+func (c *Cache) Warm(ctx context.Context, phrases []string) (*WarmResult, error) {
+	langs := language.GetRegistry().List()
+	for _, text := range phrases {
+		if !slices.ContainsFunc(langs, func(l *language.Language) bool { return slices.Contains(c.texts(l), text) }) {
+			return nil, ErrUnknownPhrase
+		}
+	}
+	res := &WarmResult{}
+	for _, l := range langs {
+		s, ok := failover.NewLangTtsApp(l.Tts, model.EncodingPCM).(model.PhraseSynthesizer)
+		if !ok {
+			continue
+		}
+		for _, text := range c.texts(l) {
+			if len(phrases) > 0 && !slices.Contains(phrases, text) {
+				continue
+			}
+			if c.Get(ctx, s.Voice(), text) != nil {
+				res.Cached++
+				continue
+			}
+			if err := c.Fill(ctx, s, text); err != nil {
+				log.Error("warm phrase err:", err)
+				res.Failed++
+				continue
+			}
+			res.Filled++
+		}
+	}
+	return res, nil
+}
+
+// texts 一种语言需要缓存的话术, 包括配置的话术与该语言的固定话术
+func (c *Cache) texts(l *language.Language) []string {
+	texts := slices.Clone(c.phrases)
+	for _, text := range language.Texts(l.Scripts) {
+		if text != "" && !slices.Contains(texts, text) {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
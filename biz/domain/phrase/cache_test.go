@@ -0,0 +1,46 @@
+package phrase
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+type fakeSynthesizer struct {
+	voice model.Voice
+	calls atomic.Int32
+}
+
+func (s *fakeSynthesizer) Voice() model.Voice { return s.voice }
+
+func (s *fakeSynthesizer) Synthesize(text string) ([]byte, error) {
+	s.calls.Add(1)
+	return []byte(s.voice.Speaker + ":" + text), nil
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(&config.Config{}, config.PhraseCache{Store: StoreDisk, Dir: t.TempDir()})
+	s := &fakeSynthesizer{voice: model.Voice{Speaker: "a", Format: "pcm", SampleRate: 24000, Speed: 1}}
+
+	if audio := c.Get(ctx, s.Voice(), "你好"); audio != nil {
+		t.Fatalf("unexpected hit %q", audio)
+	}
+	if err := c.Fill(ctx, s, "你好"); err != nil {
+		t.Fatal(err)
+	}
+	if audio := c.Get(ctx, s.Voice(), "你好"); !bytes.Equal(audio, []byte("a:你好")) {
+		t.Fatalf("got %q", audio)
+	}
+
+	// 音色或语速不同时不命中
+	other := s.Voice()
+	other.Speed = 1.2
+	if audio := c.Get(ctx, other, "你好"); audio != nil {
+		t.Fatalf("unexpected hit %q", audio)
+	}
+}
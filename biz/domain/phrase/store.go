@@ -0,0 +1,81 @@
+package phrase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+// Store 音频的存储
+type Store interface {
+	// Get 读取音频, 不存在时返回nil
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, audio []byte) error
+}
+
+// diskStore 本地磁盘存储, 每段音频一个文件
+type diskStore struct {
+	dir string
+}
+
+func (s *diskStore) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+func (s *diskStore) Get(_ context.Context, key string) ([]byte, error) {
+	audio, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return audio, err
+}
+
+// Set 先写入临时文件再重命名, 避免读到写了一半的音频
+func (s *diskStore) Set(_ context.Context, key string, audio []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err = f.Write(audio); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// redisStore redis存储, 适合多实例共享
+type redisStore struct {
+	rds *redis.Redis
+	// expire 过期时间, 单位秒, 0表示不过期
+	expire int
+}
+
+func redisKey(key string) string {
+	return "phrase:" + key
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := s.rds.GetCtx(ctx, redisKey(key))
+	if err != nil || val == "" {
+		return nil, err
+	}
+	return []byte(val), nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, audio []byte) error {
+	if s.expire > 0 {
+		return s.rds.SetexCtx(ctx, redisKey(key), string(audio), s.expire)
+	}
+	return s.rds.SetCtx(ctx, redisKey(key), string(audio))
+}
@@ -0,0 +1,117 @@
+package preference
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 对话中调整偏好的语音指令, 如"说慢一点", "大声点"
+
+// maxIntentChars 指令的最多字数, 更长的话视为正常对话, 避免误判"我女儿说话太快了"之类
+const maxIntentChars = 12
+
+// 每次指令的调整幅度
+const (
+	rateStep     = 15
+	volumeStep   = 20
+	textSizeStep = 1
+)
+
+// Intent 一条调整偏好的指令
+type Intent struct {
+	// apply 调整偏好, 已到达取值范围的边界时返回false
+	apply func(p *Preference) bool
+	// done, limit 调整成功与已到边界时的回复
+	done, limit string
+}
+
+// 指令只匹配句首的命令句式, 如"说慢一点", "你大声点"
+// 抱怨的话只有明确对助手说时才算指令, 如"你说得太快了", 避免"外面太吵了", "我看不清路"之类被误判
+const (
+	// polite 句首可选的请求语气
+	polite = `(?:请|麻烦)?(?:你|您)?(?:能不能|可不可以|可以)?再?`
+	// you 明确对助手说
+	you = `(?:你|您)`
+)
+
+// command 由句首的多种句式组成的匹配
+func command(forms ...string) *regexp.Regexp {
+	return regexp.MustCompile(`^(?:` + strings.Join(forms, "|") + `)`)
+}
+
+var intents = []struct {
+	pattern *regexp.Regexp
+	intent  Intent
+}{
+	{
+		command(polite+`(?:说|讲)话?得?慢一?点`, polite+`慢一?点(?:说|讲)`, you+`(?:说|讲)(?:话|得)*太快`),
+		Intent{step(rateOf, -rateStep, minRate, maxRate), "好的，我说慢一点。", "已经是最慢的语速啦，您要是哪里没听清，我再说一遍。"},
+	},
+	{
+		command(polite+`(?:说|讲)话?得?快一?点`, polite+`快一?点(?:说|讲)`, you+`(?:说|讲)(?:话|得)*太慢`),
+		Intent{step(rateOf, rateStep, minRate, maxRate), "好的，我说快一点。", "已经是最快的语速啦。"},
+	},
+	{
+		command(polite+`(?:说|讲)?(?:大声|声音大)一?点`, you+`的?声音太小`, `我?听不(?:清|见)`+you),
+		Intent{step(volumeOf, volumeStep, minRate, maxRate), "好的，我大声一点。", "已经是最大的声音啦，您可以把设备的音量调大一些。"},
+	},
+	{
+		command(polite+`(?:说|讲)?(?:小声|声音小)一?点`, you+`的?声音太大`, you+`太吵`),
+		Intent{step(volumeOf, -volumeStep, minRate, maxRate), "好的，我小声一点。", "已经是最小的声音啦。"},
+	},
+	{
+		command(polite+`把?字(?:调|放)?大一?点`, you+`?的?字太小`, `我?看不清(?:屏幕上的|上面的)?字`),
+		Intent{step(textSizeOf, textSizeStep, minTextSize, maxTextSize), "好的，字已经调大了。", "字已经是最大的啦。"},
+	},
+	{
+		command(polite+`把?字(?:调|缩)?小一?点`, you+`?的?字太大`),
+		Intent{step(textSizeOf, -textSizeStep, minTextSize, maxTextSize), "好的，字已经调小了。", "字已经是最小的啦。"},
+	},
+}
+
+var (
+	rateOf     = func(p *Preference) *int { return &p.SpeechRate }
+	volumeOf   = func(p *Preference) *int { return &p.Volume }
+	textSizeOf = func(p *Preference) *int { return &p.TextSize }
+)
+
+func step(field func(p *Preference) *int, delta, lo, hi int) func(p *Preference) bool {
+	return func(p *Preference) bool {
+		v := field(p)
+		next := max(lo, min(hi, *v+delta))
+		if next == *v {
+			return false
+		}
+		*v = next
+		return true
+	}
+}
+
+// Match 识别一句话中调整偏好的指令
+func Match(text string) (*Intent, bool) {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, text)
+	if text == "" || utf8.RuneCountInString(text) > maxIntentChars {
+		return nil, false
+	}
+	for _, i := range intents {
+		if i.pattern.MatchString(text) {
+			return &i.intent, true
+		}
+	}
+	return nil, false
+}
+
+// Apply 调整偏好, 返回给老人的回复与偏好是否有变化
+func (i *Intent) Apply(p *Preference) (string, bool) {
+	if i.apply(p) {
+		return i.done, true
+	}
+	return i.limit, false
+}
@@ -0,0 +1,41 @@
+package preference
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	p := &Preference{}
+	for _, text := range []string{"说慢一点", "你说慢点儿！", "大声点", "字大一点"} {
+		i, ok := Match(text)
+		if !ok {
+			t.Fatalf("Match(%q) failed", text)
+		}
+		if _, changed := i.Apply(p); !changed {
+			t.Errorf("Apply(%q) should change preference", text)
+		}
+	}
+	if p.SpeechRate != -2*rateStep || p.Volume != volumeStep || p.TextSize != textSizeStep {
+		t.Errorf("preference = %+v", p)
+	}
+	if _, ok := Match("我女儿平时说话总是太快了听不清楚"); ok {
+		t.Error("long sentence should not match")
+	}
+	// 没有对助手说的抱怨不是指令
+	for _, text := range []string{"今天天气不错", "我女儿说话太快了", "外面太吵了", "我看不清路", "听不清楚", "慢点走"} {
+		if _, ok := Match(text); ok {
+			t.Errorf("Match(%q) should not match", text)
+		}
+	}
+	for _, text := range []string{"你说得太快了", "请您大声一点", "我听不清你说的", "你太吵了", "看不清字"} {
+		if _, ok := Match(text); !ok {
+			t.Errorf("Match(%q) failed", text)
+		}
+	}
+}
+
+func TestIntentLimit(t *testing.T) {
+	p := &Preference{SpeechRate: minRate}
+	i, _ := Match("说慢一点")
+	if _, changed := i.Apply(p); changed || p.SpeechRate != minRate {
+		t.Errorf("speech rate = %d", p.SpeechRate)
+	}
+}
@@ -0,0 +1,89 @@
+package preference
+
+import (
+	"context"
+	"sync"
+
+	"github.com/xh-polaris/psych-senior/biz/domain/model"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/mapper/preference"
+)
+
+// 各项偏好的取值范围, 语速与音量与语音风格的调整一致
+const (
+	minRate     = -50
+	maxRate     = 100
+	minTextSize = -2
+	maxTextSize = 4
+)
+
+// Preference 老人的语音与显示偏好
+type Preference = preference.Preference
+
+// Store 老人偏好的读取与保存
+type Store struct {
+	mapper preference.IMongoMapper
+}
+
+var (
+	store *Store
+	once  sync.Once
+)
+
+func GetStore() *Store {
+	once.Do(func() {
+		store = NewStore(preference.GetMongoMapper())
+	})
+	return store
+}
+
+func NewStore(mapper preference.IMongoMapper) *Store {
+	return &Store{mapper: mapper}
+}
+
+// Load 读取老人的偏好, 没有保存过时返回默认值
+func (s *Store) Load(ctx context.Context, seniorId string) (*Preference, error) {
+	p, err := s.mapper.FindOne(ctx, seniorId)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = &Preference{SeniorId: seniorId}
+	}
+	return p, nil
+}
+
+// Save 校验取值范围后保存老人的偏好
+func (s *Store) Save(ctx context.Context, p *Preference) error {
+	Clamp(p)
+	return s.mapper.Upsert(ctx, p)
+}
+
+// SaveRecordConsent 保存老人是否同意录音, 与语音和显示偏好分开修改
+func (s *Store) SaveRecordConsent(ctx context.Context, seniorId string, consent bool) error {
+	return s.mapper.UpsertRecordConsent(ctx, seniorId, consent)
+}
+
+// Clamp 将各项偏好限制在取值范围内
+func Clamp(p *Preference) {
+	p.SpeechRate = max(minRate, min(maxRate, p.SpeechRate))
+	p.Volume = max(minRate, min(maxRate, p.Volume))
+	p.TextSize = max(minTextSize, min(maxTextSize, p.TextSize))
+}
+
+// Apply 在情绪风格的基础上叠加老人的偏好, p为nil时不调整
+func Apply(p *Preference, s model.Style) model.Style {
+	if p == nil {
+		return s
+	}
+	if p.Speaker != "" {
+		s.Speaker = p.Speaker
+	}
+	s.SpeechRate += p.SpeechRate
+	s.Volume += p.Volume
+	return s
+}
+
+// Default 偏好是否与默认的语音一致, 不一致时不能使用按默认语音缓存的音频
+func Default(p *Preference) bool {
+	return p == nil || p.Speaker == "" && p.SpeechRate == 0 && p.Volume == 0
+}
@@ -0,0 +1,159 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/domain/audio"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/blob"
+)
+
+// Playback 可以流式读取的录音
+type Playback struct {
+	io.ReadCloser
+	// Size 长度, 未知时为-1
+	Size        int64
+	ContentType string
+}
+
+// Open 按时间顺序连接一方在一次会话中的录音, turn小于0时包含所有轮次
+// 各段的格式不同时只保留与第一段相同的, wav合并为一个文件
+// ogg重新封装为一个流, 只保留第一段的头部, 页序号与granule连续, 长度在读完之前无法确定
+func (r *Recorder) Open(ctx context.Context, sessionId, side string, turn int64) (*Playback, error) {
+	recs, err := r.mapper.FindMany(ctx, sessionId, side, turn)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, ErrNotFound
+	}
+	first := recs[0]
+	same := make([]*Recording, 0, len(recs))
+	for _, rec := range recs {
+		if rec.Format != first.Format || rec.SampleRate != first.SampleRate || rec.Channels != first.Channels {
+			log.Error("skip recording of different format:", rec.Key)
+			continue
+		}
+		same = append(same, rec)
+	}
+
+	c := &chain{store: r.store, recs: same}
+	if first.Format == FormatOgg {
+		c.ogg = &remux{}
+		return &Playback{ReadCloser: c, Size: -1, ContentType: "audio/ogg"}, nil
+	}
+	var size int64
+	for _, rec := range same {
+		size += rec.Size - audio.WavHeaderSize
+	}
+	c.skip = audio.WavHeaderSize
+	c.out.Write(audio.WavHeader(first.SampleRate, first.Channels, uint32(size)))
+	return &Playback{ReadCloser: c, Size: size + audio.WavHeaderSize, ContentType: "audio/wav"}, nil
+}
+
+// remux 将多段ogg的数据包重新封装为一个流
+type remux struct {
+	r *audio.OggReader
+	w *audio.OggWriter
+}
+
+// next 开始读取下一段
+func (m *remux) next() {
+	m.r = audio.NewOggReader()
+}
+
+// write 解析一块数据, 将其中的数据包封装后写入out, 第一个数据包之前写入头部页
+func (m *remux) write(out *bytes.Buffer, data []byte) {
+	packets, err := m.r.Write(data)
+	if err != nil {
+		log.Error("parse recording ogg err:", err)
+	}
+	for _, p := range packets {
+		if len(p) > audio.MaxOggPacket {
+			continue
+		}
+		if m.w == nil {
+			channels, rate := m.r.Head()
+			m.w = audio.NewOggWriter(uint32(time.Now().UnixNano()), channels, rate)
+			out.Write(m.w.Header())
+		}
+		out.Write(m.w.Page(p))
+	}
+}
+
+// chain 依次读取各段录音, 在读到时才打开文件
+type chain struct {
+	store blob.Store
+	recs  []*Recording
+	// skip 每段开头跳过的字节数
+	skip int64
+	// ogg 重新封装ogg, 不是ogg时为nil
+	ogg *remux
+	// out 待读出的数据
+	out bytes.Buffer
+	buf []byte
+	cur io.ReadCloser
+}
+
+func (c *chain) Read(p []byte) (int, error) {
+	for {
+		if c.out.Len() > 0 {
+			return c.out.Read(p)
+		}
+		if c.cur == nil {
+			if len(c.recs) == 0 {
+				return 0, io.EOF
+			}
+			// 响应在请求处理结束后才写出, 不使用请求的ctx
+			rc, _, err := c.store.Get(context.Background(), c.recs[0].Key)
+			if err != nil {
+				return 0, err
+			}
+			c.recs = c.recs[1:]
+			if _, err = io.CopyN(io.Discard, rc, c.skip); err != nil {
+				_ = rc.Close()
+				return 0, err
+			}
+			c.cur = rc
+			if c.ogg != nil {
+				c.ogg.next()
+			}
+		}
+		if c.ogg != nil {
+			if c.buf == nil {
+				c.buf = make([]byte, 32*1024)
+			}
+			n, err := c.cur.Read(c.buf)
+			c.ogg.write(&c.out, c.buf[:n])
+			if err == io.EOF {
+				_ = c.cur.Close()
+				c.cur = nil
+			} else if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		n, err := c.cur.Read(p)
+		if err == io.EOF {
+			_ = c.cur.Close()
+			c.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *chain) Close() error {
+	if c.cur == nil {
+		return nil
+	}
+	err := c.cur.Close()
+	c.cur = nil
+	return err
+}
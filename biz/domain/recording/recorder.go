@@ -0,0 +1,93 @@
+package recording
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/domain/audio"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/blob"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/mapper/recording"
+)
+
+// 会话录音, 老人与回复的音频分别按轮次保存, 用于家属对对话内容有疑问时回听
+
+// 说话的一方
+const (
+	SideUser = "user"
+	SideAi   = "ai"
+)
+
+// 录音的格式, pcm保存为wav, ogg封装的opus原样保存
+const (
+	FormatWav = "wav"
+	FormatOgg = "ogg"
+)
+
+const defaultMaxSeconds = 600
+
+// ErrNotFound 没有录音
+var ErrNotFound = errors.New("recording not found")
+
+// Recording 一段录音
+type Recording = recording.Recording
+
+// Format 录音的音频格式, Ogg为false时为pcm16
+type Format struct {
+	Ogg        bool
+	SampleRate int
+	Channels   int
+}
+
+// Recorder 录音的保存与读取
+type Recorder struct {
+	enable bool
+	// maxDuration 单轮录音的最长时长
+	maxDuration time.Duration
+	store       blob.Store
+	mapper      recording.IMongoMapper
+}
+
+var (
+	recorder *Recorder
+	once     sync.Once
+)
+
+func GetRecorder() *Recorder {
+	once.Do(func() {
+		recorder = NewRecorder(config.GetConfig().Recording, blob.GetStore(), recording.GetMongoMapper())
+	})
+	return recorder
+}
+
+func NewRecorder(c config.Recording, store blob.Store, mapper recording.IMongoMapper) *Recorder {
+	seconds := c.MaxSeconds
+	if seconds <= 0 {
+		seconds = defaultMaxSeconds
+	}
+	return &Recorder{enable: c.Enable, maxDuration: time.Duration(seconds) * time.Second, store: store, mapper: mapper}
+}
+
+// Allowed 开启录音且老人同意时才录音
+func (r *Recorder) Allowed(consent bool) bool {
+	return r != nil && r.enable && consent
+}
+
+// NewTrack 开始录制一方的音频
+func (r *Recorder) NewTrack(side, seniorId string, f Format) *Track {
+	if f.Channels == 0 {
+		f.Channels = 1
+	}
+	t := &Track{r: r, side: side, seniorId: seniorId, format: f}
+	if f.Ogg {
+		t.ogg = audio.NewOggReader()
+	}
+	return t
+}
+
+// List 按时间顺序列出一次会话的录音, side为空时包含双方, turn小于0时包含所有轮次
+func (r *Recorder) List(ctx context.Context, sessionId, side string, turn int64) ([]*Recording, error) {
+	return r.mapper.FindMany(ctx, sessionId, side, turn)
+}
@@ -0,0 +1,167 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/xh-polaris/psych-senior/biz/domain/audio"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/blob"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"golang.org/x/net/context"
+)
+
+type memMapper struct {
+	mu   sync.Mutex
+	recs []*Recording
+}
+
+func (m *memMapper) Insert(_ context.Context, r *Recording) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recs = append(m.recs, r)
+	return nil
+}
+
+func (m *memMapper) FindMany(_ context.Context, sessionId, side string, turn int64) ([]*Recording, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*Recording
+	for _, r := range m.recs {
+		if r.SessionId == sessionId && (side == "" || r.Side == side) && (turn < 0 || r.Turn == turn) {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+func TestTrack(t *testing.T) {
+	m := &memMapper{}
+	r := NewRecorder(config.Recording{Enable: true}, blob.New(config.Blob{Dir: t.TempDir()}), m)
+	if !r.Allowed(true) || r.Allowed(false) {
+		t.Fatal("unexpected consent result")
+	}
+
+	track := r.NewTrack(SideAi, "senior", Format{SampleRate: 16000})
+	track.Write(1, []byte{1, 2, 3, 4})
+	track.Write(1, []byte{5, 6})
+	track.Write(2, []byte{7, 8})
+	// 会话id确定之前不保存
+	if len(m.recs) != 0 {
+		t.Fatal("saved before session is known")
+	}
+	track.SetSession("s1")
+	track.Close()
+	if len(m.recs) != 2 {
+		t.Fatalf("got %d recordings", len(m.recs))
+	}
+
+	p, err := r.Open(context.Background(), "s1", SideAi, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(p)
+	_ = p.Close()
+	if int64(len(data)) != p.Size || p.ContentType != "audio/wav" {
+		t.Fatalf("size %d, read %d, type %s", p.Size, len(data), p.ContentType)
+	}
+	if got := binary.LittleEndian.Uint32(data[40:44]); got != 8 {
+		t.Errorf("data size = %d", got)
+	}
+	if !bytes.Equal(data[44:], []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Errorf("pcm = %v", data[44:])
+	}
+
+	p, err = r.Open(context.Background(), "s1", SideAi, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(p)
+	if !bytes.Equal(data[44:], []byte{7, 8}) {
+		t.Errorf("turn 2 pcm = %v", data[44:])
+	}
+	if _, err = r.Open(context.Background(), "s1", SideUser, -1); err != ErrNotFound {
+		t.Errorf("open user err = %v", err)
+	}
+}
+
+func TestTrackOgg(t *testing.T) {
+	m := &memMapper{}
+	r := NewRecorder(config.Recording{Enable: true}, blob.New(config.Blob{Dir: t.TempDir()}), m)
+	track := r.NewTrack(SideAi, "senior", Format{Ogg: true})
+	track.SetSession("s1")
+	// 每轮两句合成, 每句都是带头部的单独的流
+	var packets [][]byte
+	for turn := int64(1); turn <= 2; turn++ {
+		for i := 0; i < 2; i++ {
+			w := audio.NewOggWriter(uint32(turn*10)+uint32(i), 2, 24000)
+			p := []byte{0xf8, byte(turn), byte(i)}
+			packets = append(packets, p)
+			track.Write(turn, append(w.Header(), w.Page(p)...))
+		}
+	}
+	track.Close()
+	if len(m.recs) != 2 {
+		t.Fatalf("got %d recordings", len(m.recs))
+	}
+
+	p, err := r.Open(context.Background(), "s1", SideAi, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(p)
+	_ = p.Close()
+	if p.Size != -1 || p.ContentType != "audio/ogg" {
+		t.Fatalf("size %d, type %s", p.Size, p.ContentType)
+	}
+	// 只有一个头部, 所有页的serial相同, 页序号连续
+	var pages int
+	serial := binary.LittleEndian.Uint32(data[14:18])
+	for rest := data; len(rest) > 0; pages++ {
+		if string(rest[:4]) != "OggS" {
+			t.Fatalf("page %d capture = %q", pages, rest[:4])
+		}
+		if bos := rest[5]&0x02 != 0; bos != (pages == 0) {
+			t.Errorf("page %d bos = %v", pages, bos)
+		}
+		if got := binary.LittleEndian.Uint32(rest[14:18]); got != serial {
+			t.Errorf("page %d serial = %d", pages, got)
+		}
+		if got := binary.LittleEndian.Uint32(rest[18:22]); got != uint32(pages) {
+			t.Errorf("page %d seq = %d", pages, got)
+		}
+		size := 27 + int(rest[26])
+		for _, l := range rest[27:size] {
+			size += int(l)
+		}
+		rest = rest[size:]
+	}
+	if pages != 2+len(packets) {
+		t.Errorf("got %d pages", pages)
+	}
+
+	reader := audio.NewOggReader()
+	got, err := reader.Write(data)
+	if err != nil || len(got) != len(packets) {
+		t.Fatalf("got %d packets, err %v", len(got), err)
+	}
+	for i := range packets {
+		if !bytes.Equal(got[i], packets[i]) {
+			t.Errorf("packet %d = %v", i, got[i])
+		}
+	}
+	if channels, rate := reader.Head(); channels != 2 || rate != 24000 {
+		t.Errorf("head = %d %d", channels, rate)
+	}
+}
+
+func TestTrackNil(t *testing.T) {
+	var track *Track
+	track.Write(1, []byte{1})
+	track.SetSession("s")
+	track.Close()
+}
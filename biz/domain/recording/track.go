@@ -0,0 +1,194 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/domain/audio"
+)
+
+// saveTimeout 保存一段录音的超时时间
+const saveTimeout = time.Minute
+
+// Track 一方的录音, 按轮次分段保存, 为nil时不录音
+// 会话id确定之前的录音暂存在内存中, 确定后统一保存
+type Track struct {
+	r        *Recorder
+	side     string
+	seniorId string
+	format   Format
+
+	mu        sync.Mutex
+	sessionId string
+	// ogg 解析写入的ogg, 数据包按段重新封装, 使每段都是单独可以播放的一个流
+	ogg     *audio.OggReader
+	cur     *segment
+	pending []*segment
+	wg      sync.WaitGroup
+}
+
+// segment 一轮的录音
+type segment struct {
+	turn  int64
+	start time.Time
+	clock *audio.Clock
+	// ogg 本段的ogg封装, 不是ogg时为nil
+	ogg *audio.OggWriter
+	buf bytes.Buffer
+}
+
+// SetHeader 写入单独发送的ogg头部页, 用于确定声道数与采样率
+func (t *Track) SetHeader(header []byte) {
+	if t == nil || t.ogg == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.ogg.Write(header); err != nil {
+		log.Error("parse recording header err:", err)
+	}
+}
+
+// SetSession 设置录音所属的会话, 保存之前暂存的录音
+func (t *Track) SetSession(sessionId string) {
+	if t == nil || sessionId == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sessionId = sessionId
+	t.save()
+}
+
+// Write 写入一块音频, 轮次变化时保存上一轮的录音, 超过最长时长的部分丢弃
+func (t *Track) Write(turn int64, data []byte) {
+	if t == nil || len(data) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cur != nil && t.cur.turn != turn {
+		t.cut()
+	}
+	if t.ogg == nil {
+		t.segment(turn).write(data, t.r.maxDuration)
+		return
+	}
+	// 合成的音频每句都是带头部的单独的流, 只取出数据包, 由本段的封装重新编号
+	packets, err := t.ogg.Write(data)
+	if err != nil {
+		log.Error("parse recording ogg err:", err)
+	}
+	for _, p := range packets {
+		if len(p) > audio.MaxOggPacket {
+			continue
+		}
+		seg := t.segment(turn)
+		seg.write(seg.ogg.Page(p), t.r.maxDuration)
+	}
+}
+
+// Close 保存剩余的录音并等待保存完成, 会话id仍未确定时丢弃
+func (t *Track) Close() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.cut()
+	if t.sessionId == "" && len(t.pending) > 0 {
+		log.Error("drop recording without session, side:", t.side)
+		t.pending = nil
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+}
+
+// segment 当前一轮的录音, 没有时创建, ogg以头部页开头, 需持有mu
+func (t *Track) segment(turn int64) *segment {
+	if t.cur != nil {
+		return t.cur
+	}
+	start := time.Now()
+	t.cur = &segment{turn: turn, start: start}
+	if t.ogg == nil {
+		t.cur.clock = audio.NewPCMClock(t.format.SampleRate, t.format.Channels)
+		return t.cur
+	}
+	channels, rate := t.ogg.Head()
+	t.cur.clock = audio.NewOggClock()
+	t.cur.ogg = audio.NewOggWriter(uint32(start.UnixNano()), channels, rate)
+	t.cur.buf.Write(t.cur.ogg.Header())
+	return t.cur
+}
+
+// write 写入一块音频, 超过最长时长的部分丢弃
+func (s *segment) write(data []byte, limit time.Duration) {
+	if s.clock.Position() >= limit {
+		return
+	}
+	s.clock.Advance(data)
+	s.buf.Write(data)
+}
+
+// cut 结束当前一轮的录音, 需持有mu
+func (t *Track) cut() {
+	if t.cur != nil {
+		t.pending = append(t.pending, t.cur)
+		t.cur = nil
+	}
+	t.save()
+}
+
+// save 在后台保存暂存的录音, 需持有mu
+func (t *Track) save() {
+	if t.sessionId == "" {
+		return
+	}
+	for _, seg := range t.pending {
+		rec, data := t.encode(seg)
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+			defer cancel()
+			if err := t.r.save(ctx, rec, data); err != nil {
+				log.Error("save recording err:", err)
+			}
+		}()
+	}
+	t.pending = nil
+}
+
+// encode 生成完整的音频文件与录音记录
+func (t *Track) encode(seg *segment) (*Recording, []byte) {
+	rec := &Recording{
+		SessionId: t.sessionId,
+		SeniorId:  t.seniorId,
+		Side:      t.side,
+		Turn:      seg.turn,
+		Start:     seg.start,
+		End:       seg.start.Add(seg.clock.Position()),
+	}
+	var data []byte
+	if t.format.Ogg {
+		rec.Format, data = FormatOgg, seg.buf.Bytes()
+	} else {
+		rec.Format, rec.SampleRate, rec.Channels = FormatWav, t.format.SampleRate, t.format.Channels
+		data = append(audio.WavHeader(t.format.SampleRate, t.format.Channels, uint32(seg.buf.Len())), seg.buf.Bytes()...)
+	}
+	rec.Size = int64(len(data))
+	rec.Key = fmt.Sprintf("recordings/%s/%s/%d-%d.%s", t.sessionId, t.side, seg.turn, seg.start.UnixMilli(), rec.Format)
+	return rec, data
+}
+
+// save 保存音频文件后写入录音记录
+func (r *Recorder) save(ctx context.Context, rec *Recording, data []byte) error {
+	if err := r.store.Put(ctx, rec.Key, data); err != nil {
+		return err
+	}
+	return r.mapper.Insert(ctx, rec)
+}
@@ -1,12 +1,16 @@
 package domain
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/xh-polaris/psych-senior/biz/application/dto"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
 	rs "github.com/xh-polaris/psych-senior/biz/infrastructure/redis"
 	"github.com/zeromicro/go-zero/core/stores/redis"
 	"sync"
+	"time"
 )
 
 var (
@@ -29,25 +33,29 @@ func GetRedisHelper() *RedisHelper {
 }
 
 // AddAi 添加ai对话记录
-func (r *RedisHelper) AddAi(sessionId, msg string) error {
-	return r.add(sessionId, "ai", msg)
+func (r *RedisHelper) AddAi(ctx context.Context, sessionId string, his *dto.ChatHistory) error {
+	his.Role = consts.RoleAi
+	return r.add(ctx, sessionId, his)
 }
 
 // AddUser 添加用户对话记录
-func (r *RedisHelper) AddUser(sessionId, msg string) error {
-	return r.add(sessionId, "user", msg)
+func (r *RedisHelper) AddUser(ctx context.Context, sessionId string, his *dto.ChatHistory) error {
+	his.Role = consts.RoleUser
+	return r.add(ctx, sessionId, his)
 }
 
 // AddSystem 添加系统对话记录
-func (r *RedisHelper) AddSystem(sessionId, msg string) error {
-	return r.add(sessionId, "system", msg)
+func (r *RedisHelper) AddSystem(ctx context.Context, sessionId, msg string) error {
+	return r.add(ctx, sessionId, &dto.ChatHistory{
+		Role:    consts.RoleSystem,
+		Content: msg,
+	})
 }
 
 // add 将对话记录添加到队列尾部
-func (r *RedisHelper) add(sessionId, role, msg string) error {
-	history := dto.ChatHistory{
-		Role:    role,
-		Content: msg,
+func (r *RedisHelper) add(ctx context.Context, sessionId string, history *dto.ChatHistory) error {
+	if history.Timestamp == 0 {
+		history.Timestamp = time.Now().UnixMilli()
 	}
 
 	data, err := json.Marshal(history)
@@ -55,14 +63,23 @@ func (r *RedisHelper) add(sessionId, role, msg string) error {
 		return err
 	}
 
-	_, err = r.rs.Rpush(sessionId, string(data))
+	start := time.Now()
+	_, err = r.rs.RpushCtx(ctx, sessionId, string(data))
+	metrics.RedisOp.Observe(metrics.Since(start), "rpush", metrics.Result(err))
 	return err
 }
 
+// Ping 检查redis连接
+func (r *RedisHelper) Ping(ctx context.Context) bool {
+	return r.rs.PingCtx(ctx)
+}
+
 // Load 获取session对应的所有对话记录
-func (r *RedisHelper) Load(sessionId string) ([]*dto.ChatHistory, error) {
+func (r *RedisHelper) Load(ctx context.Context, sessionId string) ([]*dto.ChatHistory, error) {
 	// 获取所有元素
-	data, err := r.rs.Lrange(sessionId, 0, -1)
+	start := time.Now()
+	data, err := r.rs.LrangeCtx(ctx, sessionId, 0, -1)
+	metrics.RedisOp.Observe(metrics.Since(start), "lrange", metrics.Result(err))
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +96,10 @@ func (r *RedisHelper) Load(sessionId string) ([]*dto.ChatHistory, error) {
 }
 
 // Remove 删除Session对应的记录
-func (r *RedisHelper) Remove(sessionId string) error {
-	_, err := r.rs.Del(sessionId)
+func (r *RedisHelper) Remove(ctx context.Context, sessionId string) error {
+	start := time.Now()
+	_, err := r.rs.DelCtx(ctx, sessionId)
+	metrics.RedisOp.Observe(metrics.Since(start), "del", metrics.Result(err))
 	return err
 }
 
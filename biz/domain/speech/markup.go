@@ -0,0 +1,54 @@
+package speech
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	fenceRe    = regexp.MustCompile("```[A-Za-z0-9_+-]*")
+	linkRe     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	urlRe      = regexp.MustCompile(`(?:https?://|www\.)[A-Za-z0-9\-._~:/?#\[\]@!$&'()*+,;=%]+`)
+	ruleRe     = regexp.MustCompile(`(?m)^\s*[-*_]{3,}\s*$`)
+	headingRe  = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s*`)
+	quoteRe    = regexp.MustCompile(`(?m)^\s*>\s?`)
+	bulletRe   = regexp.MustCompile(`(?m)^\s*[-*+•]\s+`)
+	numberedRe = regexp.MustCompile(`(?m)^\s*(\d{1,2})(?:[.)]\s+|、)`)
+	emphasisRe = regexp.MustCompile(`\*{1,3}|_{2,3}|~~|` + "`")
+)
+
+// StripMarkdown 去除markdown标记, 链接保留文字, 网址不朗读, 编号列表读作"第几"
+func StripMarkdown(text string) string {
+	text = fenceRe.ReplaceAllString(text, "")
+	text = linkRe.ReplaceAllString(text, "$1")
+	text = urlRe.ReplaceAllString(text, "")
+	text = ruleRe.ReplaceAllString(text, "")
+	text = headingRe.ReplaceAllString(text, "")
+	text = quoteRe.ReplaceAllString(text, "")
+	text = bulletRe.ReplaceAllString(text, "")
+	text = numberedRe.ReplaceAllString(text, "第$1，")
+	text = emphasisRe.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+// StripEmoji 去除emoji及其修饰符
+func StripEmoji(text string) string {
+	return strings.Map(func(r rune) rune {
+		if isEmoji(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // 表情, 符号, 国旗等
+		r >= 0x2600 && r <= 0x27BF, // 杂项符号与装饰符号
+		r >= 0x2B00 && r <= 0x2BFF, // 箭头与星形
+		r >= 0xFE00 && r <= 0xFE0F, // 变体选择符
+		r == 0x200D, r == 0x20E3:   // 零宽连接符与键帽
+		return true
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package speech
+
+import "sync"
+
+// 语音合成前的文本规范化, 只作用于送去合成的文本, 前端展示的仍是原文
+
+// Rule 一条规范化规则, 输入输出均为一句文本
+type Rule func(text string) string
+
+// Normalizer 按顺序执行一组规则
+type Normalizer struct {
+	rules []Rule
+}
+
+func NewNormalizer(rules ...Rule) *Normalizer {
+	return &Normalizer{rules: rules}
+}
+
+// Normalize 规范化一句文本
+func (n *Normalizer) Normalize(text string) string {
+	if n == nil {
+		return text
+	}
+	for _, rule := range n.rules {
+		text = rule(text)
+	}
+	return text
+}
+
+var (
+	mu sync.RWMutex
+	// normalizers 各语言的规范化规则, 未注册的语言使用普通话的规则
+	normalizers = map[string]*Normalizer{
+		"zh":          NewNormalizer(StripMarkdown, StripEmoji, Numbers(Mandarin)),
+		"zh-shanghai": NewNormalizer(StripMarkdown, StripEmoji, Numbers(Shanghai)),
+	}
+)
+
+// Register 注册或替换一种语言的规范化规则
+func Register(lang string, rules ...Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	normalizers[lang] = NewNormalizer(rules...)
+}
+
+// GetNormalizer 获取一种语言的规范化规则
+func GetNormalizer(lang string) *Normalizer {
+	mu.RLock()
+	defer mu.RUnlock()
+	if n, ok := normalizers[lang]; ok {
+		return n
+	}
+	return normalizers["zh"]
+}
@@ -0,0 +1,29 @@
+package speech
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		lang, in, want string
+	}{
+		{"zh", "**记得**按时吃药😊", "记得按时吃药"},
+		{"zh", "1. 每天早上吃0.5mg", "第一，每天早上吃零点五毫克"},
+		{"zh", "- 详情见[说明](https://a.com/x)", "详情见说明"},
+		{"zh", "复诊时间是2025-03-01 14:05", "复诊时间是二零二五年三月一日 十四点零五分"},
+		{"zh", "血压130/85mmHg, 体温36.5℃", "血压一百三十/八十五毫米汞柱, 体温三十六点五摄氏度"},
+		{"zh", "有效率达到95%，每天2次，每次1-2片", "有效率达到百分之九十五，每天两次，每次一到两片"},
+		{"zh", "电话是13800138000，共10086元", "电话是一三八零零一三八零零零，共一万零八十六元"},
+		{"zh", "体温36.5-37度", "体温三十六点五到三十七度"},
+		{"zh", "拨打021-1234", "拨打零二一-一二三四"},
+		{"zh", "号码1234567-89", "号码一二三四五六七-八九"},
+		{"zh", "座机87654321", "座机八七六五四三二一"},
+		{"zh", "2025-03的复诊", "二零二五年三月的复诊"},
+		{"zh-shanghai", "3月25日2:00见", "三月廿五日两点见"},
+		{"zh-shanghai", "2025/3/25", "二零二五年三月廿五号"},
+	}
+	for _, c := range cases {
+		if got := GetNormalizer(c.lang).Normalize(c.in); got != c.want {
+			t.Errorf("Normalize(%q, %q) = %q, want %q", c.lang, c.in, got, c.want)
+		}
+	}
+}
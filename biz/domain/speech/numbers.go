@@ -0,0 +1,264 @@
+package speech
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Style 数字的读法, 不同方言的日期与数字读法不同
+type Style struct {
+	// Day 日期中日的读法
+	Day string
+	// Twenty 二十至二十九是否读作廿
+	Twenty bool
+}
+
+var (
+	// Mandarin 普通话读法
+	Mandarin = Style{Day: "日"}
+	// Shanghai 沪语读法, 如 三月廿五号
+	Shanghai = Style{Day: "号", Twenty: true}
+)
+
+const num = `(\d+(?:\.\d+)?)`
+
+var (
+	dateRe    = regexp.MustCompile(`(\d{4})[-/.年](\d{1,2})[-/.月](\d{1,2})日?`)
+	yearRe    = regexp.MustCompile(`(\d{4})年`)
+	timeRe    = regexp.MustCompile(`(\d{1,2})[:：](\d{2})(?:[:：](\d{2}))?`)
+	percentRe = regexp.MustCompile(num + `(?:\s*[-~～]\s*` + num + `)?\s*[%％]`)
+	unitRe    = regexp.MustCompile(num + `(?:\s*[-~～]\s*` + num + `)?\s*(?:(mmol/L|mmHg|kcal|mg|kg|ml|mL|km|cm|mm|g|L|m)\b|(℃|°C))`)
+	rangeRe   = regexp.MustCompile(num + `\s*[-~～]\s*` + num)
+	numberRe  = regexp.MustCompile(num)
+)
+
+// units 单位的读法
+var units = map[string]string{
+	"mmol/L": "毫摩尔每升",
+	"mmHg":   "毫米汞柱",
+	"kcal":   "千卡",
+	"mg":     "毫克",
+	"kg":     "公斤",
+	"ml":     "毫升",
+	"mL":     "毫升",
+	"km":     "公里",
+	"cm":     "厘米",
+	"mm":     "毫米",
+	"g":      "克",
+	"L":      "升",
+	"m":      "米",
+	"℃":      "摄氏度",
+	"°C":     "摄氏度",
+}
+
+// measures 数字二在这些量词前读作两
+const measures = "个次片粒颗天位只件本杯碗瓶盒年周岁分秒斤种点层"
+
+var (
+	digits    = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+	places    = []string{"千", "百", "十", ""}
+	bigPlaces = []string{"", "万", "亿"}
+)
+
+// Numbers 将日期, 时间, 百分比, 带单位的数值与其他数字转换为中文读法
+func Numbers(style Style) Rule {
+	return func(text string) string {
+		text = dateRe.ReplaceAllStringFunc(text, func(s string) string {
+			m := dateRe.FindStringSubmatch(s)
+			return readDigits(m[1]) + "年" + style.value(m[2]) + "月" + style.value(m[3]) + style.Day
+		})
+		text = yearRe.ReplaceAllStringFunc(text, func(s string) string {
+			return readDigits(yearRe.FindStringSubmatch(s)[1]) + "年"
+		})
+		text = timeRe.ReplaceAllStringFunc(text, func(s string) string {
+			m := timeRe.FindStringSubmatch(s)
+			out := style.count(style.trim(m[1])) + "点"
+			if minute, _ := strconv.Atoi(m[2]); minute > 0 {
+				if minute < 10 {
+					out += "零"
+				}
+				out += style.read(strconv.Itoa(minute)) + "分"
+			}
+			if second, _ := strconv.Atoi(m[3]); second > 0 {
+				out += style.read(strconv.Itoa(second)) + "秒"
+			}
+			return out
+		})
+		text = percentRe.ReplaceAllStringFunc(text, func(s string) string {
+			m := percentRe.FindStringSubmatch(s)
+			if m[2] != "" {
+				return "百分之" + style.read(m[1]) + "到百分之" + style.read(m[2])
+			}
+			return "百分之" + style.read(m[1])
+		})
+		text = unitRe.ReplaceAllStringFunc(text, func(s string) string {
+			m := unitRe.FindStringSubmatch(s)
+			unit := units[m[3]+m[4]]
+			if m[2] != "" {
+				return style.count(m[1]) + "到" + style.count(m[2]) + unit
+			}
+			return style.count(m[1]) + unit
+		})
+		text = rangeRe.ReplaceAllStringFunc(text, func(s string) string {
+			m := rangeRe.FindStringSubmatch(s)
+			if yearMonth(m[1], m[2]) {
+				return readDigits(m[1]) + "年" + style.value(m[2]) + "月"
+			}
+			// 带区号的电话等编号, 各段均逐位读出
+			if serial(integer(m[1])) || serial(integer(m[2])) {
+				return numberRe.ReplaceAllStringFunc(s, readDigits)
+			}
+			return style.read(m[1]) + "到" + m[2]
+		})
+		return replaceNumbers(text, style)
+	}
+}
+
+// yearMonth 是否为年月, 如2025-03
+func yearMonth(year, month string) bool {
+	if len(year) != 4 || len(month) > 2 || strings.ContainsRune(month, '.') || (year[:2] != "19" && year[:2] != "20") {
+		return false
+	}
+	m, _ := strconv.Atoi(month)
+	return m >= 1 && m <= 12
+}
+
+// integer 返回数字的整数部分
+func integer(s string) string {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// serialLen 达到该位数的整数视为编号, 如7到8位的座机号码与11位的手机号码
+const serialLen = 7
+
+// serial 整数部分是否为编号而非数值, 如前导零或位数过多的电话号码, 编号逐位读出
+func serial(s string) bool {
+	return len(s) >= serialLen || (len(s) > 1 && s[0] == '0')
+}
+
+// replaceNumbers 转换剩余的数字, 二在量词前读作两
+func replaceNumbers(text string, style Style) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range numberRe.FindAllStringIndex(text, -1) {
+		sb.WriteString(text[last:loc[0]])
+		s := text[loc[0]:loc[1]]
+		if next := text[loc[1]:]; next != "" && strings.Contains(measures, string([]rune(next)[0])) {
+			sb.WriteString(style.count(s))
+		} else {
+			sb.WriteString(style.read(s))
+		}
+		last = loc[1]
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// trim 去掉日期与时间中补位的0
+func (style Style) trim(s string) string {
+	if t := strings.TrimLeft(s, "0"); t != "" {
+		return t
+	}
+	return "0"
+}
+
+// value 读出日期与时间中的数值
+func (style Style) value(s string) string {
+	return style.read(style.trim(s))
+}
+
+// count 读作数量, 二读作两
+func (style Style) count(s string) string {
+	if n, err := strconv.Atoi(s); err == nil && n == 2 {
+		return "两"
+	}
+	return style.read(s)
+}
+
+// read 读出一个整数或小数, 编号逐位读出
+func (style Style) read(s string) string {
+	integer, fraction, decimal := strings.Cut(s, ".")
+	var out string
+	if serial(integer) {
+		out = readDigits(integer)
+	} else {
+		n, _ := strconv.ParseInt(integer, 10, 64)
+		if style.Twenty && n >= 20 && n <= 29 {
+			out = "廿" + strings.TrimPrefix(readInt(n%10), "零")
+		} else {
+			out = readInt(n)
+		}
+	}
+	if decimal {
+		out += "点" + readDigits(fraction)
+	}
+	return out
+}
+
+// readDigits 逐位读出
+func readDigits(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			sb.WriteString(digits[c-'0'])
+		}
+	}
+	return sb.String()
+}
+
+// readInt 按位值读出一个整数, 如 10086 读作 一万零八十六
+func readInt(n int64) string {
+	if n == 0 {
+		return "零"
+	}
+	var groups []int64
+	for ; n > 0; n /= 10000 {
+		groups = append(groups, n%10000)
+	}
+	var sb strings.Builder
+	zero := false
+	for i := len(groups) - 1; i >= 0; i-- {
+		g := groups[i]
+		if g == 0 {
+			zero = true
+			continue
+		}
+		if sb.Len() > 0 && (zero || g < 1000) {
+			sb.WriteString("零")
+		}
+		sb.WriteString(readGroup(g))
+		sb.WriteString(bigPlaces[i])
+		zero = false
+	}
+	// 十至十九读作 十X 而不是 一十X
+	out := sb.String()
+	if strings.HasPrefix(out, "一十") {
+		out = strings.TrimPrefix(out, "一")
+	}
+	return out
+}
+
+// readGroup 读出0至9999
+func readGroup(g int64) string {
+	var sb strings.Builder
+	started, zero := false, false
+	for i, div := 0, int64(1000); i < 4; i, div = i+1, div/10 {
+		d := g / div % 10
+		if d == 0 {
+			zero = started
+			continue
+		}
+		if zero {
+			sb.WriteString("零")
+			zero = false
+		}
+		sb.WriteString(digits[d])
+		sb.WriteString(places[i])
+		started = true
+	}
+	return sb.String()
+}
@@ -0,0 +1,141 @@
+package speech
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 流式文本的分句, 将大模型增量输出的片段拼接为适合合成的句子
+// 句末标点处切分, 过短的句子与下一句合并, 过长时在逗号等弱标点处切分
+
+// 未配置时的默认长度, 单位为字符数
+const (
+	DefaultMinChars = 6
+	DefaultMaxChars = 50
+)
+
+// isStrong 句末标点
+func isStrong(r rune) bool {
+	return strings.ContainsRune("。！？；…!?;\n", r)
+}
+
+// isWeak 句中停顿的标点
+func isWeak(r rune) bool {
+	return strings.ContainsRune("，、：,:—", r)
+}
+
+// isClosing 句末标点之后的引号与括号, 需要与句子一起切分
+func isClosing(r rune) bool {
+	return strings.ContainsRune("”’」』）)]\"'", r)
+}
+
+// Segmenter 流式分句器, 非并发安全
+type Segmenter struct {
+	min, max int
+	buf      []rune
+	// first 是否为本轮的第一句, 第一句在弱标点处即可切分, 以降低首音频耗时
+	first bool
+}
+
+func NewSegmenter(min, max int) *Segmenter {
+	if min <= 0 {
+		min = DefaultMinChars
+	}
+	if max <= 0 {
+		max = DefaultMaxChars
+	}
+	if max < min {
+		max = min
+	}
+	return &Segmenter{min: min, max: max, first: true}
+}
+
+// Write 写入一段文本, 返回已经可以合成的句子
+func (s *Segmenter) Write(text string) []string {
+	s.buf = append(s.buf, []rune(text)...)
+	var out []string
+	for {
+		chunk, ok := s.next()
+		if !ok {
+			return out
+		}
+		if chunk != "" {
+			out = append(out, chunk)
+		}
+	}
+}
+
+// Flush 返回缓冲中剩余的文本, 并开始新的一轮
+func (s *Segmenter) Flush() string {
+	chunk := strings.TrimSpace(string(s.buf))
+	s.buf = s.buf[:0]
+	s.first = true
+	return chunk
+}
+
+// Len 缓冲中的字符数
+func (s *Segmenter) Len() int {
+	return len(s.buf)
+}
+
+// next 切分出下一句, 没有可以切分的句子时返回false
+func (s *Segmenter) next() (string, bool) {
+	n := len(s.buf)
+	for i := 0; i < n; i++ {
+		r := s.buf[i]
+		switch {
+		case isStrong(r):
+		case r == '.':
+			// 英文句号需要后接空白, 以区分小数与缩写, 不确定时等待后续文本
+			if i+1 >= n || !unicode.IsSpace(s.buf[i+1]) {
+				continue
+			}
+		case s.first && isWeak(r):
+		default:
+			continue
+		}
+		end := s.extend(i)
+		// 过短的句子与下一句合并
+		if end+1 < s.min {
+			continue
+		}
+		return s.cut(end + 1), true
+	}
+	if n > s.max {
+		// 超过最大长度, 优先在弱标点处切分, 其次在空白处, 否则直接切分
+		cut := lastIndex(s.buf[:s.max], isWeak)
+		if cut < s.min {
+			cut = lastIndex(s.buf[:s.max], unicode.IsSpace)
+		}
+		if cut < s.min {
+			cut = s.max
+		}
+		return s.cut(cut), true
+	}
+	return "", false
+}
+
+// extend 将连续的标点与后引号并入句子
+func (s *Segmenter) extend(i int) int {
+	for i+1 < len(s.buf) && (isStrong(s.buf[i+1]) || isClosing(s.buf[i+1]) || s.buf[i+1] == '.') {
+		i++
+	}
+	return i
+}
+
+func (s *Segmenter) cut(k int) string {
+	chunk := strings.TrimSpace(string(s.buf[:k]))
+	s.buf = append(s.buf[:0], s.buf[k:]...)
+	s.first = false
+	return chunk
+}
+
+// lastIndex 返回最后一个满足f的字符之后的位置, 不存在时返回0
+func lastIndex(rs []rune, f func(rune) bool) int {
+	for i := len(rs) - 1; i >= 0; i-- {
+		if f(rs[i]) {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,26 @@
+package speech
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSegmenter(t *testing.T) {
+	s := NewSegmenter(3, 20)
+	var out []string
+	for _, piece := range []string{"您好，", "今天", "天气不错。好", "的！", "药要吃0.", "5mg, 记住了吗？", "Take it easy. ", "OK"} {
+		out = append(out, s.Write(piece)...)
+	}
+	out = append(out, s.Flush())
+	want := []string{"您好，", "今天天气不错。", "好的！", "药要吃0.5mg, 记住了吗？", "Take it easy.", "OK"}
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+
+	// 超过最大长度时在弱标点处切分
+	out = s.Write(strings.Repeat("很", 15) + "，" + strings.Repeat("长", 10))
+	if len(out) != 1 || out[0] != strings.Repeat("很", 15)+"，" {
+		t.Fatalf("got %q", out)
+	}
+}
@@ -0,0 +1,217 @@
+package speech
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 语音识别结果的后处理, 规范化后的文本发送给大模型与用于生成报告, 原文另外保存
+
+var (
+	// fillerRe 语气填充词, 连同之后的停顿一起去除
+	fillerRe = regexp.MustCompile(`(?:嗯|呃|唔|呣)+[，,、…\s]*`)
+	// leadingFillerRe 句首的口头禅, 后面有停顿时去除
+	leadingFillerRe = regexp.MustCompile(`(^|[，,。？！?!；;])\s*(?:那个|这个|就是说|就是|然后呢|啊|哎|诶)[，,、\s]+`)
+	asciiPunct      = strings.NewReplacer(",", "，", "?", "？", "!", "！", ";", "；", ":", "：")
+	periodRe        = regexp.MustCompile(`(\p{Han})\.`)
+	pauseRunRe      = regexp.MustCompile(`([，、；])[，、；\s]+`)
+	stopRunRe       = regexp.MustCompile(`[，、；\s]*([。！？])[。！？，、；\s]*`)
+	leadingPunctRe  = regexp.MustCompile(`^[，、；：。！？\s]+`)
+	trailingPauseRe = regexp.MustCompile(`[，、；：\s]+$`)
+	// finalFaRe 句末的伐, 沪语的疑问语气词
+	finalFaRe = regexp.MustCompile(`(\p{Han})伐([，。？！,.?!；;\s]|$)`)
+)
+
+// faWords 以伐结尾的普通话词, 句末的伐不是语气词, 如步伐, 讨伐
+const faWords = "步征讨砍采杀挞"
+
+// stutterChars 重复两次即视为口吃的字, 其他的字重复两次多为叠词, 如谢谢, 慢慢
+const stutterChars = "我你他她它就这那"
+
+// lexicons 内置的方言词表, 方言写法到普通话, 与原文相同的项用于保护普通话中的词不被替换
+var lexicons = map[string]map[string]string{
+	"zh-shanghai": {
+		"阿拉":  "我们",
+		"阿拉伯": "阿拉伯",
+		"伊拉":  "他们",
+		"伊拉克": "伊拉克",
+		"侬":   "你",
+		"今朝":  "今天",
+		"辰光":  "时候",
+		"交关":  "非常",
+		"啥人":  "谁",
+		"哪能":  "怎么",
+		"勿晓得": "不知道",
+		"晓得":  "知道",
+		"覅":   "不要",
+		"欢喜":  "喜欢",
+		"困觉":  "睡觉",
+		"屋里厢": "家里",
+		"物事":  "东西",
+		"小囡":  "小孩",
+		"搿个":  "这个",
+		"好伐":  "好吗",
+		"对伐":  "对吗",
+		"是伐":  "是吗",
+	},
+}
+
+// Transcript 获取一种语言的识别结果规范化规则, lexicon为配置的方言词表, 与内置词表合并, 配置优先
+func Transcript(lang string, lexicon map[string]string) *Normalizer {
+	words := make(map[string]string)
+	for k, v := range lexicons[lang] {
+		words[k] = v
+	}
+	for k, v := range lexicon {
+		words[k] = v
+	}
+	rules := []Rule{RemoveFillers, RemoveStutters}
+	if len(words) > 0 {
+		rules = append(rules, Lexicon(words))
+	}
+	if lang == "zh-shanghai" {
+		rules = append(rules, FinalFa)
+	}
+	return NewNormalizer(append(rules, RepairPunctuation)...)
+}
+
+// RemoveFillers 去除语气填充词与句首的口头禅
+func RemoveFillers(text string) string {
+	text = fillerRe.ReplaceAllString(text, "")
+	// 连续的口头禅之间共用停顿, 一次替换只能去除其中一个
+	for {
+		next := leadingFillerRe.ReplaceAllString(text, "$1")
+		if next == text {
+			return text
+		}
+		text = next
+	}
+}
+
+// RemoveStutters 去除口吃造成的重复, 如 我我我想, 今天，今天去
+func RemoveStutters(text string) string {
+	r := []rune(text)
+	out := make([]rune, 0, len(r))
+	for i := 0; i < len(r); {
+		if n := repeatedWord(r, i); n > 0 {
+			i += n
+			continue
+		}
+		j := i
+		for j < len(r) && r[j] == r[i] {
+			j++
+		}
+		if k := j - i; unicode.Is(unicode.Han, r[i]) && (k >= 3 || k == 2 && strings.ContainsRune(stutterChars, r[i])) {
+			out = append(out, r[i])
+		} else {
+			out = append(out, r[i:j]...)
+		}
+		i = j
+	}
+	return string(out)
+}
+
+// repeatedWord 从i开始的词隔着停顿再次出现时, 返回第一次出现与停顿的长度
+func repeatedWord(r []rune, i int) int {
+	for n := 4; n >= 2; n-- {
+		if i+n > len(r) || !allHan(r[i:i+n]) {
+			continue
+		}
+		j := i + n
+		for j < len(r) && isPause(r[j]) {
+			j++
+		}
+		if j > i+n && j+n <= len(r) && string(r[i:i+n]) == string(r[j:j+n]) {
+			return j - i
+		}
+	}
+	return 0
+}
+
+func allHan(r []rune) bool {
+	for _, c := range r {
+		if !unicode.Is(unicode.Han, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPause(c rune) bool {
+	return c == '，' || c == ',' || c == '、' || c == '…' || unicode.IsSpace(c)
+}
+
+// Lexicon 按词表替换方言写法, 长的词优先
+func Lexicon(words map[string]string) Rule {
+	keys := make([]string, 0, len(words))
+	for k := range words {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if li, lj := len([]rune(keys[i])), len([]rune(keys[j])); li != lj {
+			return li > lj
+		}
+		return keys[i] < keys[j]
+	})
+	pairs := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k, words[k])
+	}
+	replacer := strings.NewReplacer(pairs...)
+	return replacer.Replace
+}
+
+// FinalFa 沪语句末的疑问语气词伐转为吗, 如 侬晓得伐 转为 你知道吗, 之后补全为问号
+func FinalFa(text string) string {
+	return finalFaRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := finalFaRe.FindStringSubmatch(s)
+		if strings.Contains(faWords, m[1]) {
+			return s
+		}
+		return m[1] + "吗" + m[2]
+	})
+}
+
+// RepairPunctuation 统一为中文标点, 合并重复的标点, 去除汉字间的空格, 并补全句末的标点
+func RepairPunctuation(text string) string {
+	text = asciiPunct.Replace(text)
+	text = periodRe.ReplaceAllString(text, "$1。")
+	text = joinHan(text)
+	text = pauseRunRe.ReplaceAllString(text, "$1")
+	text = stopRunRe.ReplaceAllString(text, "$1")
+	text = leadingPunctRe.ReplaceAllString(text, "")
+	text = trailingPauseRe.ReplaceAllString(text, "")
+	if text == "" {
+		return text
+	}
+	switch last := []rune(text)[len([]rune(text))-1]; {
+	case last == '吗':
+		text += "？"
+	case unicode.Is(unicode.Han, last):
+		text += "。"
+	}
+	return text
+}
+
+// joinHan 去除汉字之间的空格
+func joinHan(text string) string {
+	r := []rune(text)
+	out := make([]rune, 0, len(r))
+	for i, c := range r {
+		if unicode.IsSpace(c) && len(out) > 0 && unicode.Is(unicode.Han, out[len(out)-1]) {
+			j := i
+			for j < len(r) && unicode.IsSpace(r[j]) {
+				j++
+			}
+			if j < len(r) && unicode.Is(unicode.Han, r[j]) {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
@@ -0,0 +1,33 @@
+package speech
+
+import "testing"
+
+func TestTranscript(t *testing.T) {
+	cases := []struct {
+		lang, in, want string
+	}{
+		{"zh", "嗯，我我我想问一下", "我想问一下。"},
+		{"zh", "呃 那个，那个，今天，今天天气怎么样", "今天天气怎么样。"},
+		{"zh", "谢谢你，慢慢来", "谢谢你，慢慢来。"},
+		{"zh", "我 今天 吃了药,,血压还好吗", "我今天吃了药，血压还好吗？"},
+		{"zh", "，好的。。", "好的。"},
+		{"zh", "药吃了0.5片.", "药吃了0.5片。"},
+		{"zh", "嗯", ""},
+		{"zh-shanghai", "阿拉今朝去公园，侬晓得伐", "我们今天去公园，你知道吗？"},
+		{"zh-shanghai", "侬吃过饭伐，要加快步伐", "你吃过饭吗，要加快步伐。"},
+		{"zh-shanghai", "侬讲好伐", "你讲好吗？"},
+		{"zh-shanghai", "阿拉伯数字", "阿拉伯数字。"},
+	}
+	for _, c := range cases {
+		if got := Transcript(c.lang, nil).Normalize(c.in); got != c.want {
+			t.Errorf("Transcript(%q, %q) = %q, want %q", c.lang, c.in, got, c.want)
+		}
+	}
+}
+
+func TestTranscriptLexicon(t *testing.T) {
+	n := Transcript("zh-sichuan", map[string]string{"巴适": "舒服", "啥子": "什么"})
+	if got := n.Normalize("今天好巴适，吃啥子"); got != "今天好舒服，吃什么。" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,242 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/application/dto"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/mapper/usage"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+	rs "github.com/xh-polaris/psych-senior/biz/infrastructure/redis"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+// 用量按 应用, 机构, 老人 三个维度统计
+// redis中保存当日与当月的实时用量, 用于额度控制; mongo中保存每日汇总, 用于查询
+
+const (
+	ScopeApp         = "app"
+	ScopeInstitution = "institution"
+	ScopeSenior      = "senior"
+)
+
+const (
+	DateLayout  = "2006-01-02"
+	dayLayout   = "20060102"
+	monthLayout = "200601"
+
+	dayTTL   = 48 * time.Hour
+	monthTTL = 32 * 24 * time.Hour
+)
+
+// redis hash中的字段
+const (
+	fieldInputTokens  = "input_tokens"
+	fieldOutputTokens = "output_tokens"
+	fieldTtsChars     = "tts_chars"
+	fieldAsrMillis    = "asr_millis"
+)
+
+// Kind 额度检查的类别
+type Kind int
+
+const (
+	// KindChat 对话, 检查token与合成字数
+	KindChat Kind = iota
+	// KindAsr 语音识别, 检查识别时长
+	KindAsr
+)
+
+// Owner 用量归属的对象, 为空的维度不统计
+type Owner struct {
+	AppId         string
+	InstitutionId string
+	SeniorId      string
+}
+
+// scopes 返回需要统计的维度与对应id
+func (o Owner) scopes() [][2]string {
+	var s [][2]string
+	for _, v := range [][2]string{{ScopeApp, o.AppId}, {ScopeInstitution, o.InstitutionId}, {ScopeSenior, o.SeniorId}} {
+		if v[1] != "" {
+			s = append(s, v)
+		}
+	}
+	return s
+}
+
+var (
+	instance *Recorder
+	once     sync.Once
+)
+
+type Recorder struct {
+	rs     *redis.Redis
+	mapper *usage.MongoMapper
+	quota  *config.Quota
+}
+
+func GetRecorder() *Recorder {
+	once.Do(func() {
+		c := config.GetConfig()
+		instance = &Recorder{
+			rs:     rs.NewRedis(c),
+			mapper: usage.GetMongoMapper(),
+			quota:  &c.Quota,
+		}
+	})
+	return instance
+}
+
+func dayKey(scope, id string, t time.Time) string {
+	return fmt.Sprintf("usage:%s:%s:d:%s", scope, id, t.Format(dayLayout))
+}
+
+func monthKey(scope, id string, t time.Time) string {
+	return fmt.Sprintf("usage:%s:%s:m:%s", scope, id, t.Format(monthLayout))
+}
+
+// Add 累加实时用量
+func (r *Recorder) Add(ctx context.Context, o Owner, stat *dto.UsageStat) error {
+	scopes := o.scopes()
+	if len(scopes) == 0 || stat.Empty() {
+		return nil
+	}
+	now := time.Now()
+	start := time.Now()
+	err := r.rs.PipelinedCtx(ctx, func(p redis.Pipeliner) error {
+		for _, s := range scopes {
+			for key, ttl := range map[string]time.Duration{dayKey(s[0], s[1], now): dayTTL, monthKey(s[0], s[1], now): monthTTL} {
+				for field, v := range map[string]int64{
+					fieldInputTokens:  stat.InputTokens,
+					fieldOutputTokens: stat.OutputTokens,
+					fieldTtsChars:     stat.TtsChars,
+					fieldAsrMillis:    stat.AsrMillis,
+				} {
+					if v != 0 {
+						p.HIncrBy(ctx, key, field, v)
+					}
+				}
+				p.Expire(ctx, key, ttl)
+			}
+		}
+		return nil
+	})
+	metrics.RedisOp.Observe(metrics.Since(start), "usage_add", metrics.Result(err))
+	return err
+}
+
+// Flush 将一次会话的用量汇总到当日的记录中
+func (r *Recorder) Flush(ctx context.Context, o Owner, stat *dto.UsageStat) error {
+	if stat.Empty() {
+		return nil
+	}
+	date := time.Now().Format(DateLayout)
+	for _, s := range o.scopes() {
+		if err := r.mapper.Inc(ctx, s[0], s[1], date, stat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Current 获取对象当日与当月的实时用量
+func (r *Recorder) Current(ctx context.Context, scope, id string) (day, month *dto.UsageStat, err error) {
+	res, err := r.load(ctx, [][2]string{{scope, id}})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res[0][0], res[0][1], nil
+}
+
+// load 批量获取各维度当日与当月的用量
+func (r *Recorder) load(ctx context.Context, scopes [][2]string) ([][2]*dto.UsageStat, error) {
+	now := time.Now()
+	vals := make([][2]func() map[string]string, len(scopes))
+	start := time.Now()
+	err := r.rs.PipelinedCtx(ctx, func(p redis.Pipeliner) error {
+		for i, s := range scopes {
+			vals[i][0] = p.HGetAll(ctx, dayKey(s[0], s[1], now)).Val
+			vals[i][1] = p.HGetAll(ctx, monthKey(s[0], s[1], now)).Val
+		}
+		return nil
+	})
+	metrics.RedisOp.Observe(metrics.Since(start), "usage_load", metrics.Result(err))
+	if err != nil {
+		return nil, err
+	}
+	res := make([][2]*dto.UsageStat, len(scopes))
+	for i := range vals {
+		res[i][0], res[i][1] = parse(vals[i][0]()), parse(vals[i][1]())
+	}
+	return res, nil
+}
+
+func parse(m map[string]string) *dto.UsageStat {
+	get := func(field string) int64 {
+		v, _ := strconv.ParseInt(m[field], 10, 64)
+		return v
+	}
+	return &dto.UsageStat{
+		InputTokens:  get(fieldInputTokens),
+		OutputTokens: get(fieldOutputTokens),
+		TtsChars:     get(fieldTtsChars),
+		AsrMillis:    get(fieldAsrMillis),
+	}
+}
+
+// Limit 获取对象的额度, 优先使用单独配置的额度
+func (r *Recorder) Limit(scope, id string) config.QuotaRule {
+	if rule, ok := r.quota.Overrides[scope+":"+id]; ok {
+		return rule
+	}
+	switch scope {
+	case ScopeApp:
+		return r.quota.App
+	case ScopeInstitution:
+		return r.quota.Institution
+	default:
+		return r.quota.Senior
+	}
+}
+
+// Exceeded 检查对象的额度是否已用尽, 任一维度用尽即视为用尽
+func (r *Recorder) Exceeded(ctx context.Context, o Owner, kind Kind) (bool, error) {
+	scopes := o.scopes()
+	if len(scopes) == 0 {
+		return false, nil
+	}
+	res, err := r.load(ctx, scopes)
+	if err != nil {
+		return false, err
+	}
+	for i, s := range scopes {
+		rule := r.Limit(s[0], s[1])
+		if exceeded(res[i][0], rule.Daily, kind) || exceeded(res[i][1], rule.Monthly, kind) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func exceeded(stat *dto.UsageStat, limit config.QuotaLimit, kind Kind) bool {
+	if kind == KindAsr {
+		return limit.AsrSeconds > 0 && stat.AsrMillis >= limit.AsrSeconds*1000
+	}
+	return (limit.Tokens > 0 && stat.InputTokens+stat.OutputTokens >= limit.Tokens) ||
+		(limit.TtsChars > 0 && stat.TtsChars >= limit.TtsChars)
+}
+
+// Message 额度用尽时的提示语
+func (r *Recorder) Message() string {
+	return r.quota.Message
+}
+
+// History 查询对象在[from, to]日期内的每日用量
+func (r *Recorder) History(ctx context.Context, scope, id, from, to string) ([]*usage.Usage, error) {
+	return r.mapper.FindRange(ctx, scope, id, from, to)
+}
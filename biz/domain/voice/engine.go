@@ -1,18 +1,40 @@
 package voice
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/hertz-contrib/websocket"
 	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-senior/biz/application/dto"
 	"github.com/xh-polaris/psych-senior/biz/domain"
+	"github.com/xh-polaris/psych-senior/biz/domain/audio"
+	"github.com/xh-polaris/psych-senior/biz/domain/hotword"
+	"github.com/xh-polaris/psych-senior/biz/domain/language"
 	"github.com/xh-polaris/psych-senior/biz/domain/model"
-	"github.com/xh-polaris/psych-senior/biz/domain/model/volc"
+	"github.com/xh-polaris/psych-senior/biz/domain/model/failover"
+	"github.com/xh-polaris/psych-senior/biz/domain/preference"
+	"github.com/xh-polaris/psych-senior/biz/domain/recording"
+	"github.com/xh-polaris/psych-senior/biz/domain/usage"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/trace"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// asrFormat 语音识别要求的音频格式, 前端的音频转换为该格式后发送
+var asrFormat = audio.Format{Encoding: audio.PCM16, SampleRate: 16000, Channels: 1}
+
+// defaultLang 未指定语言时指标中使用的语言
+const defaultLang = "default"
+
 type Engine struct {
 	// ctx 上下文
 	ctx    context.Context
@@ -24,35 +46,228 @@ type Engine struct {
 	// asrApp 语音识别app
 	asrApp model.AsrApp
 
-	// finish 结束
+	// finish 结束, 由end关闭
 	finish chan struct{}
+	ended  sync.Once
+
+	// provider 第三方服务标识, 用于指标统计
+	provider string
+	// lang 识别的语言, 用于指标统计, 未指定时为default
+	lang string
+
+	// started 是否完成初始化, 用于统计活跃连接数
+	started bool
+
+	// span 本次识别会话的追踪span
+	span oteltrace.Span
+
+	// owner 用量归属的对象
+	owner usage.Owner
+	// recorder 用量统计与额度控制
+	recorder *usage.Recorder
+	// uplink 前端音频到识别格式的转换, opus重新封装为ogg页
+	uplink *audio.Uplink
+	// pending 未发送开始请求时读到的首个音频包
+	pending []byte
+	// audioTime 已发送识别的音频时长, 单位纳秒
+	audioTime atomic.Int64
+	// reconnecting 语音识别正在重连, 用于通知去重
+	reconnecting atomic.Bool
+	// context 老人的热词与最近的对话, 提高家人名字与药品名等的识别准确率
+	context *model.AsrContext
+	// turn 老人这次说话所在的对话轮次, 由对话记录推算, 每识别出一句话进入下一轮, 用于录音与回复对齐
+	turn atomic.Int64
+	// track 老人说话的录音, 未同意录音或没有会话id时为nil
+	track *recording.Track
 }
 
 // NewEngine 初始化
 func NewEngine(ctx context.Context, conn *websocket.Conn) *Engine {
-	ctx, cancel := context.WithCancel(context.Background())
-	c := config.GetConfig()
+	// 会话的生命周期长于协议升级请求, 只保留追踪信息
+	ctx, span := trace.Start(trace.Detach(ctx), "asr.session", attribute.String("provider", consts.VolcAsr))
+	ctx, cancel := context.WithCancel(ctx)
 	e := &Engine{
-		ctx:    ctx,
-		cancel: cancel,
-		ws:     domain.NewWsHelper(conn),
-		asrApp: volc.NewVcAsrApp(c.VolcAsr.AppKey, c.VolcAsr.AccessKey, c.VolcAsr.ResourceId, c.VolcAsr.Url),
-		finish: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		span:     span,
+		ws:       domain.NewWsHelper(conn),
+		asrApp:   failover.NewAsrApp(model.EncodingPCM, config.LanguageAsr{}),
+		finish:   make(chan struct{}),
+		provider: consts.VolcAsr,
+		lang:     defaultLang,
+		recorder: usage.GetRecorder(),
 	}
 	return e
 }
 
 // Start 初始化
 func (e *Engine) Start() error {
+	if err := e.handshake(); err != nil {
+		return err
+	}
+	if exceeded, err := e.recorder.Exceeded(e.ctx, e.owner, usage.KindAsr); err != nil {
+		log.Error("check quota err:", err)
+	} else if exceeded {
+		_ = e.ws.Error(consts.ErrQuotaExceeded)
+		return consts.ErrQuotaExceeded
+	}
+	if r, ok := e.asrApp.(model.Reconnectable); ok {
+		r.OnReconnect(e.onReconnect)
+	}
+	if c, ok := e.asrApp.(model.Contextual); ok && e.context != nil {
+		c.SetContext(e.context)
+	}
 	if err := e.asrApp.Dial(); err != nil {
+		metrics.AsrError.Inc(e.provider, e.lang)
 		return err
 	}
 	if err := e.asrApp.Start(); err != nil {
+		metrics.AsrError.Inc(e.provider, e.lang)
+		return err
+	}
+	if e.uplink.Ogg() {
+		header := e.uplink.Header()
+		if err := e.asrApp.Send(header); err != nil {
+			metrics.AsrError.Inc(e.provider, e.lang)
+			return err
+		}
+		e.track.SetHeader(header)
+	}
+	e.started = true
+	metrics.AsrSessionActive.Inc(e.provider, e.lang)
+	return nil
+}
+
+// handshake 读取可选的开始请求, 首个消息为音频时直接作为待识别数据
+func (e *Engine) handshake() error {
+	mt, data, err := e.ws.Read()
+	if err != nil {
+		return err
+	}
+	if mt == websocket.BinaryMessage {
+		e.pending = data
+		e.uplink, err = audio.NewUplink(asrFormat, asrFormat, 0)
+		return err
+	}
+	var req dto.AsrStartReq
+	if err = json.Unmarshal(data, &req); err != nil {
+		_ = e.ws.Error(consts.ErrInvalidParam)
+		return err
+	}
+	e.owner = usage.Owner{
+		AppId:         req.AppId,
+		InstitutionId: req.InstitutionId,
+		SeniorId:      req.SeniorId,
+	}
+	e.context = e.loadContext(req.SeniorId, req.SessionId)
+	// 未指定语言时使用默认的识别模型
+	var lang config.LanguageAsr
+	if req.Lang != "" {
+		l, ok := language.GetRegistry().Get(req.Lang)
+		if !ok {
+			_ = e.ws.Error(consts.ErrInvalidParam)
+			return consts.ErrInvalidParam
+		}
+		lang, e.lang = l.Asr, l.Code
+	}
+	f := req.InputFormat
+	src := audio.Format{Encoding: f.Encoding, SampleRate: f.SampleRate, Channels: f.Channels}
+	if e.uplink, err = audio.NewUplink(src, asrFormat, rand.Uint32()); err != nil {
+		_ = e.ws.Error(consts.ErrInvalidParam)
 		return err
 	}
+	if e.uplink.Ogg() {
+		// opus直接交给第三方解码, 减少上行带宽
+		e.asrApp = failover.NewAsrApp(model.EncodingOggOpus, lang)
+		e.track = e.newTrack(req.SeniorId, req.SessionId, recording.Format{Ogg: true})
+		return nil
+	}
+	e.asrApp = failover.NewAsrApp(model.EncodingPCM, lang)
+	e.track = e.newTrack(req.SeniorId, req.SessionId, recording.Format{SampleRate: asrFormat.SampleRate, Channels: asrFormat.Channels})
 	return nil
 }
 
+// loadContext 读取老人的热词与对话最近的内容作为识别上下文, 读取失败的部分跳过, 不影响识别
+func (e *Engine) loadContext(seniorId, sessionId string) *model.AsrContext {
+	if seniorId == "" && sessionId == "" {
+		return nil
+	}
+	c := &model.AsrContext{Uid: seniorId}
+	if seniorId != "" {
+		if h, err := hotword.GetStore().Load(e.ctx, seniorId); err != nil {
+			log.Error("load hotword err:", err)
+		} else {
+			c.Hotwords = hotword.Words(h)
+		}
+	}
+	if sessionId != "" {
+		if histories, err := domain.GetRedisHelper().Load(e.ctx, sessionId); err != nil {
+			log.Error("load asr dialog err:", err)
+		} else {
+			c.Dialog = hotword.Recent(histories)
+			for _, h := range histories {
+				e.turn.Store(max(e.turn.Load(), h.Turn+1))
+			}
+		}
+		// 识别期间对话仍在进行, 重连时使用最新的对话
+		c.Reload = func() ([]string, error) {
+			histories, err := domain.GetRedisHelper().Load(e.ctx, sessionId)
+			if err != nil {
+				return nil, err
+			}
+			return hotword.Recent(histories), nil
+		}
+	}
+	return c
+}
+
+// newTrack 老人同意录音时录制发送识别的音频, 录音属于对话的会话, 没有会话id时不录音
+func (e *Engine) newTrack(seniorId, sessionId string, f recording.Format) *recording.Track {
+	r := recording.GetRecorder()
+	if seniorId == "" || sessionId == "" || !r.Allowed(true) {
+		return nil
+	}
+	p, err := preference.GetStore().Load(e.ctx, seniorId)
+	if err != nil {
+		log.Error("load preference err:", err)
+		return nil
+	}
+	if !r.Allowed(p.RecordConsent) {
+		return nil
+	}
+	t := r.NewTrack(recording.SideUser, seniorId, f)
+	t.SetSession(sessionId)
+	return t
+}
+
+// onReconnect 语音识别断线重连时通知前端, 重连失败时由recognise结束会话
+func (e *Engine) onReconnect(state model.ReconnectState) {
+	var event, msg string
+	switch state {
+	case model.Reconnecting:
+		if !e.reconnecting.CompareAndSwap(false, true) {
+			return
+		}
+		event, msg = consts.EventAsrReconnecting, "语音识别连接中断, 正在重新连接"
+	case model.Reconnected:
+		if !e.reconnecting.CompareAndSwap(true, false) {
+			return
+		}
+		event, msg = consts.EventAsrReconnected, "语音识别已重新连接"
+	default:
+		e.reconnecting.Store(false)
+		return
+	}
+	e.span.AddEvent(event)
+	if err := e.ws.WriteJSON(&dto.ChatNotice{
+		Type:  consts.Notice,
+		Event: event,
+		Msg:   msg,
+	}); err != nil {
+		log.Error("write notice err:", err)
+	}
+}
+
 // Listen 主事件循环, 获取前端的音频流输入, 返回文字
 func (e *Engine) Listen() {
 	go e.listen()
@@ -60,38 +275,79 @@ func (e *Engine) Listen() {
 	<-e.finish
 }
 
-// recognise 识别音频并写入输入
+// end 结束会话, 可重复调用
+func (e *Engine) end() {
+	e.ended.Do(func() { close(e.finish) })
+}
+
+// recognise 识别音频并写入输入, 服务端识别完毕后结束会话
 func (e *Engine) recognise() {
+	// last 上一句确定的时间, 作为下一句话span的起点
+	last := time.Now()
 	for {
 		select {
 		case <-e.ctx.Done():
 			return
 		default:
 			// 获取响应并写入ws
-			text, err := e.asrApp.Receive()
+			result, err := e.asrApp.Receive()
 			if err == io.EOF {
+				e.end()
 				return
 			} else if err != nil {
+				metrics.AsrError.Inc(e.provider, e.lang)
 				log.Error("获取响应失败", err)
-				e.finish <- struct{}{}
+				e.end()
 				return
 			}
-			if text == "" {
+			if result == nil {
 				continue
 			}
-			resp := &dto.AsrResp{
-				Text:      text,
-				Timestamp: time.Now().Unix(),
-			}
-			if err = e.ws.WriteJSON(resp); err != nil {
-				log.Error("写入响应失败", err)
-				e.finish <- struct{}{}
-				return
+			for _, resp := range e.responses(result) {
+				if resp.Type == consts.AsrFinal {
+					_, span := trace.StartAt(e.ctx, "asr.utterance", last, attribute.Int("text_len", len(resp.Text)))
+					span.End()
+					last = time.Now()
+					// 这句话会作为一轮对话发送, 之后的音频属于下一轮
+					e.turn.Add(1)
+				}
+				if err = e.ws.WriteJSON(resp); err != nil {
+					log.Error("写入响应失败", err)
+					e.end()
+					return
+				}
 			}
 		}
 	}
 }
 
+// responses 每句话一个响应, 服务端未分句时整体作为中间结果
+func (e *Engine) responses(result *model.AsrResult) []*dto.AsrResp {
+	now := time.Now().Unix()
+	if len(result.Utterances) == 0 {
+		if result.Text == "" {
+			return nil
+		}
+		return []*dto.AsrResp{{Type: consts.AsrPartial, Text: result.Text, Timestamp: now}}
+	}
+	resps := make([]*dto.AsrResp, 0, len(result.Utterances))
+	for _, u := range result.Utterances {
+		typ := consts.AsrPartial
+		if u.Definite {
+			typ = consts.AsrFinal
+		}
+		resps = append(resps, &dto.AsrResp{
+			Type:       typ,
+			Text:       u.Text,
+			Timestamp:  now,
+			Start:      u.Start.Milliseconds(),
+			End:        u.End.Milliseconds(),
+			Confidence: u.Confidence,
+		})
+	}
+	return resps
+}
+
 // listen 获取音频输入并发送给asr #生产者
 func (e *Engine) listen() {
 	for {
@@ -99,12 +355,20 @@ func (e *Engine) listen() {
 		case <-e.ctx.Done():
 			return
 		default:
-			data, err := e.ws.ReadBytes()
+			var data []byte
+			var err error
+			// 优先处理握手时读到的音频
+			if e.pending != nil {
+				data, e.pending = e.pending, nil
+			} else {
+				data, err = e.ws.ReadBytes()
+			}
 			if err == io.EOF {
 				return
 			} else if err != nil {
 				log.Error("listen:receive user:err ", err)
-				e.finish <- struct{}{}
+				e.end()
+				return
 			} else if data == nil || len(data) == 0 {
 				continue
 			}
@@ -114,17 +378,55 @@ func (e *Engine) listen() {
 				}
 				return
 			}
-			if err = e.asrApp.Send(data); err != nil {
-				log.Error("listen:send asr:err ", err)
-				e.finish <- struct{}{}
+			if err = e.send(data); err != nil {
 				return
 			}
 		}
 	}
 }
 
+// send 发送音频用于识别, 并统计音频时长
+func (e *Engine) send(data []byte) error {
+	chunks, d, err := e.uplink.Encode(data)
+	if errors.Is(err, audio.ErrDropped) {
+		// 损坏的页与过长的数据包直接丢弃, 不中断识别
+		log.Error("listen:drop audio:err ", err)
+	} else if err != nil {
+		log.Error("listen:convert audio:err ", err)
+		e.end()
+		return err
+	}
+	for _, chunk := range chunks {
+		e.track.Write(e.turn.Load(), chunk)
+		if err = e.asrApp.Send(chunk); err != nil {
+			metrics.AsrError.Inc(e.provider, e.lang)
+			log.Error("listen:send asr:err ", err)
+			e.end()
+			return err
+		}
+	}
+	e.audioTime.Add(int64(d))
+	return nil
+}
+
 // Close 释放资源
 func (e *Engine) Close() error {
+	// 统计本次识别的音频时长
+	stat := &dto.UsageStat{AsrMillis: time.Duration(e.audioTime.Load()).Milliseconds()}
+	ctx := trace.Detach(e.ctx)
+	if err := e.recorder.Add(ctx, e.owner, stat); err != nil {
+		log.Error("add usage err:", err)
+	}
+	if err := e.recorder.Flush(ctx, e.owner, stat); err != nil {
+		log.Error("flush usage err:", err)
+	}
+	if e.started {
+		metrics.AsrSessionActive.Dec(e.provider, e.lang)
+	}
 	e.cancel()
-	return e.ws.Close()
+	e.span.End()
+	err := e.ws.Close()
+	// 等待录音保存完成
+	e.track.Close()
+	return err
 }
@@ -0,0 +1,53 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+// 存储方式
+const (
+	TypeLocal = "local"
+	TypeS3    = "s3"
+)
+
+const defaultDir = "data/blob"
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("blob not found")
+
+// Store 文件的存储, key为以/分隔的路径
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get 读取文件, 返回内容与长度, 不存在时返回ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+}
+
+var (
+	store Store
+	once  sync.Once
+)
+
+// GetStore 获取录音使用的存储
+func GetStore() Store {
+	once.Do(func() {
+		store = New(config.GetConfig().Recording.Blob)
+	})
+	return store
+}
+
+// New 按配置创建存储, 默认为本地目录
+func New(c config.Blob) Store {
+	if c.Type == TypeS3 {
+		return newS3Store(c.S3)
+	}
+	dir := c.Dir
+	if dir == "" {
+		dir = defaultDir
+	}
+	return &localStore{dir: dir}
+}
@@ -0,0 +1,72 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+func roundTrip(t *testing.T, s Store) {
+	ctx := context.Background()
+	if _, _, err := s.Get(ctx, "a/missing.wav"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing err = %v", err)
+	}
+	if err := s.Put(ctx, "a/b/1.wav", []byte("audio")); err != nil {
+		t.Fatal(err)
+	}
+	r, size, err := s.Get(ctx, "a/b/1.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	data, _ := io.ReadAll(r)
+	if string(data) != "audio" || size != 5 {
+		t.Errorf("got %q, size %d", data, size)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	s := New(config.Blob{Dir: t.TempDir()})
+	roundTrip(t, s)
+	if err := s.Put(context.Background(), "../escape", nil); err == nil {
+		t.Error("key with .. should be rejected")
+	}
+}
+
+func TestS3(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		}
+	}))
+	defer srv.Close()
+	roundTrip(t, New(config.Blob{Type: TypeS3, S3: config.S3{
+		Endpoint: srv.URL, Bucket: "records", AccessKey: "ak", SecretKey: "sk", PathStyle: true,
+	}}))
+	if _, ok := objects["/records/a/b/1.wav"]; !ok {
+		t.Errorf("objects = %v", objects)
+	}
+}
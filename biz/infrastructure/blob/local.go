@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localStore 本地目录存储, 每个key一个文件
+type localStore struct {
+	dir string
+}
+
+func (s *localStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid blob key: %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+// Put 先写入临时文件再重命名, 避免读到写了一半的文件
+func (s *localStore) Put(_ context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *localStore) Get(_ context.Context, key string) (io.ReadCloser, int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
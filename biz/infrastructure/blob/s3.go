@@ -0,0 +1,159 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+// s3Timeout 单次请求的超时时间, 录音按轮保存, 单个文件不大
+const s3Timeout = 60 * time.Second
+
+// s3Store 兼容S3协议的对象存储, 使用AWS Signature V4签名
+type s3Store struct {
+	c      config.S3
+	client *http.Client
+}
+
+func newS3Store(c config.S3) *s3Store {
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	c.Endpoint = strings.TrimSuffix(c.Endpoint, "/")
+	return &s3Store{c: c, client: &http.Client{Timeout: s3Timeout}}
+}
+
+// url 对象的地址
+func (s *s3Store) url(key string) (*url.URL, error) {
+	u, err := url.Parse(s.c.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if s.c.PathStyle {
+		u.Path = "/" + s.c.Bucket + "/" + key
+	} else {
+		u.Host = s.c.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return u, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, data []byte) error {
+	req, err := s.request(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("put blob %s: [code=%d] %s", key, resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		_ = resp.Body.Close()
+		return nil, 0, ErrNotFound
+	case resp.StatusCode/100 != 2:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		return nil, 0, fmt.Errorf("get blob %s: [code=%d] %s", key, resp.StatusCode, body)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// request 构造签名后的请求
+func (s *s3Store) request(ctx context.Context, method, key string, data []byte) (*http.Request, error) {
+	u, err := s.url(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		req.Body, req.ContentLength = http.NoBody, 0
+	}
+	s.sign(req, data, time.Now())
+	return req, nil
+}
+
+// sign 按AWS Signature V4为请求签名
+func (s *s3Store) sign(req *http.Request, data []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payload := sha256Hex(data)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+
+	const signed = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		"",
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payload + "\nx-amz-date:" + amzDate + "\n",
+		signed,
+		payload,
+	}, "\n")
+	scope := date + "/" + s.c.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.c.SecretKey), date)
+	key = hmacSHA256(key, s.c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.c.AccessKey+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+signature)
+}
+
+// escapePath 对路径的各段编码, 保留/
+func escapePath(path string) string {
+	var sb strings.Builder
+	for _, b := range []byte(path) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/':
+			sb.WriteByte(b)
+		default:
+			_, _ = fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
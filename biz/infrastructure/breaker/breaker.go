@@ -0,0 +1,226 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+)
+
+// 按后端维度的熔断器, 失败率或耗时超过阈值时熔断, 冷却后放行一次探测调用
+// go-zero自带的breaker是自适应的, 无法按后端配置阈值, 所以这里单独实现
+
+// ErrOpen 熔断器打开, 调用被拒绝
+var ErrOpen = errors.New("circuit breaker is open")
+
+// 未配置时的默认阈值
+const (
+	defaultErrorRate   = 0.5
+	defaultLatency     = 10000
+	defaultMinRequests = 5
+	defaultWindow      = 60
+	defaultCooldown    = 30
+)
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+// Breaker 一个后端的熔断器
+type Breaker struct {
+	name string
+	rule config.Breaker
+
+	mu    sync.Mutex
+	state State
+	// windowStart, total, failures 当前统计窗口的起点, 调用数与失败数
+	windowStart time.Time
+	total       int64
+	failures    int64
+	// openUntil 熔断的结束时间
+	openUntil time.Time
+	// probing 半开状态下是否已放行探测调用
+	probing bool
+}
+
+var (
+	mu       sync.Mutex
+	breakers = make(map[string]*Breaker)
+)
+
+// Get 获取后端对应的熔断器, 同一后端在所有会话间共享
+// rules 按优先级排列, 每项取第一个非0的配置
+func Get(name string, rules ...config.Breaker) *Breaker {
+	mu.Lock()
+	defer mu.Unlock()
+	if b, ok := breakers[name]; ok {
+		return b
+	}
+	b := &Breaker{
+		name:        name,
+		rule:        merge(rules...),
+		windowStart: time.Now(),
+	}
+	breakers[name] = b
+	metrics.BreakerState.Set(float64(Closed), name)
+	return b
+}
+
+func merge(rules ...config.Breaker) config.Breaker {
+	r := config.Breaker{}
+	for _, rule := range append(rules, config.Breaker{
+		ErrorRate:   defaultErrorRate,
+		Latency:     defaultLatency,
+		MinRequests: defaultMinRequests,
+		Window:      defaultWindow,
+		Cooldown:    defaultCooldown,
+	}) {
+		if r.ErrorRate == 0 {
+			r.ErrorRate = rule.ErrorRate
+		}
+		if r.Latency == 0 {
+			r.Latency = rule.Latency
+		}
+		if r.MinRequests == 0 {
+			r.MinRequests = rule.MinRequests
+		}
+		if r.Window == 0 {
+			r.Window = rule.Window
+		}
+		if r.Cooldown == 0 {
+			r.Cooldown = rule.Cooldown
+		}
+	}
+	return r
+}
+
+// Name 后端名称
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Call 一次被允许的调用, 结束时必须调用Mark或Cancel
+type Call struct {
+	b *Breaker
+	// probe 是否为半开状态下的探测调用, 只有探测调用的结果决定半开后的状态
+	probe bool
+}
+
+// Allow 是否允许调用, 允许时返回本次调用
+func (b *Breaker) Allow() (*Call, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if time.Now().Before(b.openUntil) {
+			return nil, false
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+		return &Call{b: b, probe: true}, true
+	case HalfOpen:
+		if b.probing {
+			return nil, false
+		}
+		b.probing = true
+		return &Call{b: b, probe: true}, true
+	default:
+		return &Call{b: b}, true
+	}
+}
+
+// Mark 记录调用的结果, 耗时超过阈值同样视为失败
+func (c *Call) Mark(err error, latency time.Duration) {
+	c.b.mark(c.probe, err != nil || latency > time.Duration(c.b.rule.Latency)*time.Millisecond)
+}
+
+// Cancel 放弃调用, 如调用方主动取消, 不记录结果, 探测调用释放探测名额
+func (c *Call) Cancel() {
+	if !c.probe {
+		return
+	}
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	if c.b.state == HalfOpen {
+		c.b.probing = false
+	}
+}
+
+// Report 记录不经过Allow的调用结果, 如流式响应中途的错误
+// 只在关闭状态下计入失败率, 不影响半开状态下等待中的探测
+func (b *Breaker) Report(err error) {
+	b.mark(false, err != nil)
+}
+
+func (b *Breaker) mark(probe, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	switch b.state {
+	case HalfOpen:
+		if !probe {
+			// 熔断前放行的调用, 不代表探测的结果
+			return
+		}
+		b.probing = false
+		if failed {
+			b.trip(now)
+		} else {
+			b.reset(now)
+			b.setState(Closed)
+		}
+	case Closed:
+		if now.Sub(b.windowStart) > time.Duration(b.rule.Window)*time.Second {
+			b.reset(now)
+		}
+		b.total++
+		if failed {
+			b.failures++
+		}
+		if b.total >= b.rule.MinRequests && float64(b.failures)/float64(b.total) >= b.rule.ErrorRate {
+			b.trip(now)
+		}
+	default:
+		// 熔断期间放行前的调用结果, 不影响状态
+	}
+}
+
+// Do 在熔断器保护下执行fn
+func (b *Breaker) Do(fn func() error) error {
+	call, ok := b.Allow()
+	if !ok {
+		return ErrOpen
+	}
+	start := time.Now()
+	err := fn()
+	call.Mark(err, time.Since(start))
+	return err
+}
+
+// State 当前状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) trip(now time.Time) {
+	b.openUntil = now.Add(time.Duration(b.rule.Cooldown) * time.Second)
+	b.setState(Open)
+}
+
+func (b *Breaker) reset(now time.Time) {
+	b.windowStart = now
+	b.total, b.failures = 0, 0
+}
+
+func (b *Breaker) setState(s State) {
+	b.state = s
+	metrics.BreakerState.Set(float64(s), b.name)
+}
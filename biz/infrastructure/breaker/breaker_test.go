@@ -0,0 +1,62 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+func TestBreaker(t *testing.T) {
+	b := Get("test", config.Breaker{ErrorRate: 0.5, MinRequests: 2, Latency: 100, Cooldown: 1})
+	fail := errors.New("fail")
+
+	b.Report(nil)
+	b.Report(fail)
+	if _, ok := b.Allow(); ok {
+		t.Fatal("breaker should be open")
+	}
+
+	time.Sleep(time.Second)
+	probe, ok := b.Allow()
+	if !ok {
+		t.Fatal("breaker should allow a probe after cooldown")
+	}
+	if _, ok = b.Allow(); ok {
+		t.Fatal("breaker should allow only one probe")
+	}
+	// 中途的错误不影响等待中的探测
+	b.Report(fail)
+	if b.State() != HalfOpen {
+		t.Fatal("reported error should not change a half open breaker")
+	}
+	// 探测调用超时, 重新熔断
+	probe.Mark(nil, 200*time.Millisecond)
+	if b.State() != Open {
+		t.Fatal("slow probe should trip the breaker")
+	}
+
+	time.Sleep(time.Second)
+	if err := b.Do(func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if b.State() != Closed {
+		t.Fatal("successful probe should close the breaker")
+	}
+}
+
+func TestBreakerCancel(t *testing.T) {
+	b := Get("test_cancel", config.Breaker{ErrorRate: 0.5, MinRequests: 1, Cooldown: 1})
+	b.Report(errors.New("fail"))
+	time.Sleep(time.Second)
+	probe, ok := b.Allow()
+	if !ok {
+		t.Fatal("breaker should allow a probe after cooldown")
+	}
+	// 取消的探测释放名额, 之后可以再次探测
+	probe.Cancel()
+	if _, ok = b.Allow(); !ok {
+		t.Fatal("canceled probe should release the probe slot")
+	}
+}
@@ -33,11 +33,23 @@ type Config struct {
 	RabbitMQ            RabbitMQ
 	SMTP                SMTP
 	BaiLianChat         BaiLianChat
-	BaiLianShanghaiChat BaiLianShanghaiChat
+	BaiLianShanghaiChat BaiLianShanghaiChat `json:",optional"`
 	BaiLianReport       BaiLianReport
 	VolcTts             VolcTts
 	VolcAsr             VolcAsr
 	VolcNoModelTts      VolcNoModelTts
+	Health              Health      `json:",optional"`
+	Quota               Quota       `json:",optional"`
+	Failover            Failover    `json:",optional"`
+	HttpClient          HttpClient  `json:",optional"`
+	Segment             Segment     `json:",optional"`
+	TtsPool             ConnPool    `json:",optional"`
+	PhraseCache         PhraseCache `json:",optional"`
+	Reconnect           Reconnect   `json:",optional"`
+	VoiceStyle          VoiceStyle  `json:",optional"`
+	// Languages 支持的语言与方言, 为空时使用内置的普通话与沪语
+	Languages []Language `json:",optional"`
+	Recording Recording  `json:",optional"`
 }
 
 type Auth struct {
@@ -53,16 +65,65 @@ type RabbitMQ struct {
 type BaiLianChat struct {
 	AppId  string
 	ApiKey string
+	// PromptVersion 应用当前使用的提示词版本, 随对话记录保存
+	PromptVersion string  `json:",optional"`
+	Breaker       Breaker `json:",optional"`
 }
 
-type BaiLianShanghaiChat struct {
-	AppId  string
-	ApiKey string
+// BaiLianShanghaiChat 沪语对话应用, 配置项与普通话一致, 只在未配置Languages时使用
+type BaiLianShanghaiChat = BaiLianChat
+
+// Language 一种语言或方言使用的对话, 合成与识别服务, 未配置的项使用默认的服务
+type Language struct {
+	// Code 开始对话时前端传入的语言, 如zh, zh-shanghai, zh-cantonese
+	Code string
+	// Name 展示给用户的名称
+	Name string
+	// Chat 对话应用, AppId为空时使用BaiLianChat与其备用后端
+	Chat         BaiLianChat   `json:",optional"`
+	ChatFailover []BaiLianChat `json:",optional"`
+	Tts          LanguageTts   `json:",optional"`
+	Asr          LanguageAsr   `json:",optional"`
+	// ReportPrompt 生成报告时附加在对话记录前的说明, 如方言的转写习惯
+	ReportPrompt string `json:",optional"`
+	// Lexicon 方言写法到普通话的词表, 与内置词表合并后用于规范化语音识别的结果
+	Lexicon map[string]string `json:",optional"`
+	// Scripts 固定话术, 为空的项使用默认话术
+	Scripts Scripts `json:",optional"`
+}
+
+// Scripts 固定话术, 直接播报并缓存音频, 不调用大模型
+type Scripts struct {
+	// Greeting 开场白
+	Greeting string `json:",optional"`
+	// Goodbye 老人结束对话时的告别语
+	Goodbye string `json:",optional"`
+}
+
+// LanguageTts 语言使用的语音合成
+type LanguageTts struct {
+	// Backend volc_tts为双向流式合成, volc_nomodel_tts为逐句合成, 默认为volc_tts
+	Backend string `json:",optional"`
+	// Speaker 音色, 为空时使用后端配置的音色
+	Speaker string `json:",optional"`
+	// Lang 逐句合成的语言, 为空时使用VolcNoModelTts.Lang
+	Lang string `json:",optional"`
+}
+
+// LanguageAsr 语言使用的语音识别
+type LanguageAsr struct {
+	// ResourceId 识别模型的资源id, 为空时使用VolcAsr配置的资源id
+	ResourceId string `json:",optional"`
+	// Language 识别的语言, 为空时由服务端自动判断
+	Language string `json:",optional"`
 }
 
 type BaiLianReport struct {
 	AppId  string
 	ApiKey string
+	// Timeout 报告生成的超时时间(ms), 默认180000
+	Timeout int64   `json:",optional"`
+	Breaker Breaker `json:",optional"`
 }
 
 type VolcTts struct {
@@ -71,6 +132,7 @@ type VolcTts struct {
 	AccessKey  string
 	Speaker    string
 	ResourceId string
+	Breaker    Breaker `json:",optional"`
 }
 
 type VolcNoModelTts struct {
@@ -79,7 +141,8 @@ type VolcNoModelTts struct {
 	AccessKey string
 	Speaker   string
 	Cluster   string
-	Lang      string
+	Lang      string  `json:",optional"`
+	Breaker   Breaker `json:",optional"`
 }
 
 type VolcAsr struct {
@@ -87,6 +150,185 @@ type VolcAsr struct {
 	AppKey     string
 	AccessKey  string
 	ResourceId string
+	// ResultType 结果的返回方式, single增量返回, full全量返回, 默认为single
+	ResultType string  `json:",optional"`
+	Breaker    Breaker `json:",optional"`
+}
+
+// Failover 各服务的备用后端, 主后端为上方的配置, 不可用时按顺序切换到备用后端
+type Failover struct {
+	BaiLianChat         []BaiLianChat    `json:",optional"`
+	BaiLianShanghaiChat []BaiLianChat    `json:",optional"`
+	BaiLianReport       []BaiLianReport  `json:",optional"`
+	VolcTts             []VolcTts        `json:",optional"`
+	VolcNoModelTts      []VolcNoModelTts `json:",optional"`
+	VolcAsr             []VolcAsr        `json:",optional"`
+	// Breaker 默认的熔断阈值, 后端未单独配置时使用
+	Breaker Breaker `json:",optional"`
+}
+
+// Breaker 熔断阈值, 为0的项使用默认值
+type Breaker struct {
+	// ErrorRate 统计窗口内的失败率达到该值时熔断, 取值0~1
+	ErrorRate float64 `json:",optional"`
+	// Latency 调用耗时超过该值时视为失败, 单位毫秒
+	Latency int64 `json:",optional"`
+	// MinRequests 统计窗口内的调用数达到该值才会熔断
+	MinRequests int64 `json:",optional"`
+	// Window 统计窗口, 单位秒
+	Window int64 `json:",optional"`
+	// Cooldown 熔断后经过该时间放行一次探测调用, 单位秒
+	Cooldown int64 `json:",optional"`
+}
+
+// HttpClient 调用第三方http接口的超时与重试配置, 为0的项使用默认值
+type HttpClient struct {
+	// ConnectTimeout 建立连接的超时时间, 单位毫秒
+	ConnectTimeout int64 `json:",optional"`
+	// FirstByteTimeout 发出请求到收到响应头的超时时间, 单位毫秒
+	FirstByteTimeout int64 `json:",optional"`
+	// IdleTimeout 流式响应两次读取之间的最长间隔, 单位毫秒
+	IdleTimeout int64 `json:",optional"`
+	// RequestTimeout 非流式请求的总超时时间, 包括重试, 单位毫秒
+	RequestTimeout int64 `json:",optional"`
+	// Retries 幂等请求失败后的重试次数, 小于0表示不重试
+	Retries int `json:",optional"`
+	// RetryBackoff 重试的基础退避时间, 实际退避时间指数增长并加入随机抖动, 单位毫秒
+	RetryBackoff int64 `json:",optional"`
+}
+
+// Reconnect 语音合成与识别的连接断开后的重连配置, 为0的项使用默认值
+type Reconnect struct {
+	// Attempts 每次断开后的最多重连次数
+	Attempts int `json:",optional"`
+	// Backoff 第一次重试前的等待时间, 之后每次翻倍, 单位毫秒
+	Backoff int64 `json:",optional"`
+	// MaxBackoff 重试等待时间的上限, 单位毫秒
+	MaxBackoff int64 `json:",optional"`
+}
+
+// ConnPool 长连接池配置, 为0的项使用默认值
+type ConnPool struct {
+	// Size 每个后端预先建立的空闲连接数, 小于0表示不使用连接池
+	Size int `json:",optional"`
+	// MaxIdle 空闲连接的最长保留时间, 超过后关闭并重新建立, 单位秒
+	MaxIdle int64 `json:",optional"`
+	// CheckInterval 空闲连接健康检查的间隔, 单位秒
+	CheckInterval int64 `json:",optional"`
+	// SessionIdle 服务端会话的空闲超时, 池中连接已开始会话, 超过后即使连接可用会话也可能已被关闭, 单位秒
+	SessionIdle int64 `json:",optional"`
+	// MaxPools 连接池的最大数量, 超过时关闭最久未使用的连接池
+	MaxPools int `json:",optional"`
+	// PoolIdle 连接池未被使用的最长时间, 超过后关闭, 单位秒
+	PoolIdle int64 `json:",optional"`
+}
+
+// PhraseCache 固定话术的音频缓存
+type PhraseCache struct {
+	// Store 存储方式, disk本地磁盘, redis, none表示不缓存, 默认为disk
+	Store string `json:",optional"`
+	// Dir 本地磁盘的缓存目录, 默认为data/phrases
+	Dir string `json:",optional"`
+	// Expire redis中缓存的过期时间, 单位秒, 0表示不过期
+	Expire int64 `json:",optional"`
+	// Phrases 需要预先合成的话术, 由warm接口合成
+	Phrases []string `json:",optional"`
+}
+
+// Recording 会话录音, 开启后只录制同意录音的老人的会话
+type Recording struct {
+	Enable bool `json:",optional"`
+	// MaxSeconds 单轮录音的最长时长, 超出的部分不保存, 默认600秒
+	MaxSeconds int64 `json:",optional"`
+	Blob       Blob  `json:",optional"`
+}
+
+// Blob 录音等文件的存储
+type Blob struct {
+	// Type 存储方式, local本地目录, s3兼容S3协议的对象存储, 默认为local
+	Type string `json:",optional"`
+	// Dir 本地存储的目录, 默认为data/blob
+	Dir string `json:",optional"`
+	S3  S3     `json:",optional"`
+}
+
+// S3 兼容S3协议的对象存储
+type S3 struct {
+	// Endpoint 服务地址, 如https://s3.cn-north-1.amazonaws.com.cn
+	Endpoint  string `json:",optional"`
+	Region    string `json:",optional"`
+	Bucket    string `json:",optional"`
+	AccessKey string `json:",optional"`
+	SecretKey string `json:",optional"`
+	// PathStyle 使用路径形式的地址 endpoint/bucket/key, 否则为 bucket.endpoint/key
+	PathStyle bool `json:",optional"`
+}
+
+// VoiceStyle 根据老人与回复的情绪调整合成语音的风格
+// 情绪为sad, anxious, happy, neutral, 未配置的情绪使用内置的风格
+type VoiceStyle struct {
+	// Disable 关闭情绪风格, 始终使用默认的语音
+	Disable bool `json:",optional"`
+	// Default 各情绪的风格, key为情绪
+	Default map[string]Style `json:",optional"`
+	// Speakers 为指定音色单独配置风格, key为音色, 其中未配置的情绪使用Default
+	Speakers map[string]map[string]Style `json:",optional"`
+}
+
+// Style 一种语音风格
+type Style struct {
+	// Name 风格名称, 随对话记录保存
+	Name string `json:",optional"`
+	// Emotion 音色的情感, 如happy, sad, 需要音色支持多情感
+	Emotion string `json:",optional"`
+	// SpeechRate, PitchRate, Volume 语速, 音调, 音量的调整, 取值-50~100, 0为默认
+	SpeechRate int `json:",optional"`
+	PitchRate  int `json:",optional"`
+	Volume     int `json:",optional"`
+}
+
+// Segment 语音合成前的分句配置, 为0的项使用默认值
+type Segment struct {
+	// MinChars 句子的最少字数, 更短的句子与下一句合并
+	MinChars int `json:",optional"`
+	// MaxChars 句子的最多字数, 超过时在逗号等处切分
+	MaxChars int `json:",optional"`
+	// FlushTimeout 未凑成一句的文本等待该时间后直接合成, 单位毫秒
+	FlushTimeout int64 `json:",optional"`
+}
+
+// Health 就绪检查配置
+type Health struct {
+	// Probe 是否探测第三方模型服务的连通性
+	Probe bool `json:",optional"`
+	// ProbeCache 探测结果的缓存时间, 单位秒
+	ProbeCache int64 `json:",default=30"`
+	// Timeout 单项检查的超时时间, 单位毫秒
+	Timeout int64 `json:",default=2000"`
+}
+
+// Quota 用量额度配置, 各项为0表示不限制
+type Quota struct {
+	// Message 额度用尽时播报的提示语
+	Message     string    `json:",default=今天我们已经聊了很久啦，先休息一下，明天再来找我聊天吧。"`
+	App         QuotaRule `json:",optional"`
+	Institution QuotaRule `json:",optional"`
+	Senior      QuotaRule `json:",optional"`
+	// Overrides 为指定对象单独配置额度, key为 维度:id, 如 institution:xxx
+	Overrides map[string]QuotaRule `json:",optional"`
+}
+
+// QuotaRule 一个维度的日额度和月额度
+type QuotaRule struct {
+	Daily   QuotaLimit `json:",optional"`
+	Monthly QuotaLimit `json:",optional"`
+}
+
+// QuotaLimit 各类用量的上限
+type QuotaLimit struct {
+	Tokens     int64 `json:",optional"`
+	TtsChars   int64 `json:",optional"`
+	AsrSeconds int64 `json:",optional"`
 }
 
 func NewConfig() (*Config, error) {
@@ -99,6 +341,17 @@ func NewConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	// DevServer是可选项, 未配置时不会填充默认值, 按默认配置开启以暴露指标
+	if c.DevServer.Port == 0 {
+		c.DevServer = service.DevServerConfig{
+			Enabled:        true,
+			Port:           6060,
+			MetricsPath:    "/metrics",
+			HealthPath:     "/healthz",
+			EnableMetrics:  true,
+			HealthResponse: "OK",
+		}
+	}
 	err = c.SetUp()
 	if err != nil {
 		return nil, err
@@ -6,13 +6,82 @@ const (
 	StartTime  = "start_time"
 )
 
+// 对话报告的生成状态与由报告推算的风险等级
+const (
+	ReportDone   = "done"
+	ReportFailed = "failed"
+
+	RiskHigh   = "high"
+	RiskMedium = "medium"
+	RiskLow    = "low"
+)
+
 // Post http
 const (
 	Post = "POST"
 )
 
+// 对话角色与输入来源
+const (
+	RoleAi     = "ai"
+	RoleUser   = "user"
+	RoleSystem = "system"
+
+	SourceAsr  = "asr"
+	SourceText = "text"
+)
+
 // 默认值
 const (
 	EndCmd = -1
 	Ping   = 1
 )
+
+// 第三方服务标识, 用于指标与日志
+const (
+	BaiLian        = "bailian"
+	VolcTts        = "volc_tts"
+	VolcNoModelTts = "volc_nomodel_tts"
+	VolcAsr        = "volc_asr"
+	// Script 固定话术, 不经过大模型
+	Script = "script"
+)
+
+// 推送给前端的通知
+const (
+	// Notice 通知消息的类型, 用于与对话内容区分
+	Notice = "notice"
+	// EventTtsDegraded 语音合成不可用, 降级为纯文字
+	EventTtsDegraded = "tts_degraded"
+	// EventTtsRestored 语音合成已恢复
+	EventTtsRestored = "tts_restored"
+	// EventTtsReconnecting 语音合成连接断开, 正在重连, 期间的语音可能延迟
+	EventTtsReconnecting = "tts_reconnecting"
+	// EventTtsReconnected 语音合成重连成功
+	EventTtsReconnected = "tts_reconnected"
+	// EventAsrReconnecting 语音识别连接断开, 正在重连
+	EventAsrReconnecting = "asr_reconnecting"
+	// EventAsrReconnected 语音识别重连成功
+	EventAsrReconnected = "asr_reconnected"
+)
+
+// 语音识别结果的类型
+const (
+	// AsrPartial 中间结果, 同一句话之后还会更新
+	AsrPartial = "partial"
+	// AsrFinal 确定的结果
+	AsrFinal = "final"
+)
+
+// Preference 推送给前端的偏好消息的类型, 开始对话与偏好变化时下发
+const Preference = "preference"
+
+// 推送给前端的字幕
+const (
+	// Caption 字幕消息的类型
+	Caption = "caption"
+	// EventSentenceStart 一句话的音频开始
+	EventSentenceStart = "sentence_start"
+	// EventSentenceEnd 一句话的音频结束, 携带逐字的时间
+	EventSentenceEnd = "sentence_end"
+)
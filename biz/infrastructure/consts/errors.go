@@ -34,7 +34,11 @@ func NewErrno(code codes.Code, err error) *Errno {
 
 // 定义常量错误
 var (
-	ErrForbidden   = NewErrno(codes.PermissionDenied, errors.New("forbidden"))
-	ErrWsUpgrade   = NewErrno(codes.Code(1000), errors.New("websocket协议升级失败"))
-	ErrInvalidUser = NewErrno(codes.Code(1001), errors.New("非授权用户，请重试或切换账号"))
+	ErrForbidden     = NewErrno(codes.PermissionDenied, errors.New("forbidden"))
+	ErrWsUpgrade     = NewErrno(codes.Code(1000), errors.New("websocket协议升级失败"))
+	ErrInvalidUser   = NewErrno(codes.Code(1001), errors.New("非授权用户，请重试或切换账号"))
+	ErrQuotaExceeded = NewErrno(codes.Code(1002), errors.New("用量已达上限，请稍后再试"))
+	ErrInvalidParam  = NewErrno(codes.InvalidArgument, errors.New("参数错误"))
+	ErrNoRecording   = NewErrno(codes.NotFound, errors.New("没有对话录音"))
+	ErrNoHistory     = NewErrno(codes.NotFound, errors.New("对话记录不存在"))
 )
@@ -0,0 +1,60 @@
+package history
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidCursor 游标无法解析
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor 按开始时间倒序分页时上一页最后一条记录的位置, 开始时间相同时按id倒序
+type Cursor struct {
+	StartTime time.Time
+	ID        primitive.ObjectID
+}
+
+// NewCursor 记录之后的位置
+func NewCursor(h *History) *Cursor {
+	return &Cursor{StartTime: h.StartTime, ID: h.ID}
+}
+
+// Encode 编码为前端使用的字符串
+func (c *Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.StartTime.UnixMilli(), 10) + ":" + c.ID.Hex()))
+}
+
+// DecodeCursor 解析前端传回的游标
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ms, hex, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	milli, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{StartTime: time.UnixMilli(milli), ID: id}, nil
+}
+
+// after 游标之后的记录
+func (c *Cursor) after() bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"start_time": bson.M{"$lt": c.StartTime}},
+		bson.M{"start_time": c.StartTime, "_id": bson.M{"$lt": c.ID}},
+	}}
+}
@@ -5,15 +5,50 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type History struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Dialogs   []*Dialog          `bson:"dialogs" json:"dialogs"`
-	Report    *Report            `bson:"report" json:"report"`
-	StartTime time.Time          `bson:"start_time" json:"start_time"`
-	EndTime   time.Time          `bson:"end_time" json:"end_time"`
+	SessionId string             `bson:"session_id,omitempty" json:"session_id,omitempty"`
+	// SeniorId, InstitutionId 对话所属的老人与机构
+	SeniorId      string    `bson:"senior_id,omitempty" json:"senior_id,omitempty"`
+	InstitutionId string    `bson:"institution_id,omitempty" json:"institution_id,omitempty"`
+	Lang          string    `bson:"lang,omitempty" json:"lang,omitempty"`
+	Dialogs       []*Dialog `bson:"dialogs" json:"dialogs"`
+	Report        *Report   `bson:"report" json:"report"`
+	// ReportStatus 报告的生成状态, 早期的记录没有该字段, 均已生成报告
+	ReportStatus string `bson:"report_status,omitempty" json:"report_status,omitempty"`
+	// RiskLevel 由报告推算的风险等级
+	RiskLevel string `bson:"risk_level,omitempty" json:"risk_level,omitempty"`
+	// SearchText 对话内容切分后的检索词, 用于全文检索
+	SearchText string    `bson:"search_text,omitempty" json:"-"`
+	StartTime  time.Time `bson:"start_time" json:"start_time"`
+	EndTime    time.Time `bson:"end_time" json:"end_time"`
 }
 
 type Dialog struct {
-	Role    string `bson:"role" json:"role"`
-	Content string `bson:"content" json:"content"`
+	Role          string    `bson:"role" json:"role"`
+	Content       string    `bson:"content" json:"content"`
+	Timestamp     time.Time `bson:"timestamp,omitempty" json:"timestamp"`
+	Turn          int64     `bson:"turn" json:"turn"`
+	Provider      string    `bson:"provider,omitempty" json:"provider,omitempty"`
+	Model         string    `bson:"model,omitempty" json:"model,omitempty"`
+	PromptVersion string    `bson:"prompt_version,omitempty" json:"prompt_version,omitempty"`
+	// Source 用户输入的来源, asr或text
+	Source string `bson:"source,omitempty" json:"source,omitempty"`
+	// Raw 语音识别的原文, Content为规范化后的文本
+	Raw string `bson:"raw,omitempty" json:"raw,omitempty"`
+	// FirstToken, Duration, FirstAudio AI回复的耗时统计, 单位毫秒
+	FirstToken  int64  `bson:"first_token,omitempty" json:"first_token,omitempty"`
+	Duration    int64  `bson:"duration,omitempty" json:"duration,omitempty"`
+	FirstAudio  int64  `bson:"first_audio,omitempty" json:"first_audio,omitempty"`
+	Usage       *Usage `bson:"usage,omitempty" json:"usage,omitempty"`
+	Interrupted bool   `bson:"interrupted,omitempty" json:"interrupted,omitempty"`
+	// Emotion, VoiceStyle AI回复的情绪与合成语音使用的风格
+	Emotion    string `bson:"emotion,omitempty" json:"emotion,omitempty"`
+	VoiceStyle string `bson:"voice_style,omitempty" json:"voice_style,omitempty"`
+}
+
+// Usage 模型token用量
+type Usage struct {
+	InputTokens  int64 `bson:"input_tokens" json:"input_tokens"`
+	OutputTokens int64 `bson:"output_tokens" json:"output_tokens"`
 }
 
 //type Report struct {
@@ -1,16 +1,21 @@
 package history
 
 import (
+	"errors"
+	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-senior/biz/adaptor/cmd"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/util"
 	"github.com/zeromicro/go-zero/core/stores/monc"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/net/context"
 	"sync"
+	"time"
 )
 
 const (
@@ -21,9 +26,26 @@ const (
 var Mapper *MongoMapper
 var once sync.Once
 
+// backfillOnce 多个mapper共用同一集合, 每个进程只补充一次检索词
+var backfillOnce sync.Once
+
 type IMongoMapper interface {
-	Insert(ctx context.Context, his History) error
-	FindMany(ctx context.Context, p *cmd.Paging) (data []*History, total int64, err error)
+	Insert(ctx context.Context, his *History) error
+	FindOne(ctx context.Context, id string) (*History, error)
+	FindMany(ctx context.Context, f *Filter, p *cmd.Paging) (data []*History, total int64, err error)
+}
+
+// Filter 对话记录的筛选条件, 为空的条件不筛选
+type Filter struct {
+	SeniorId      string
+	InstitutionId string
+	// From, To 对话开始时间的范围, 包含From不包含To
+	From, To     time.Time
+	RiskLevel    string
+	EmotionTone  string
+	ReportStatus string
+	// Query 检索对话内容
+	Query string
 }
 
 type MongoMapper struct {
@@ -32,7 +54,10 @@ type MongoMapper struct {
 
 func NewMongoMapper(config *config.Config) *MongoMapper {
 	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.Cache)
-	return &MongoMapper{conn: conn}
+	m := &MongoMapper{conn: conn}
+	m.ensureIndexes()
+	backfillOnce.Do(func() { go m.backfill() })
+	return m
 }
 
 func GetMongoMapper() *MongoMapper {
@@ -42,33 +67,182 @@ func GetMongoMapper() *MongoMapper {
 		Mapper = &MongoMapper{
 			conn: conn,
 		}
+		Mapper.ensureIndexes()
+		backfillOnce.Do(func() { go Mapper.backfill() })
 	})
 	return Mapper
 }
 
+// Ping 检查mongo连接
+func (m *MongoMapper) Ping(ctx context.Context) error {
+	return m.conn.Database().Client().Ping(ctx, nil)
+}
+
+// ensureIndexes 创建查询使用的索引, 索引已存在时不会重复创建, 失败时只影响查询性能
+func (m *MongoMapper) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer cancel()
+	_, err := m.conn.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: consts.StartTime, Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "senior_id", Value: 1}, {Key: consts.StartTime, Value: -1}}},
+		{Keys: bson.D{{Key: "institution_id", Value: 1}, {Key: consts.StartTime, Value: -1}}},
+		// 检索词已切分, 不使用语言的词干与停用词
+		{Keys: bson.D{{Key: "search_text", Value: "text"}}, Options: options.Index().SetDefaultLanguage("none")},
+	})
+	if err != nil {
+		log.Error("create history index err:", err)
+	}
+}
+
+// backfill 为早期没有search_text的记录补充检索词, 补充完成之前这些记录不能被检索到
+// 没有对话内容的记录写入空的检索词, 避免重复处理
+func (m *MongoMapper) backfill() {
+	ctx, cancel := context.WithTimeout(context.Background(), backfillTimeout)
+	defer cancel()
+	var total int
+	for {
+		var batch []*History
+		limit := int64(backfillBatch)
+		err := m.conn.Find(ctx, &batch, bson.M{"search_text": bson.M{"$exists": false}}, &options.FindOptions{
+			Limit:      &limit,
+			Projection: bson.M{"dialogs": 1},
+		})
+		if err != nil {
+			log.Error("backfill history search text err:", err)
+			return
+		}
+		for _, h := range batch {
+			if _, err = m.conn.UpdateOneNoCache(ctx, bson.M{"_id": h.ID},
+				bson.M{"$set": bson.M{"search_text": searchText(h.Dialogs)}}); err != nil {
+				log.Error("backfill history search text err:", err)
+				return
+			}
+		}
+		total += len(batch)
+		if len(batch) < backfillBatch {
+			break
+		}
+	}
+	if total > 0 {
+		log.Info("backfill history search text:", total)
+	}
+}
+
 func (m *MongoMapper) Insert(ctx context.Context, his *History) error {
 	if his.ID.IsZero() {
 		his.ID = primitive.NewObjectID()
 	}
+	if his.SearchText == "" {
+		his.SearchText = searchText(his.Dialogs)
+	}
+	start := time.Now()
 	_, err := m.conn.InsertOneNoCache(ctx, his)
+	metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "insert", metrics.Result(err))
 	return err
 }
 
-func (m *MongoMapper) FindMany(ctx context.Context, p *cmd.Paging) (data []*History, total int64, err error) {
+// FindOne 按id查询对话记录, 不存在时返回nil
+func (m *MongoMapper) FindOne(ctx context.Context, id string) (h *History, err error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	start := time.Now()
+	defer func() {
+		metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "find_one", metrics.Result(err))
+	}()
+	h = new(History)
+	err = m.conn.FindOneNoCache(ctx, h, bson.M{"_id": oid})
+	if errors.Is(err, monc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// FindMany 按开始时间倒序查询对话记录, 不包含对话内容, 游标不为空时从游标之后开始
+// total为满足筛选条件的总数, 与游标无关
+func (m *MongoMapper) FindMany(ctx context.Context, f *Filter, p *cmd.Paging) (data []*History, total int64, err error) {
 	skip, limit := util.ParsePaging(p)
+	filter := f.query()
+	page := filter
+	if p.Cursor != "" {
+		c, err := DecodeCursor(p.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		skip, page = 0, bson.M{"$and": bson.A{filter, c.after()}}
+	}
 	data = make([]*History, 0, limit)
+	start := time.Now()
+	defer func() {
+		metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "find_many", metrics.Result(err))
+	}()
 	err = m.conn.Find(ctx, &data,
-		bson.M{}, &options.FindOptions{
-			Skip:  &skip,
-			Limit: &limit,
-			Sort:  bson.M{consts.StartTime: -1},
+		page, &options.FindOptions{
+			Skip:       &skip,
+			Limit:      &limit,
+			Sort:       bson.D{{Key: consts.StartTime, Value: -1}, {Key: "_id", Value: -1}},
+			Projection: bson.M{"dialogs": 0, "search_text": 0},
 		})
 	if err != nil {
 		return nil, 0, err
 	}
-	total, err = m.conn.CountDocuments(ctx, bson.M{})
+	total, err = m.conn.CountDocuments(ctx, filter)
 	if err != nil {
 		return nil, 0, err
 	}
 	return data, total, nil
 }
+
+// query 生成查询条件
+func (f *Filter) query() bson.M {
+	q := bson.M{}
+	if f == nil {
+		return q
+	}
+	if f.SeniorId != "" {
+		q["senior_id"] = f.SeniorId
+	}
+	if f.InstitutionId != "" {
+		q["institution_id"] = f.InstitutionId
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		r := bson.M{}
+		if !f.From.IsZero() {
+			r["$gte"] = f.From
+		}
+		if !f.To.IsZero() {
+			r["$lt"] = f.To
+		}
+		q[consts.StartTime] = r
+	}
+	if f.RiskLevel != "" {
+		q["risk_level"] = f.RiskLevel
+	}
+	if f.EmotionTone != "" {
+		q["report.overview_summary.emotion_tone"] = f.EmotionTone
+	}
+	switch f.ReportStatus {
+	case "":
+	case consts.ReportDone:
+		// 早期的记录没有生成状态, 均已生成报告
+		q["report_status"] = bson.M{"$ne": consts.ReportFailed}
+	default:
+		q["report_status"] = f.ReportStatus
+	}
+	if search := searchQuery(f.Query); search != "" {
+		q["$text"] = bson.M{"$search": search}
+	}
+	return q
+}
+
+const (
+	// indexTimeout 创建索引的超时时间
+	indexTimeout = 10 * time.Second
+	// backfillTimeout, backfillBatch 补充检索词的总超时时间与每批的数量
+	backfillTimeout = 30 * time.Minute
+	backfillBatch   = 200
+)
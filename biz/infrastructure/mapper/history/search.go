@@ -0,0 +1,78 @@
+package history
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// mongo的文本索引不能切分中文, 对话内容切分为检索词后保存在search_text中
+// 检索时同样切分, 每个词作为短语, 要求包含所有的词
+
+// SearchTerms 切分检索词, 汉字切分为单字与相邻的两字, 字母与数字按词切分并转为小写, 结果去重
+func SearchTerms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	add := func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	r := []rune(strings.ToLower(text))
+	for i := 0; i < len(r); {
+		switch {
+		case unicode.Is(unicode.Han, r[i]):
+			add(string(r[i]))
+			if i+1 < len(r) && unicode.Is(unicode.Han, r[i+1]) {
+				add(string(r[i : i+2]))
+			}
+			i++
+		case unicode.IsLetter(r[i]) || unicode.IsDigit(r[i]):
+			j := i
+			for j < len(r) && !unicode.Is(unicode.Han, r[j]) && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j])) {
+				j++
+			}
+			add(string(r[i:j]))
+			i = j
+		default:
+			i++
+		}
+	}
+	return terms
+}
+
+// searchText 对话内容的检索词
+func searchText(dialogs []*Dialog) string {
+	var sb strings.Builder
+	for _, d := range dialogs {
+		if d != nil {
+			sb.WriteString(d.Content)
+			sb.WriteString("\n")
+		}
+	}
+	return strings.Join(SearchTerms(sb.String()), " ")
+}
+
+// searchQuery 检索内容转为$text的查询, 检索词为两字时已包含其中的单字, 单字不再重复查询
+func searchQuery(query string) string {
+	terms := SearchTerms(query)
+	phrases := make([]string, 0, len(terms))
+	for i, t := range terms {
+		if r := []rune(t); len(r) == 1 && unicode.Is(unicode.Han, r[0]) && covered(terms, i) {
+			continue
+		}
+		phrases = append(phrases, strconv.Quote(t))
+	}
+	return strings.Join(phrases, " ")
+}
+
+// covered 单字是否包含在其他的两字词中
+func covered(terms []string, i int) bool {
+	for j, t := range terms {
+		if j != i && len([]rune(t)) == 2 && strings.Contains(t, terms[i]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSearchTerms(t *testing.T) {
+	got := SearchTerms("头疼，吃了Aspirin 2片。头疼")
+	want := []string{"头", "头疼", "疼", "吃", "吃了", "了", "aspirin", "2", "片"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchTerms = %q", got)
+	}
+	if got := searchQuery("头疼"); got != `"头疼"` {
+		t.Errorf("searchQuery = %s", got)
+	}
+	if got := searchQuery("疼"); got != `"疼"` {
+		t.Errorf("searchQuery = %s", got)
+	}
+	if got := searchQuery("，。"); got != "" {
+		t.Errorf("searchQuery = %s", got)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	c := &Cursor{StartTime: time.UnixMilli(1700000000123), ID: primitive.NewObjectID()}
+	got, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.StartTime.Equal(c.StartTime) || got.ID != c.ID {
+		t.Errorf("got %+v, want %+v", got, c)
+	}
+	for _, s := range []string{"", "bad", "MTIz"} {
+		if _, err = DecodeCursor(s); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) err = %v", s, err)
+		}
+	}
+}
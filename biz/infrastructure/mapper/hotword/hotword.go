@@ -0,0 +1,24 @@
+package hotword
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Hotword 老人的识别热词, 按来源分类保存, 识别时合并使用
+type Hotword struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SeniorId string             `bson:"senior_id" json:"senior_id"`
+	// Family 家人的名字与称呼
+	Family []string `bson:"family" json:"family"`
+	// Pets 宠物的名字
+	Pets []string `bson:"pets" json:"pets"`
+	// Places 常去的地名
+	Places []string `bson:"places" json:"places"`
+	// Medications 正在服用的药品
+	Medications []string `bson:"medications" json:"medications"`
+	// Others 其他词语
+	Others     []string  `bson:"others" json:"others"`
+	UpdateTime time.Time `bson:"update_time" json:"update_time"`
+}
@@ -0,0 +1,83 @@
+package hotword
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/context"
+)
+
+const (
+	prefixHotwordCacheKey = "cache:hotword:"
+	CollectionName        = "hotword"
+)
+
+var Mapper *MongoMapper
+var once sync.Once
+
+type IMongoMapper interface {
+	FindOne(ctx context.Context, seniorId string) (*Hotword, error)
+	Upsert(ctx context.Context, h *Hotword) error
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper(config *config.Config) *MongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.Cache)
+	return &MongoMapper{conn: conn}
+}
+
+func GetMongoMapper() *MongoMapper {
+	once.Do(func() {
+		c := config.GetConfig()
+		conn := monc.MustNewModel(c.Mongo.URL, c.Mongo.DB, CollectionName, c.Cache)
+		Mapper = &MongoMapper{
+			conn: conn,
+		}
+	})
+	return Mapper
+}
+
+// FindOne 查询老人的热词, 不存在时返回nil
+func (m *MongoMapper) FindOne(ctx context.Context, seniorId string) (h *Hotword, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "find_one", metrics.Result(err))
+	}()
+	h = new(Hotword)
+	err = m.conn.FindOne(ctx, prefixHotwordCacheKey+seniorId, h, bson.M{"senior_id": seniorId})
+	if errors.Is(err, monc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Upsert 整体保存老人的热词, 不存在时创建
+func (m *MongoMapper) Upsert(ctx context.Context, h *Hotword) error {
+	h.UpdateTime = time.Now()
+	start := time.Now()
+	_, err := m.conn.UpdateOne(ctx, prefixHotwordCacheKey+h.SeniorId,
+		bson.M{"senior_id": h.SeniorId},
+		bson.M{"$set": bson.M{
+			"family":      h.Family,
+			"pets":        h.Pets,
+			"places":      h.Places,
+			"medications": h.Medications,
+			"others":      h.Others,
+			"update_time": h.UpdateTime,
+		}},
+		options.Update().SetUpsert(true))
+	metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "upsert", metrics.Result(err))
+	return err
+}
@@ -0,0 +1,97 @@
+package preference
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/context"
+)
+
+const (
+	prefixPreferenceCacheKey = "cache:preference:"
+	CollectionName           = "preference"
+)
+
+var Mapper *MongoMapper
+var once sync.Once
+
+type IMongoMapper interface {
+	FindOne(ctx context.Context, seniorId string) (*Preference, error)
+	Upsert(ctx context.Context, p *Preference) error
+	UpsertRecordConsent(ctx context.Context, seniorId string, consent bool) error
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper(config *config.Config) *MongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.Cache)
+	return &MongoMapper{conn: conn}
+}
+
+func GetMongoMapper() *MongoMapper {
+	once.Do(func() {
+		c := config.GetConfig()
+		conn := monc.MustNewModel(c.Mongo.URL, c.Mongo.DB, CollectionName, c.Cache)
+		Mapper = &MongoMapper{
+			conn: conn,
+		}
+	})
+	return Mapper
+}
+
+// FindOne 查询老人的偏好, 不存在时返回nil
+func (m *MongoMapper) FindOne(ctx context.Context, seniorId string) (p *Preference, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "find_one", metrics.Result(err))
+	}()
+	p = new(Preference)
+	err = m.conn.FindOne(ctx, prefixPreferenceCacheKey+seniorId, p, bson.M{"senior_id": seniorId})
+	if errors.Is(err, monc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Upsert 保存老人的语音与显示偏好, 不存在时创建, 不修改是否同意录音
+func (m *MongoMapper) Upsert(ctx context.Context, p *Preference) error {
+	p.UpdateTime = time.Now()
+	start := time.Now()
+	_, err := m.conn.UpdateOne(ctx, prefixPreferenceCacheKey+p.SeniorId,
+		bson.M{"senior_id": p.SeniorId},
+		bson.M{"$set": bson.M{
+			"speaker":     p.Speaker,
+			"speech_rate": p.SpeechRate,
+			"volume":      p.Volume,
+			"text_size":   p.TextSize,
+			"update_time": p.UpdateTime,
+		}},
+		options.Update().SetUpsert(true))
+	metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "upsert", metrics.Result(err))
+	return err
+}
+
+// UpsertRecordConsent 只保存老人是否同意录音, 不存在时创建
+func (m *MongoMapper) UpsertRecordConsent(ctx context.Context, seniorId string, consent bool) error {
+	start := time.Now()
+	_, err := m.conn.UpdateOne(ctx, prefixPreferenceCacheKey+seniorId,
+		bson.M{"senior_id": seniorId},
+		bson.M{"$set": bson.M{
+			"record_consent": consent,
+			"update_time":    start,
+		}},
+		options.Update().SetUpsert(true))
+	metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "upsert_record_consent", metrics.Result(err))
+	return err
+}
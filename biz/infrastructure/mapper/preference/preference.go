@@ -0,0 +1,23 @@
+package preference
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Preference 老人的语音与显示偏好, 各项为0或空时使用默认值
+type Preference struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SeniorId string             `bson:"senior_id" json:"senior_id"`
+	// Speaker 偏好的音色
+	Speaker string `bson:"speaker,omitempty" json:"speaker,omitempty"`
+	// SpeechRate, Volume 语速与音量的调整, 取值-50~100
+	SpeechRate int `bson:"speech_rate" json:"speech_rate"`
+	Volume     int `bson:"volume" json:"volume"`
+	// TextSize 字号的调整级别, 由前端换算为实际字号
+	TextSize int `bson:"text_size" json:"text_size"`
+	// RecordConsent 是否同意保存对话录音
+	RecordConsent bool      `bson:"record_consent" json:"record_consent"`
+	UpdateTime    time.Time `bson:"update_time" json:"update_time"`
+}
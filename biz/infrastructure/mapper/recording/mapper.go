@@ -0,0 +1,77 @@
+package recording
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/context"
+)
+
+const (
+	CollectionName = "recording"
+)
+
+var Mapper *MongoMapper
+var once sync.Once
+
+type IMongoMapper interface {
+	Insert(ctx context.Context, r *Recording) error
+	// FindMany 按开始时间查询一次会话的录音, side为空时包含双方, turn小于0时包含所有轮次
+	FindMany(ctx context.Context, sessionId, side string, turn int64) ([]*Recording, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper(config *config.Config) *MongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.Cache)
+	return &MongoMapper{conn: conn}
+}
+
+func GetMongoMapper() *MongoMapper {
+	once.Do(func() {
+		c := config.GetConfig()
+		conn := monc.MustNewModel(c.Mongo.URL, c.Mongo.DB, CollectionName, c.Cache)
+		Mapper = &MongoMapper{
+			conn: conn,
+		}
+	})
+	return Mapper
+}
+
+func (m *MongoMapper) Insert(ctx context.Context, r *Recording) error {
+	if r.ID.IsZero() {
+		r.ID = primitive.NewObjectID()
+	}
+	start := time.Now()
+	_, err := m.conn.InsertOneNoCache(ctx, r)
+	metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "insert", metrics.Result(err))
+	return err
+}
+
+func (m *MongoMapper) FindMany(ctx context.Context, sessionId, side string, turn int64) (data []*Recording, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "find_many", metrics.Result(err))
+	}()
+	filter := bson.M{"session_id": sessionId}
+	if side != "" {
+		filter["side"] = side
+	}
+	if turn >= 0 {
+		filter["turn"] = turn
+	}
+	data = make([]*Recording, 0)
+	err = m.conn.Find(ctx, &data, filter, &options.FindOptions{Sort: bson.D{{Key: "start", Value: 1}}})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
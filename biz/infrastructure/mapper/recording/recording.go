@@ -0,0 +1,29 @@
+package recording
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Recording 一段录音, 一方在一轮对话中的音频, 音频文件保存在blob存储中
+type Recording struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	SessionId string             `bson:"session_id" json:"session_id"`
+	SeniorId  string             `bson:"senior_id,omitempty" json:"senior_id,omitempty"`
+	// Side 说话的一方, user老人, ai回复
+	Side string `bson:"side" json:"side"`
+	// Turn 对话轮次, 与对话记录的轮次对应
+	Turn int64 `bson:"turn" json:"turn"`
+	// Key 音频文件在blob存储中的路径
+	Key string `bson:"key" json:"key"`
+	// Format wav或ogg, wav时为pcm16
+	Format     string `bson:"format" json:"format"`
+	SampleRate int    `bson:"sample_rate,omitempty" json:"sample_rate,omitempty"`
+	Channels   int    `bson:"channels,omitempty" json:"channels,omitempty"`
+	// Size 音频文件的字节数
+	Size int64 `bson:"size" json:"size"`
+	// Start, End 音频开始与结束的时间, 与对话记录的时间对齐
+	Start time.Time `bson:"start" json:"start"`
+	End   time.Time `bson:"end" json:"end"`
+}
@@ -0,0 +1,80 @@
+package usage
+
+import (
+	"github.com/xh-polaris/psych-senior/biz/application/dto"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/context"
+	"sync"
+	"time"
+)
+
+const (
+	CollectionName = "usage"
+)
+
+var Mapper *MongoMapper
+var once sync.Once
+
+type IMongoMapper interface {
+	Inc(ctx context.Context, scope, ownerId, date string, stat *dto.UsageStat) error
+	FindRange(ctx context.Context, scope, ownerId, from, to string) ([]*Usage, error)
+}
+
+type MongoMapper struct {
+	conn *monc.Model
+}
+
+func NewMongoMapper(config *config.Config) *MongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.Cache)
+	return &MongoMapper{conn: conn}
+}
+
+func GetMongoMapper() *MongoMapper {
+	once.Do(func() {
+		c := config.GetConfig()
+		conn := monc.MustNewModel(c.Mongo.URL, c.Mongo.DB, CollectionName, c.Cache)
+		Mapper = &MongoMapper{
+			conn: conn,
+		}
+	})
+	return Mapper
+}
+
+// Inc 累加某个对象某天的用量, 不存在时创建
+func (m *MongoMapper) Inc(ctx context.Context, scope, ownerId, date string, stat *dto.UsageStat) error {
+	start := time.Now()
+	_, err := m.conn.UpdateOneNoCache(ctx,
+		bson.M{"scope": scope, "owner_id": ownerId, "date": date},
+		bson.M{
+			"$inc": bson.M{
+				"input_tokens":  stat.InputTokens,
+				"output_tokens": stat.OutputTokens,
+				"tts_chars":     stat.TtsChars,
+				"asr_millis":    stat.AsrMillis,
+			},
+			"$set": bson.M{"update_time": time.Now()},
+		},
+		options.Update().SetUpsert(true))
+	metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "inc", metrics.Result(err))
+	return err
+}
+
+// FindRange 查询某个对象在[from, to]日期内的每日用量
+func (m *MongoMapper) FindRange(ctx context.Context, scope, ownerId, from, to string) (data []*Usage, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.MongoOp.Observe(metrics.Since(start), CollectionName, "find_range", metrics.Result(err))
+	}()
+	data = make([]*Usage, 0)
+	err = m.conn.Find(ctx, &data,
+		bson.M{"scope": scope, "owner_id": ownerId, "date": bson.M{"$gte": from, "$lte": to}},
+		&options.FindOptions{Sort: bson.M{"date": 1}})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
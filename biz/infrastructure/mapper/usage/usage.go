@@ -0,0 +1,19 @@
+package usage
+
+import "time"
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Usage 某个对象一天内的用量汇总
+type Usage struct {
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	// Scope 统计维度, app, institution或senior
+	Scope   string `bson:"scope" json:"scope"`
+	OwnerId string `bson:"owner_id" json:"owner_id"`
+	// Date 统计日期, 格式为 2006-01-02
+	Date         string    `bson:"date" json:"date"`
+	InputTokens  int64     `bson:"input_tokens" json:"input_tokens"`
+	OutputTokens int64     `bson:"output_tokens" json:"output_tokens"`
+	TtsChars     int64     `bson:"tts_chars" json:"tts_chars"`
+	AsrMillis    int64     `bson:"asr_millis" json:"asr_millis"`
+	UpdateTime   time.Time `bson:"update_time" json:"update_time"`
+}
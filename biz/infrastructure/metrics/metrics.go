@@ -0,0 +1,192 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/zeromicro/go-zero/core/metric"
+)
+
+// 指标通过go-zero的DevServer暴露, 默认路径为 :6060/metrics, 未配置DevServer时使用默认配置开启
+
+const namespace = "psych_senior"
+
+// 延迟类指标的分桶, 单位毫秒
+var latencyBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 750, 1000, 1500, 2000, 3000, 5000, 10000}
+
+// 报表生成耗时的分桶, 单位毫秒
+var reportBuckets = []float64{1000, 2000, 5000, 10000, 20000, 30000, 60000, 120000}
+
+var (
+	// ChatSessionActive 当前活跃的对话数
+	ChatSessionActive = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: "chat",
+		Name:      "sessions_active",
+		Help:      "number of active chat sessions",
+		Labels:    []string{"provider", "lang"},
+	})
+
+	// AsrSessionActive 当前活跃的语音识别连接数
+	AsrSessionActive = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: "asr",
+		Name:      "sessions_active",
+		Help:      "number of active asr sessions",
+		Labels:    []string{"provider", "lang"},
+	})
+
+	// ChatFirstToken 每轮对话从发起调用到获得首个token的耗时
+	ChatFirstToken = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: "chat",
+		Name:      "first_token_ms",
+		Help:      "time to first token of each turn in milliseconds",
+		Labels:    []string{"provider", "lang"},
+		Buckets:   latencyBuckets,
+	})
+
+	// ChatFirstAudio 每轮对话从发起调用到获得首个音频包的耗时
+	ChatFirstAudio = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: "chat",
+		Name:      "first_audio_ms",
+		Help:      "time to first audio of each turn in milliseconds",
+		Labels:    []string{"provider", "lang"},
+		Buckets:   latencyBuckets,
+	})
+
+	// TtsError 语音合成错误数
+	TtsError = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "tts",
+		Name:      "errors_total",
+		Help:      "tts error count",
+		Labels:    []string{"provider", "lang"},
+	})
+
+	// AsrError 语音识别错误数
+	AsrError = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "asr",
+		Name:      "errors_total",
+		Help:      "asr error count",
+		Labels:    []string{"provider", "lang"},
+	})
+
+	// BreakerState 各后端熔断器的状态, 0关闭, 1打开, 2半开
+	BreakerState = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: "breaker",
+		Name:      "state",
+		Help:      "circuit breaker state of each backend, 0 closed, 1 open, 2 half open",
+		Labels:    []string{"backend"},
+	})
+
+	// Failover 切换到备用后端的次数
+	Failover = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "failover",
+		Name:      "total",
+		Help:      "count of switching away from a failed backend",
+		Labels:    []string{"slot", "backend"},
+	})
+
+	// Reconnect 语音合成与识别的连接断开后的重连次数
+	Reconnect = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "reconnect",
+		Name:      "total",
+		Help:      "count of reconnecting after losing a speech connection",
+		Labels:    []string{"slot", "result"},
+	})
+
+	// PoolIdle 连接池中的空闲连接数
+	PoolIdle = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: namespace,
+		Subsystem: "pool",
+		Name:      "idle",
+		Help:      "idle connections in each pool",
+		Labels:    []string{"backend"},
+	})
+
+	// PoolGet 从连接池取用连接的次数, 没有空闲连接时为miss
+	PoolGet = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "pool",
+		Name:      "get_total",
+		Help:      "count of getting connections from pools",
+		Labels:    []string{"backend", "result"},
+	})
+
+	// MqPublish 消息发布耗时
+	MqPublish = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: "mq",
+		Name:      "publish_ms",
+		Help:      "mq publish latency in milliseconds",
+		Labels:    []string{"exchange", "result"},
+		Buckets:   latencyBuckets,
+	})
+
+	// MqConsume 消息从发布到投递给消费者的耗时, 不包含处理消息的耗时
+	MqConsume = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: "mq",
+		Name:      "consume_ms",
+		Help:      "mq latency between publish and delivery in milliseconds",
+		Labels:    []string{"queue"},
+		Buckets:   latencyBuckets,
+	})
+
+	// ReportDuration 报表生成耗时
+	ReportDuration = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: "report",
+		Name:      "duration_ms",
+		Help:      "report generation duration in milliseconds",
+		Labels:    []string{"provider"},
+		Buckets:   reportBuckets,
+	})
+
+	// ReportFailure 报表生成失败数
+	ReportFailure = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: namespace,
+		Subsystem: "report",
+		Name:      "failures_total",
+		Help:      "report generation failure count",
+		Labels:    []string{"provider"},
+	})
+
+	// RedisOp redis操作耗时
+	RedisOp = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "op_ms",
+		Help:      "redis operation latency in milliseconds",
+		Labels:    []string{"op", "result"},
+		Buckets:   latencyBuckets,
+	})
+
+	// MongoOp mongo操作耗时
+	MongoOp = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "op_ms",
+		Help:      "mongo operation latency in milliseconds",
+		Labels:    []string{"collection", "op", "result"},
+		Buckets:   latencyBuckets,
+	})
+)
+
+// Since 返回距离start的毫秒数
+func Since(start time.Time) int64 {
+	return time.Since(start).Milliseconds()
+}
+
+// Result 将错误转换为指标标签
+func Result(err error) string {
+	if err != nil {
+		return "fail"
+	}
+	return "ok"
+}
@@ -0,0 +1,37 @@
+package mq
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// sentAtHeader 消息的发送时间, unix毫秒
+const sentAtHeader = "x-sent-at"
+
+var _ propagation.TextMapCarrier = (*headerCarrier)(nil)
+
+// headerCarrier 通过amqp消息头传递追踪信息
+type headerCarrier amqp.Table
+
+// Get 获取消息头
+func (c headerCarrier) Get(key string) string {
+	v, ok := c[key].(string)
+	if !ok {
+		return ""
+	}
+	return v
+}
+
+// Set 设置消息头
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys 获取所有消息头
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -7,8 +7,14 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-senior/biz/domain"
-	"github.com/xh-polaris/psych-senior/biz/domain/model/bailian"
+	"github.com/xh-polaris/psych-senior/biz/domain/language"
+	"github.com/xh-polaris/psych-senior/biz/domain/model/failover"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/consts"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/mapper/history"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/trace"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 	"os"
 	"os/signal"
@@ -74,7 +80,12 @@ func (c *HistoryConsumer) consume(ctx context.Context) {
 	}
 
 	for msg := range msgs {
-		if err = c.process(ctx, msg); err != nil {
+		// 统计消息从发布到投递的耗时, 报表生成的耗时由ReportDuration统计
+		if sent, ok := sentAt(msg); ok {
+			metrics.MqConsume.Observe(metrics.Since(sent), "chat_history_senior")
+		}
+		err = c.process(ctx, msg)
+		if err != nil {
 			// 失败时拒绝并重试
 			log.Error("处理失败，消息重新入队:", err)
 			if err = msg.Nack(false, true); err != nil {
@@ -86,6 +97,15 @@ func (c *HistoryConsumer) consume(ctx context.Context) {
 	}
 }
 
+// sentAt 消息头中的发送时间, 旧版本发布的消息没有
+func sentAt(msg amqp.Delivery) (time.Time, bool) {
+	ms, ok := msg.Headers[sentAtHeader].(int64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
 // osSignalHandler 处理os信号
 func (c *HistoryConsumer) osSignalHandler(ctx context.Context) {
 	log.CtxInfo(ctx, "[osSignalHandler] start")
@@ -96,18 +116,27 @@ func (c *HistoryConsumer) osSignalHandler(ctx context.Context) {
 }
 
 // process 实际消费逻辑
-func (c *HistoryConsumer) process(ctx context.Context, msg amqp.Delivery) error {
+func (c *HistoryConsumer) process(ctx context.Context, msg amqp.Delivery) (err error) {
+	// 恢复生产者传递的追踪信息
+	ctx = trace.Extract(ctx, headerCarrier(msg.Headers))
+	ctx, span := trace.StartWithKind(ctx, "mq.consume", oteltrace.SpanKindConsumer)
+	defer func() { trace.End(span, err) }()
+
 	var m map[string]interface{}
-	if err := json.Unmarshal(msg.Body, &m); err != nil {
+	if err = json.Unmarshal(msg.Body, &m); err != nil {
 		return err
 	}
 
 	session := m["sessionId"].(string)
+	lang, _ := m["lang"].(string)
+	seniorId, _ := m["seniorId"].(string)
+	institutionId, _ := m["institutionId"].(string)
 	start := int64(m["start"].(float64))
 	end := int64(m["end"].(float64))
+	span.SetAttributes(attribute.String("session_id", session))
 
 	rs := domain.GetRedisHelper()
-	histories, err := rs.Load(session)
+	histories, err := rs.Load(ctx, session)
 	if err != nil {
 		return err
 	}
@@ -115,22 +144,53 @@ func (c *HistoryConsumer) process(ctx context.Context, msg amqp.Delivery) error
 	dialogs := make([]*history.Dialog, 0, len(histories))
 	for _, his := range histories {
 		dia := &history.Dialog{
-			Role:    his.Role,
-			Content: his.Content,
+			Role:          his.Role,
+			Content:       his.Content,
+			Turn:          his.Turn,
+			Provider:      his.Provider,
+			Model:         his.Model,
+			PromptVersion: his.PromptVersion,
+			Source:        his.Source,
+			Raw:           his.Raw,
+			FirstToken:    his.FirstToken,
+			Duration:      his.Duration,
+			FirstAudio:    his.FirstAudio,
+			Interrupted:   his.Interrupted,
+			Emotion:       his.Emotion,
+			VoiceStyle:    his.VoiceStyle,
+		}
+		if his.Timestamp > 0 {
+			dia.Timestamp = time.UnixMilli(his.Timestamp)
+		}
+		if his.Usage != nil {
+			dia.Usage = &history.Usage{
+				InputTokens:  his.Usage.InputTokens,
+				OutputTokens: his.Usage.OutputTokens,
+			}
 		}
 		dialogs = append(dialogs, dia)
 	}
 	his := &history.History{
-		Dialogs:   dialogs,
-		StartTime: time.Unix(start, 0),
-		EndTime:   time.Unix(end, 0),
-		Report:    &history.Report{},
+		SessionId:     session,
+		SeniorId:      seniorId,
+		InstitutionId: institutionId,
+		Lang:          lang,
+		Dialogs:       dialogs,
+		StartTime:     time.Unix(start, 0),
+		EndTime:       time.Unix(end, 0),
+		Report:        &history.Report{},
 	}
 
 	// 解析对话消息
 	if len(dialogs) > 0 {
-		if err = parse(his); err != nil {
-			return err
+		if err = parse(ctx, his, reportPrompt(lang)); err != nil {
+			// 重试后仍失败时保存没有报告的对话记录, 避免消息无限重试
+			if !msg.Redelivered {
+				return err
+			}
+			his.ReportStatus = consts.ReportFailed
+		} else {
+			his.ReportStatus, his.RiskLevel = consts.ReportDone, riskLevel(his.Report)
 		}
 		// 存储对话记录
 		if err = c.store(ctx, his); err != nil {
@@ -138,17 +198,31 @@ func (c *HistoryConsumer) process(ctx context.Context, msg amqp.Delivery) error
 		}
 	}
 	// 从redis中删除
-	if err = rs.Remove(session); err != nil {
+	if err = rs.Remove(ctx, session); err != nil {
 		return err
 	}
 	return nil
 }
 
-// parse 解析对话信息
-func parse(his *history.History) error {
-	reportApp := bailian.GetBLReportApp()
-	report, err := reportApp.Call(buildMsg(his))
+// reportPrompt 对话语言的报告提示, 未配置或语言不支持时为空
+func reportPrompt(lang string) string {
+	if l, ok := language.GetRegistry().Get(lang); ok {
+		return l.ReportPrompt
+	}
+	return ""
+}
+
+// parse 解析对话信息, prompt不为空时附加在对话记录前
+func parse(ctx context.Context, his *history.History, prompt string) (err error) {
+	ctx, span := trace.Start(ctx, "report.call", attribute.String("provider", consts.BaiLian))
+	defer func() { trace.End(span, err) }()
+
+	reportApp := failover.GetReportApp()
+	start := time.Now()
+	report, err := reportApp.Call(ctx, buildMsg(his, prompt))
+	metrics.ReportDuration.Observe(metrics.Since(start), consts.BaiLian)
 	if err != nil {
+		metrics.ReportFailure.Inc(consts.BaiLian)
 		log.Error("call build error:", err)
 		return err
 	}
@@ -159,9 +233,26 @@ func parse(his *history.History) error {
 	return err
 }
 
+// riskLevel 由报告推算风险等级, 健康风险或认知异常为高, 孤独或重大生活事件为中
+func riskLevel(r *history.Report) string {
+	h, s := r.DetailedAnalysis.HealthFocus, r.DetailedAnalysis.PsychologicalSignals
+	switch {
+	case h.HealthRiskAlert.Exists || s.CognitiveSignalsDetected.Exists:
+		return consts.RiskHigh
+	case s.LonelinessDetected.Exists || s.MajorLifeEventsMentioned.Exists:
+		return consts.RiskMedium
+	default:
+		return consts.RiskLow
+	}
+}
+
 // buildMsg 拼接消息
-func buildMsg(his *history.History) string {
+func buildMsg(his *history.History, prompt string) string {
 	var sb strings.Builder
+	if prompt != "" {
+		sb.WriteString(prompt)
+		sb.WriteString("\n")
+	}
 	for _, h := range his.Dialogs {
 		sb.WriteString(h.Role)
 		sb.WriteString(":")
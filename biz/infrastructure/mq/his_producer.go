@@ -2,22 +2,34 @@ package mq
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/domain/usage"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/trace"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/util"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// conn 采用单例模式, 复用连接
+// conn 采用单例模式, 复用连接, 重连时由monitor替换
 var (
 	conn *amqp.Connection
 	once sync.Once
 	url  string
+	// connMu 保护conn与producer, 二者在重连时被替换
+	connMu sync.RWMutex
+
+	// reconnecting 是否处于重连状态, 用于就绪检查
+	reconnecting atomic.Bool
 )
 
 // getConn 获取连接单例
@@ -29,18 +41,28 @@ func getConn() *amqp.Connection {
 		if err != nil {
 			util.FailOnError("rabbit mq connect failed", err)
 		}
+		connMu.Lock()
 		conn = c
+		connMu.Unlock()
 		// 自动重连监听
-		go monitor()
+		go monitor(c)
 	})
+	return current()
+}
+
+// current 获取当前的连接
+func current() *amqp.Connection {
+	connMu.RLock()
+	defer connMu.RUnlock()
 	return conn
 }
 
-// monitor 监听健康状态并重连
-func monitor() {
+// monitor 监听健康状态并重连, 重连后重新打开生产者的channel
+func monitor(c *amqp.Connection) {
 	for {
-		reason := <-conn.NotifyClose(make(chan *amqp.Error))
+		reason := <-c.NotifyClose(make(chan *amqp.Error))
 		log.Info("RabbitMQ connection closed , reason: ", reason)
+		reconnecting.Store(true)
 
 		retries := 0
 		for {
@@ -48,7 +70,17 @@ func monitor() {
 
 			newConn, err := amqp.Dial(url)
 			if err == nil {
+				c = newConn
+				connMu.Lock()
 				conn = newConn
+				p := producer
+				connMu.Unlock()
+				if p != nil {
+					if err = p.reopen(newConn); err != nil {
+						log.Error("reopen producer channel err:", err)
+					}
+				}
+				reconnecting.Store(false)
 				log.Info("Reconnect to RabbitMQ")
 				break
 			}
@@ -61,6 +93,24 @@ func monitor() {
 	}
 }
 
+// Healthy 检查RabbitMQ的连接状态, 重连中或连接不可用时返回错误
+func Healthy() error {
+	connMu.RLock()
+	c, p := conn, producer
+	connMu.RUnlock()
+	switch {
+	case c == nil:
+		return errors.New("rabbitmq not connected")
+	case reconnecting.Load():
+		return errors.New("rabbitmq reconnecting")
+	case c.IsClosed():
+		return errors.New("rabbitmq connection closed")
+	case p != nil && p.closed():
+		return errors.New("rabbitmq producer channel closed")
+	}
+	return nil
+}
+
 var (
 	producer     *HistoryProducer
 	producerOnce sync.Once
@@ -68,6 +118,7 @@ var (
 
 // HistoryProducer 历史记录生产者
 type HistoryProducer struct {
+	// mu 保护channel, 发布与重新打开互斥
 	mu      sync.Mutex
 	conn    *amqp.Connection
 	channel *amqp.Channel
@@ -81,21 +132,51 @@ func GetHistoryProducer() *HistoryProducer {
 		if err != nil {
 			util.FailOnError("create channel failed", err)
 		}
+		connMu.Lock()
 		producer = &HistoryProducer{
 			conn:    c,
 			channel: ch,
 		}
+		connMu.Unlock()
 	})
+	connMu.RLock()
+	defer connMu.RUnlock()
 	return producer
 }
 
-// Produce 创建历史记录消息
-func (p *HistoryProducer) Produce(ctx context.Context, sessionId string, start, end time.Time) error {
+// reopen 在新的连接上重新打开channel
+func (p *HistoryProducer) reopen(c *amqp.Connection) error {
+	ch, err := c.Channel()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conn, p.channel = c, ch
+	return nil
+}
+
+// closed channel是否已关闭
+func (p *HistoryProducer) closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.channel.IsClosed()
+}
+
+// Produce 创建历史记录消息, lang为对话的语言, 用于选择报告的提示, owner为对话所属的老人与机构
+func (p *HistoryProducer) Produce(ctx context.Context, sessionId, lang string, owner usage.Owner, start, end time.Time) (err error) {
+	ctx, span := trace.StartWithKind(ctx, "mq.publish", oteltrace.SpanKindProducer,
+		attribute.String("session_id", sessionId))
+	defer func() { trace.End(span, err) }()
+
 	// 构造消息体
 	msg := map[string]interface{}{
-		"sessionId": sessionId,
-		"start":     start.Unix(),
-		"end":       end.Unix(),
+		"sessionId":     sessionId,
+		"lang":          lang,
+		"seniorId":      owner.SeniorId,
+		"institutionId": owner.InstitutionId,
+		"start":         start.Unix(),
+		"end":           end.Unix(),
 	}
 
 	body, err := json.Marshal(msg)
@@ -103,15 +184,32 @@ func (p *HistoryProducer) Produce(ctx context.Context, sessionId string, start,
 		return err
 	}
 
+	// 在消息头中携带追踪信息, 使消费者的span与本次会话关联
+	headers := amqp.Table{}
+	trace.Inject(ctx, headerCarrier(headers))
+
+	// channel单独关闭时在当前连接上重新打开
+	if p.closed() {
+		if c := current(); c != nil && !c.IsClosed() {
+			if err = p.reopen(c); err != nil {
+				return err
+			}
+		}
+	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	// 发布持久化消息
+	// 发布持久化消息, 消息头携带毫秒精度的发送时间, amqp的Timestamp只精确到秒
+	now := time.Now()
+	headers[sentAtHeader] = now.UnixMilli()
 	err = p.channel.PublishWithContext(ctx, "chat_history_senior", "history.senior.end",
 		false, false,
 		amqp.Publishing{
 			DeliveryMode: amqp.Persistent,
 			ContentType:  "application/json",
+			Headers:      headers,
+			Timestamp:    now,
 			Body:         body,
 		})
+	metrics.MqPublish.Observe(metrics.Since(now), "chat_history_senior", metrics.Result(err))
 	return err
 }
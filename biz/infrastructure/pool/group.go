@@ -0,0 +1,107 @@
+package pool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+// 未配置时的默认值
+const (
+	defaultMaxPools = 32
+	defaultPoolIdle = 600
+)
+
+// Group 按名称管理多个连接池
+// 连接池按后端, 音色与编码区分, 组合较多, 因此限制连接池的数量并关闭长时间未使用的连接池
+type Group[T Conn] struct {
+	c        config.ConnPool
+	maxPools int
+	poolIdle time.Duration
+
+	mu    sync.Mutex
+	pools map[string]*member[T]
+}
+
+type member[T Conn] struct {
+	pool *Pool[T]
+	used time.Time
+}
+
+// NewGroup 创建连接池组并在后台关闭长时间未使用的连接池
+func NewGroup[T Conn](c config.ConnPool) *Group[T] {
+	g := &Group[T]{
+		c:        c,
+		maxPools: c.MaxPools,
+		poolIdle: time.Duration(c.PoolIdle) * time.Second,
+		pools:    make(map[string]*member[T]),
+	}
+	if g.maxPools <= 0 {
+		g.maxPools = defaultMaxPools
+	}
+	if g.poolIdle <= 0 {
+		g.poolIdle = defaultPoolIdle * time.Second
+	}
+	interval := time.Duration(c.CheckInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultCheckInterval * time.Second
+	}
+	go g.maintain(interval)
+	return g
+}
+
+// Pool 获取名称对应的连接池, 不存在时创建, 数量超过上限时关闭最久未使用的连接池
+func (g *Group[T]) Pool(name string, new func() T) *Pool[T] {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	m, ok := g.pools[name]
+	if !ok {
+		for len(g.pools) >= g.maxPools {
+			g.evict()
+		}
+		m = &member[T]{pool: New(name, new, g.c)}
+		g.pools[name] = m
+	}
+	m.used = time.Now()
+	return m.pool
+}
+
+// Len 当前连接池的数量
+func (g *Group[T]) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.pools)
+}
+
+// evict 关闭最久未使用的连接池, 调用方需持有锁
+func (g *Group[T]) evict() {
+	var oldest string
+	for name, m := range g.pools {
+		if oldest == "" || m.used.Before(g.pools[oldest].used) {
+			oldest = name
+		}
+	}
+	go g.pools[oldest].pool.Close()
+	delete(g.pools, oldest)
+}
+
+// maintain 定期关闭长时间未使用的连接池
+func (g *Group[T]) maintain(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		g.sweep()
+	}
+}
+
+func (g *Group[T]) sweep() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for name, m := range g.pools {
+		if time.Since(m.used) > g.poolIdle {
+			go m.pool.Close()
+			delete(g.pools, name)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package pool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/gopkg/util/log"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/metrics"
+)
+
+// 长连接池, 预先建立连接并完成握手, 会话开始时直接取用, 省去建连与握手的耗时
+// 连接只会被取用一次, 会话结束时由会话关闭, 不放回池中
+// 因为会话的接收协程可能仍阻塞在连接上, 放回后会读走下一个会话的数据
+
+// 未配置时的默认值
+const (
+	defaultSize          = 2
+	defaultMaxIdle       = 60
+	defaultCheckInterval = 15
+	defaultSessionIdle   = 30
+)
+
+// Conn 需要建立连接并握手的长连接
+type Conn interface {
+	Dial() error
+	Start() error
+	Close() error
+}
+
+// Pinger 支持健康检查的连接
+type Pinger interface {
+	Ping() error
+}
+
+type idleConn[T Conn] struct {
+	conn  T
+	since time.Time
+}
+
+// Pool 一个后端的连接池
+type Pool[T Conn] struct {
+	name    string
+	new     func() T
+	size    int
+	maxIdle time.Duration
+
+	mu      sync.Mutex
+	idle    []idleConn[T]
+	dialing int
+	closed  bool
+	stop    chan struct{}
+}
+
+// New 创建连接池并在后台建立空闲连接
+func New[T Conn](name string, new func() T, c config.ConnPool) *Pool[T] {
+	p := &Pool[T]{
+		name:    name,
+		new:     new,
+		size:    c.Size,
+		maxIdle: time.Duration(c.MaxIdle) * time.Second,
+		stop:    make(chan struct{}),
+	}
+	if p.size == 0 {
+		p.size = defaultSize
+	}
+	if p.maxIdle <= 0 {
+		p.maxIdle = defaultMaxIdle * time.Second
+	}
+	// 空闲连接不能超过服务端会话的空闲超时, 否则取出的连接已无法合成
+	sessionIdle := time.Duration(c.SessionIdle) * time.Second
+	if sessionIdle <= 0 {
+		sessionIdle = defaultSessionIdle * time.Second
+	}
+	p.maxIdle = min(p.maxIdle, sessionIdle)
+	interval := time.Duration(c.CheckInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultCheckInterval * time.Second
+	}
+	p.fill()
+	go p.maintain(interval)
+	return p
+}
+
+// Dial 创建实例并完成连接与握手, 失败时关闭实例
+func Dial[T Conn](new func() T) (T, error) {
+	conn := new()
+	err := conn.Dial()
+	if err == nil {
+		err = conn.Start()
+	}
+	if err != nil {
+		_ = conn.Close()
+		var zero T
+		return zero, err
+	}
+	return conn, nil
+}
+
+// Get 取出一个已握手的连接, 没有空闲连接时同步建立
+func (p *Pool[T]) Get() (T, error) {
+	p.mu.Lock()
+	for len(p.idle) > 0 {
+		// 优先取最新的连接, 较旧的连接更可能已被服务端关闭
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if time.Since(c.since) > p.maxIdle {
+			go p.discard(c.conn)
+			continue
+		}
+		p.mu.Unlock()
+		p.fill()
+		metrics.PoolGet.Inc(p.name, "hit")
+		return c.conn, nil
+	}
+	p.mu.Unlock()
+	p.fill()
+	metrics.PoolGet.Inc(p.name, "miss")
+	return Dial(p.new)
+}
+
+// Close 关闭连接池与所有空闲连接
+func (p *Pool[T]) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	for _, c := range idle {
+		p.discard(c.conn)
+	}
+	metrics.PoolIdle.Set(0, p.name)
+}
+
+// fill 在后台补足空闲连接
+func (p *Pool[T]) fill() {
+	p.mu.Lock()
+	n := p.size - len(p.idle) - p.dialing
+	if p.closed || n <= 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.dialing += n
+	p.mu.Unlock()
+	for i := 0; i < n; i++ {
+		go p.add()
+	}
+}
+
+func (p *Pool[T]) add() {
+	conn, err := Dial(p.new)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dialing--
+	if err != nil {
+		// 失败后等待下一次健康检查时重试
+		log.Error("pool "+p.name+" dial err:", err)
+		return
+	}
+	if p.closed {
+		go p.discard(conn)
+		return
+	}
+	p.idle = append(p.idle, idleConn[T]{conn: conn, since: time.Now()})
+	metrics.PoolIdle.Set(float64(len(p.idle)), p.name)
+}
+
+// maintain 定期关闭过期与不可用的空闲连接, 并补足空闲连接
+func (p *Pool[T]) maintain(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.check()
+			p.fill()
+		}
+	}
+}
+
+func (p *Pool[T]) check() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	// 检查期间取出全部空闲连接, 期间的Get会同步建立连接
+	var healthy []idleConn[T]
+	for _, c := range idle {
+		if time.Since(c.since) > p.maxIdle {
+			p.discard(c.conn)
+			continue
+		}
+		if pinger, ok := any(c.conn).(Pinger); ok {
+			if err := pinger.Ping(); err != nil {
+				log.Error("pool "+p.name+" ping err:", err)
+				p.discard(c.conn)
+				continue
+			}
+		}
+		healthy = append(healthy, c)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		for _, c := range healthy {
+			go p.discard(c.conn)
+		}
+		return
+	}
+	p.idle = append(p.idle, healthy...)
+	metrics.PoolIdle.Set(float64(len(p.idle)), p.name)
+}
+
+func (p *Pool[T]) discard(conn T) {
+	if err := conn.Close(); err != nil {
+		log.Error("pool "+p.name+" close err:", err)
+	}
+}
@@ -0,0 +1,115 @@
+package pool
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+type fakeConn struct {
+	started atomic.Bool
+	closed  atomic.Bool
+	healthy *atomic.Bool
+}
+
+func (c *fakeConn) Dial() error  { return nil }
+func (c *fakeConn) Start() error { c.started.Store(true); return nil }
+func (c *fakeConn) Close() error { c.closed.Store(true); return nil }
+func (c *fakeConn) Ping() error {
+	if !c.healthy.Load() {
+		return errors.New("broken")
+	}
+	return nil
+}
+
+func waitIdle[T Conn](t *testing.T, p *Pool[T], n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		p.mu.Lock()
+		idle := len(p.idle)
+		p.mu.Unlock()
+		if idle == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("idle connections never reached %d", n)
+}
+
+func TestPool(t *testing.T) {
+	healthy := &atomic.Bool{}
+	healthy.Store(true)
+	var dials atomic.Int32
+	p := New("test", func() *fakeConn {
+		dials.Add(1)
+		return &fakeConn{healthy: healthy}
+	}, config.ConnPool{Size: 2, CheckInterval: 3600})
+	defer p.Close()
+	waitIdle(t, p, 2)
+
+	// 取出的连接已完成握手, 并在后台补足空闲连接
+	conn, err := p.Get()
+	if err != nil || !conn.started.Load() {
+		t.Fatalf("got %v, %v", conn, err)
+	}
+	waitIdle(t, p, 2)
+	if dials.Load() != 3 {
+		t.Fatalf("dials = %d, want 3", dials.Load())
+	}
+
+	// 健康检查关闭不可用的连接
+	healthy.Store(false)
+	p.check()
+	waitIdle(t, p, 0)
+}
+
+func TestPoolSessionIdle(t *testing.T) {
+	p := New("test", func() *fakeConn { return &fakeConn{healthy: &atomic.Bool{}} },
+		config.ConnPool{Size: 1, MaxIdle: 3600, SessionIdle: 1, CheckInterval: 3600})
+	defer p.Close()
+	waitIdle(t, p, 1)
+
+	// 超过服务端会话空闲超时的连接不再取用
+	p.mu.Lock()
+	old := p.idle[0].conn
+	p.idle[0].since = time.Now().Add(-2 * time.Second)
+	p.mu.Unlock()
+	conn, err := p.Get()
+	if err != nil || conn == old {
+		t.Fatalf("got stale connection %v, %v", conn, err)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	g := NewGroup[*fakeConn](config.ConnPool{Size: 1, MaxPools: 2, PoolIdle: 3600, CheckInterval: 3600})
+	newConn := func() *fakeConn { return &fakeConn{healthy: &atomic.Bool{}} }
+	a := g.Pool("a", newConn)
+	b := g.Pool("b", newConn)
+	if g.Pool("a", newConn) != a {
+		t.Fatal("pool should be reused")
+	}
+
+	// 超过上限时关闭最久未使用的连接池
+	g.Pool("c", newConn)
+	if g.Len() != 2 || g.Pool("a", newConn) != a {
+		t.Fatal("least recently used pool should be evicted")
+	}
+	if g.Pool("b", newConn) == b {
+		t.Fatal("evicted pool should be recreated")
+	}
+
+	// 长时间未使用的连接池被关闭
+	g.mu.Lock()
+	for _, m := range g.pools {
+		m.used = time.Now().Add(-2 * time.Hour)
+	}
+	g.mu.Unlock()
+	g.sweep()
+	if g.Len() != 0 {
+		t.Fatalf("idle pools not closed, %d left", g.Len())
+	}
+}
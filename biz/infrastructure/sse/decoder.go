@@ -0,0 +1,139 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Server-Sent Events的解码, 按照 https://html.spec.whatwg.org/multipage/server-sent-events.html 实现
+// 支持CRLF, LF, CR三种换行, 注释, 多行data, event, id与retry字段, 单行长度没有限制
+
+// DefaultEvent 未指定event字段时的事件类型
+const DefaultEvent = "message"
+
+// Event 一个完整的事件
+type Event struct {
+	// Id 最近一次收到的事件id, 未被新的id字段覆盖时沿用
+	Id   string
+	Type string
+	// Data 多行data以换行拼接
+	Data string
+}
+
+// Decoder 从流中依次解码事件
+type Decoder struct {
+	r     *bufio.Reader
+	start bool
+
+	// lastId 最近的事件id
+	lastId string
+	// retry 服务端建议的重连间隔
+	retry time.Duration
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), start: true}
+}
+
+// Next 返回下一个事件, 流结束时返回io.EOF, 未以空行结束的事件会被丢弃
+func (d *Decoder) Next() (*Event, error) {
+	var typ string
+	var data strings.Builder
+	hasData := false
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+		// 空行, 分发事件
+		if len(line) == 0 {
+			if !hasData {
+				typ = ""
+				continue
+			}
+			ev := &Event{Id: d.lastId, Type: typ, Data: strings.TrimSuffix(data.String(), "\n")}
+			if ev.Type == "" {
+				ev.Type = DefaultEvent
+			}
+			return ev, nil
+		}
+		// 注释
+		if line[0] == ':' {
+			continue
+		}
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			value = bytes.TrimPrefix(value, []byte(" "))
+		}
+		switch string(field) {
+		case "event":
+			typ = string(value)
+		case "data":
+			data.Write(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				d.lastId = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(string(value), 10, 63); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		default:
+			// 未知字段忽略
+		}
+	}
+}
+
+// LastEventId 最近一次收到的事件id
+func (d *Decoder) LastEventId() string {
+	return d.lastId
+}
+
+// Retry 服务端建议的重连间隔, 未指定时为0
+func (d *Decoder) Retry() time.Duration {
+	return d.retry
+}
+
+// readLine 读取一行, 不包含换行符
+func (d *Decoder) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				// 流结束时未完成的行与事件都丢弃
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		switch b {
+		case '\n':
+			return d.trimBOM(line), nil
+		case '\r':
+			// CRLF视为一个换行
+			if next, err := d.r.Peek(1); err == nil && next[0] == '\n' {
+				_, _ = d.r.ReadByte()
+			}
+			return d.trimBOM(line), nil
+		default:
+			line = append(line, b)
+		}
+	}
+}
+
+// trimBOM 去掉流开头的BOM
+func (d *Decoder) trimBOM(line []byte) []byte {
+	if d.start {
+		d.start = false
+		return bytes.TrimPrefix(line, []byte("\xef\xbb\xbf"))
+	}
+	return line
+}
@@ -0,0 +1,45 @@
+package sse
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecoder(t *testing.T) {
+	stream := "\xef\xbb\xbfid:1\r\nevent:result\r\n:HTTP_STATUS/200\r\ndata:{\"a\":\r\ndata: 1}\r\n\r\n" +
+		"retry: 3000\ndata\n\n" +
+		"\rid:2\revent:error\rdata:" + strings.Repeat("x", 100000) + "\r\r" +
+		"data:incomplete"
+
+	d := NewDecoder(strings.NewReader(stream))
+
+	ev, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Id != "1" || ev.Type != "result" || ev.Data != "{\"a\":\n1}" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+
+	ev, err = d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Id != "1" || ev.Type != DefaultEvent || ev.Data != "" || d.Retry() != 3*time.Second {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+
+	ev, err = d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Id != "2" || ev.Type != "error" || len(ev.Data) != 100000 {
+		t.Fatalf("unexpected event id=%s type=%s len=%d", ev.Id, ev.Type, len(ev.Data))
+	}
+
+	if _, err = d.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package trace
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// 对otel的简单封装, TracerProvider由go-zero根据配置中的Telemetry初始化
+
+const tracerName = "github.com/xh-polaris/psych-senior"
+
+// Start 开启一个span, ctx中已有span时作为其子span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, oteltrace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, oteltrace.WithAttributes(attrs...))
+}
+
+// StartWithKind 开启一个指定类型的span, 用于标记消息的生产与消费
+func StartWithKind(ctx context.Context, name string, kind oteltrace.SpanKind, attrs ...attribute.KeyValue) (context.Context, oteltrace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, oteltrace.WithSpanKind(kind), oteltrace.WithAttributes(attrs...))
+}
+
+// StartAt 开启一个从指定时间开始的span, 用于事后才能确定起点的阶段, 如一句话的识别
+func StartAt(ctx context.Context, name string, start time.Time, attrs ...attribute.KeyValue) (context.Context, oteltrace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, oteltrace.WithTimestamp(start), oteltrace.WithAttributes(attrs...))
+}
+
+// End 结束span, 有错误时记录错误信息
+func End(span oteltrace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Detach 保留ctx中的追踪信息, 但不再继承其取消信号
+// 长连接的生命周期长于http升级请求, 需要脱离原请求的ctx
+func Detach(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// Inject 将ctx中的追踪信息写入carrier
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract 从carrier中恢复追踪信息
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
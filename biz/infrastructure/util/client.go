@@ -2,42 +2,193 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
 	"io"
 	"log"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-var client *HttpClient
+// 未配置时的默认值
+const (
+	defaultConnectTimeout   = 3 * time.Second
+	defaultFirstByteTimeout = 15 * time.Second
+	defaultIdleTimeout      = 30 * time.Second
+	defaultRequestTimeout   = 60 * time.Second
+	defaultRetries          = 2
+	defaultRetryBackoff     = 200 * time.Millisecond
+)
+
+// ErrIdleTimeout 流式响应在IdleTimeout内没有新的数据
+var ErrIdleTimeout = errors.New("stream idle timeout")
+
+// HttpError 第三方接口返回了非2xx的响应
+type HttpError struct {
+	StatusCode int
+	Body       string
+	// RequestId 第三方的请求id, 用于定位问题
+	RequestId string
+}
+
+func (e *HttpError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d, request id: %s, response body: %s", e.StatusCode, e.RequestId, e.Body)
+}
+
+// Temporary 是否为可重试的错误, 限流与服务端错误可以重试
+func (e *HttpError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+var (
+	client     *HttpClient
+	clientOnce sync.Once
+)
 
 // HttpClient 是一个简单的 HTTP 客户端
 type HttpClient struct {
 	Client *http.Client
-	Config *config.Config
+
+	idleTimeout    time.Duration
+	requestTimeout time.Duration
+	retries        int
+	retryBackoff   time.Duration
 }
 
 // NewHttpClient 创建一个新的 HttpClient 实例
-func NewHttpClient() *HttpClient {
+func NewHttpClient(c config.HttpClient) *HttpClient {
+	connectTimeout := duration(c.ConnectTimeout, defaultConnectTimeout)
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: duration(c.FirstByteTimeout, defaultFirstByteTimeout),
+		ExpectContinueTimeout: time.Second,
+	}
+	retries := c.Retries
+	if retries == 0 {
+		retries = defaultRetries
+	} else if retries < 0 {
+		retries = 0
+	}
 	return &HttpClient{
-		Client: &http.Client{},
+		Client:         &http.Client{Transport: otelhttp.NewTransport(transport)},
+		idleTimeout:    duration(c.IdleTimeout, defaultIdleTimeout),
+		requestTimeout: duration(c.RequestTimeout, defaultRequestTimeout),
+		retries:        retries,
+		retryBackoff:   duration(c.RetryBackoff, defaultRetryBackoff),
 	}
 }
 
+func duration(ms int64, def time.Duration) time.Duration {
+	if ms <= 0 {
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
 // GetHttpClient 获取客户端单例
 func GetHttpClient() *HttpClient {
-	if client == nil {
-		client = NewHttpClient()
-	}
+	clientOnce.Do(func() {
+		var c config.HttpClient
+		if conf := config.GetConfig(); conf != nil {
+			c = conf.HttpClient
+		}
+		client = NewHttpClient(c)
+	})
 	return client
 }
 
-// Req 发送 HTTP 请求
-func (c *HttpClient) Req(method, url string, headers http.Header, body interface{}) (map[string]interface{}, error) {
-	resp, err := c.do(method, url, headers, body)
+// reqOptions 单次请求的选项
+type reqOptions struct {
+	idempotent bool
+	timeout    time.Duration
+}
+
+type ReqOption func(o *reqOptions)
+
+// Idempotent 标记请求是幂等的, 非GET请求也允许重试
+func Idempotent() ReqOption {
+	return func(o *reqOptions) {
+		o.idempotent = true
+	}
+}
+
+// Timeout 覆盖单次请求的总超时, 用于耗时明显长于普通请求的调用
+func Timeout(d time.Duration) ReqOption {
+	return func(o *reqOptions) {
+		o.timeout = d
+	}
+}
+
+// Req 发送 HTTP 请求, 幂等请求失败时按退避时间重试
+func (c *HttpClient) Req(ctx context.Context, method, url string, headers http.Header, body interface{}, opts ...ReqOption) (map[string]interface{}, error) {
+	var respMap map[string]interface{}
+	if err := c.ReqInto(ctx, method, url, headers, body, &respMap, opts...); err != nil {
+		return nil, err
+	}
+	return respMap, nil
+}
+
+// ReqInto 发送 HTTP 请求, 并将响应反序列化到out中
+func (c *HttpClient) ReqInto(ctx context.Context, method, url string, headers http.Header, body, out interface{}, opts ...ReqOption) error {
+	var o reqOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	retries := 0
+	if o.idempotent || method == http.MethodGet || method == http.MethodHead {
+		retries = c.retries
+	}
+
+	bodyBytes, err := json.Marshal(body)
 	if err != nil {
-		return nil, fmt.Errorf("发送请求失败: %w", err)
+		return fmt.Errorf("请求体序列化失败: %w", err)
+	}
+
+	timeout := c.requestTimeout
+	if o.timeout > 0 {
+		timeout = o.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		err = c.req(ctx, method, url, headers, bodyBytes, out)
+		if err == nil || attempt >= retries || !temporary(err) {
+			return err
+		}
+		// 指数退避, 加入随机抖动避免同时重试
+		backoff := c.retryBackoff << attempt
+		backoff = backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// req 执行一次非流式请求
+func (c *HttpClient) req(ctx context.Context, method, url string, headers http.Header, body []byte, out interface{}) error {
+	resp, err := c.do(ctx, method, url, headers, body)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
@@ -47,60 +198,72 @@ func (c *HttpClient) Req(method, url string, headers http.Header, body interface
 
 	// 检查响应状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		_resp, _ := io.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("unexpected status code: %d, response body: %s", resp.StatusCode, _resp)
-		return nil, fmt.Errorf(errMsg)
+		return newHttpError(resp)
 	}
 
 	// 读取响应
 	_resp, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return fmt.Errorf("读取响应失败: %w", err)
 	}
 
 	// 反序列化响应体
-	var respMap map[string]interface{}
-	if err := json.Unmarshal(_resp, &respMap); err != nil {
-		return nil, fmt.Errorf("反序列化响应失败: %w", err)
+	if err := json.Unmarshal(_resp, out); err != nil {
+		return fmt.Errorf("反序列化响应失败: %w", err)
 	}
-
-	return respMap, nil
+	return nil
 }
 
-// StreamReq 流式响应的请求
-func (c *HttpClient) StreamReq(method, url string, headers http.Header, body interface{}) (*StreamReader, error) {
+// temporary 是否为可以重试的错误
+func temporary(err error) bool {
+	var he *HttpError
+	if errors.As(err, &he) {
+		return he.Temporary()
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// 只重试传输层错误, 响应已成功返回但无法解析时重试只会重复计费
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF)
+}
 
-	resp, err := c.do(method, url, headers, body)
+// StreamReq 流式响应的请求, ctx结束或超过IdleTimeout没有新数据时中断读取
+func (c *HttpClient) StreamReq(ctx context.Context, method, url string, headers http.Header, body interface{}) (*StreamReader, error) {
+	bodyBytes, err := json.Marshal(body)
 	if err != nil {
-		return nil, fmt.Errorf("发送请求失败: %w", err)
+		return nil, fmt.Errorf("请求体序列化失败: %w", err)
 	}
 
-	reader := &StreamReader{
-		resp:   resp,
-		reader: resp.Body,
+	ctx, cancel := context.WithCancelCause(ctx)
+	resp, err := c.do(ctx, method, url, headers, bodyBytes)
+	if err != nil {
+		cancel(nil)
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 
 	// 检查响应状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		defer func() { _ = reader.Close() }()
-		_resp, _ := reader.ReadAll()
-		errMsg := fmt.Sprintf("unexpected status code: %d, response body: %s", resp.StatusCode, _resp)
-		return nil, fmt.Errorf(errMsg)
+		defer cancel(nil)
+		defer func() { _ = resp.Body.Close() }()
+		return nil, newHttpError(resp)
 	}
 
+	reader := &StreamReader{
+		ctx:    ctx,
+		cancel: cancel,
+		resp:   resp,
+		reader: resp.Body,
+		idle:   c.idleTimeout,
+	}
+	reader.timer = time.AfterFunc(c.idleTimeout, func() { cancel(ErrIdleTimeout) })
 	return reader, nil
 }
 
 // do 实际执行请求
-func (c *HttpClient) do(method, url string, headers http.Header, body interface{}) (*http.Response, error) {
-	// 将 body 序列化为 JSON
-	bodyBytes, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("请求体序列化失败: %w", err)
-	}
-
+func (c *HttpClient) do(ctx context.Context, method, url string, headers http.Header, body []byte) (*http.Response, error) {
 	// 创建新的请求
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -113,29 +276,66 @@ func (c *HttpClient) do(method, url string, headers http.Header, body interface{
 	}
 
 	// 发送请求
-	resp, err := c.Client.Do(req)
+	return c.Client.Do(req)
+}
 
-	return resp, err
+// newHttpError 读取错误响应, 并提取第三方的请求id
+func newHttpError(resp *http.Response) *HttpError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	e := &HttpError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}
+	for _, h := range []string{"X-Request-Id", "X-Dashscope-Request-Id", "X-Tt-Logid"} {
+		if id := resp.Header.Get(h); id != "" {
+			e.RequestId = id
+			return e
+		}
+	}
+	// 百炼在响应体中返回request_id
+	var b struct {
+		RequestId string `json:"request_id"`
+	}
+	if json.Unmarshal(body, &b) == nil {
+		e.RequestId = b.RequestId
+	}
+	return e
 }
 
 // StreamReader 流式请求Reader, 封装是为了避免只返回reader时无法关闭resp.Body
 // 调用方需要负责将流关闭
 type StreamReader struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
 	resp   *http.Response
 	reader io.ReadCloser
+	// timer 读取的空闲计时, 超时后中断请求
+	timer *time.Timer
+	idle  time.Duration
 }
 
 // Read 从Reader中读取
 func (r *StreamReader) Read(p []byte) (n int, err error) {
-	return r.reader.Read(p)
+	n, err = r.reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.idle)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		if cause := context.Cause(r.ctx); cause != nil {
+			err = cause
+		}
+	}
+	return n, err
 }
 
 // ReadAll 读取所有的
 func (r *StreamReader) ReadAll() ([]byte, error) {
-	return io.ReadAll(r.reader)
+	return io.ReadAll(r)
 }
 
 // Close 关闭resp.Body
 func (r *StreamReader) Close() error {
+	r.timer.Stop()
+	defer r.cancel(nil)
 	return r.resp.Body.Close()
 }
@@ -0,0 +1,88 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
+)
+
+func TestHttpClient_Retry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("X-Request-Id", "req-1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	c := NewHttpClient(config.HttpClient{RetryBackoff: 1})
+	res, err := c.Req(context.Background(), http.MethodPost, srv.URL, nil, nil, Idempotent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["ok"] != true || calls.Load() != 3 {
+		t.Fatalf("unexpected result %v after %d calls", res, calls.Load())
+	}
+
+	// 非幂等请求不重试, 并返回带有请求id的错误
+	calls.Store(0)
+	_, err = c.Req(context.Background(), http.MethodPost, srv.URL, nil, nil)
+	var he *HttpError
+	if !errors.As(err, &he) || he.StatusCode != http.StatusServiceUnavailable || he.RequestId != "req-1" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("non idempotent request should not retry, calls %d", calls.Load())
+	}
+}
+
+func TestHttpClient_NoRetryOnDecode(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`not json`))
+	}))
+	defer srv.Close()
+
+	// 响应已返回但无法解析, 即使是幂等请求也不应重试
+	c := NewHttpClient(config.HttpClient{RetryBackoff: 1})
+	if _, err := c.Req(context.Background(), http.MethodPost, srv.URL, nil, nil, Idempotent()); err == nil {
+		t.Fatal("expected decode error")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("decode error should not retry, calls %d", calls.Load())
+	}
+}
+
+func TestHttpClient_StreamIdle(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := NewHttpClient(config.HttpClient{IdleTimeout: 100})
+	reader, err := c.StreamReq(context.Background(), http.MethodPost, srv.URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	start := time.Now()
+	if _, err = reader.ReadAll(); !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected idle timeout, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("idle timeout took too long")
+	}
+}
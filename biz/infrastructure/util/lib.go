@@ -53,12 +53,12 @@ func IntToBytes(n int) []byte {
 	return b
 }
 
-// BytesToInt 将字节数组变成整数
+// BytesToInt 将字节数组变成整数, 按有符号的32位整数解析, 与IntToBytes对应
 func BytesToInt(data []byte) (int, error) {
 	if len(data) != 4 || data == nil {
 		return 0, fmt.Errorf("BytesToInt err")
 	}
-	return int(binary.BigEndian.Uint32(data)), nil
+	return int(int32(binary.BigEndian.Uint32(data))), nil
 }
 
 // FailOnError 出现异常时中止
@@ -68,12 +68,20 @@ func FailOnError(msg string, err error) {
 	}
 }
 
-// ParsePaging 解析分页参数
+// 分页的默认与最大条数
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ParsePaging 解析分页参数, 页码从1开始, 条数限制在1~MaxLimit
 func ParsePaging(p *cmd.Paging) (skip, limit int64) {
-	// 设置分页参数
-	skip = int64((p.Page - 1) * p.Limit)
-	limit = int64(p.Limit)
-	return skip, limit
+	page, size := max(p.Page, 1), p.Limit
+	if size <= 0 {
+		size = DefaultLimit
+	}
+	size = min(size, MaxLimit)
+	return int64((page - 1) * size), int64(size)
 }
 
 // AlertEMail 发送邮件shallwii@126.com
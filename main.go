@@ -13,6 +13,7 @@ import (
 	logx "github.com/xh-polaris/gopkg/util/log"
 	"github.com/xh-polaris/psych-senior/biz/adaptor"
 	"github.com/xh-polaris/psych-senior/biz/adaptor/router"
+	"github.com/xh-polaris/psych-senior/biz/domain/model/failover"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/mq"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/util/log"
 	"github.com/xh-polaris/psych-senior/provider"
@@ -40,9 +41,9 @@ func main() {
 	// 创建服务器追踪器
 	tracer, cfg := tracing.NewServerTracer()
 	// 创造hertz服务器实例
+	// 业务指标由go-zero的DevServer统一暴露, 见 biz/infrastructure/metrics
 	h := server.New(
 		server.WithHostPorts(c.ListenOn),
-		//server.WithTracer(prometheus.NewServerTracer(":9091", "/server/metrics")),
 		tracer,
 	)
 
@@ -61,6 +62,8 @@ func main() {
 
 	// 启动消费者
 	go mq.Consume()
+	// 预先建立语音合成连接
+	failover.WarmTts()
 
 	h.Spin()
 }
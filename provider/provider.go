@@ -3,6 +3,12 @@ package provider
 import (
 	"github.com/google/wire"
 	"github.com/xh-polaris/psych-senior/biz/application/service"
+	"github.com/xh-polaris/psych-senior/biz/domain/hotword"
+	"github.com/xh-polaris/psych-senior/biz/domain/language"
+	"github.com/xh-polaris/psych-senior/biz/domain/phrase"
+	"github.com/xh-polaris/psych-senior/biz/domain/preference"
+	"github.com/xh-polaris/psych-senior/biz/domain/recording"
+	"github.com/xh-polaris/psych-senior/biz/domain/usage"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/mapper/history"
 )
@@ -19,8 +25,15 @@ func Init() {
 
 // Provider 提供controller依赖的对象
 type Provider struct {
-	Config         *config.Config
-	HistoryService service.HistoryService
+	Config            *config.Config
+	HistoryService    service.HistoryService
+	HealthService     service.HealthService
+	UsageService      service.UsageService
+	PhraseService     service.PhraseService
+	PreferenceService service.PreferenceService
+	HotwordService    service.HotwordService
+	LanguageService   service.LanguageService
+	RecordingService  service.RecordingService
 }
 
 func Get() *Provider {
@@ -31,11 +44,24 @@ var RpcSet = wire.NewSet()
 
 var ApplicationSet = wire.NewSet(
 	service.HistoryServiceSet,
+	service.HealthServiceSet,
+	service.UsageServiceSet,
+	service.PhraseServiceSet,
+	service.PreferenceServiceSet,
+	service.HotwordServiceSet,
+	service.LanguageServiceSet,
+	service.RecordingServiceSet,
 )
 
 var InfrastructureSet = wire.NewSet(
 	config.NewConfig,
 	history.NewMongoMapper,
+	usage.GetRecorder,
+	phrase.GetCache,
+	preference.GetStore,
+	hotword.GetStore,
+	language.GetRegistry,
+	recording.GetRecorder,
 	RpcSet,
 )
 
@@ -8,6 +8,12 @@ package provider
 
 import (
 	"github.com/xh-polaris/psych-senior/biz/application/service"
+	"github.com/xh-polaris/psych-senior/biz/domain/hotword"
+	"github.com/xh-polaris/psych-senior/biz/domain/language"
+	"github.com/xh-polaris/psych-senior/biz/domain/phrase"
+	"github.com/xh-polaris/psych-senior/biz/domain/preference"
+	"github.com/xh-polaris/psych-senior/biz/domain/recording"
+	"github.com/xh-polaris/psych-senior/biz/domain/usage"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/config"
 	"github.com/xh-polaris/psych-senior/biz/infrastructure/mapper/history"
 )
@@ -23,9 +29,45 @@ func NewProvider() (*Provider, error) {
 	historyService := service.HistoryService{
 		HistoryMapper: mongoMapper,
 	}
+	registry := language.GetRegistry()
+	healthService := service.HealthService{
+		Config:        configConfig,
+		HistoryMapper: mongoMapper,
+		Registry:      registry,
+	}
+	recorder := usage.GetRecorder()
+	usageService := service.UsageService{
+		Recorder: recorder,
+	}
+	cache := phrase.GetCache()
+	phraseService := service.PhraseService{
+		Cache: cache,
+	}
+	store := preference.GetStore()
+	preferenceService := service.PreferenceService{
+		Store: store,
+	}
+	hotwordStore := hotword.GetStore()
+	hotwordService := service.HotwordService{
+		Store: hotwordStore,
+	}
+	languageService := service.LanguageService{
+		Registry: registry,
+	}
+	recordingRecorder := recording.GetRecorder()
+	recordingService := service.RecordingService{
+		Recorder: recordingRecorder,
+	}
 	providerProvider := &Provider{
-		Config:         configConfig,
-		HistoryService: historyService,
+		Config:            configConfig,
+		HistoryService:    historyService,
+		HealthService:     healthService,
+		UsageService:      usageService,
+		PhraseService:     phraseService,
+		PreferenceService: preferenceService,
+		HotwordService:    hotwordService,
+		LanguageService:   languageService,
+		RecordingService:  recordingService,
 	}
 	return providerProvider, nil
 }